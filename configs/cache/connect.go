@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"sort"
 	"strconv"
 	"time"
 
@@ -85,3 +86,56 @@ func ExpiresIn(ctx context.Context, key string) (time.Duration, error) {
 	}
 	return duration, nil
 }
+
+// Sets key only if it does not exist yet. Returns true if this call set it, which makes it usable as a simple distributed lock.
+func SetIfNotExists(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	val, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return rdb.SetNX(ctx, key, val, expiration).Result()
+}
+
+// Adds by to the score of member in the sorted set at key and resets the key's expiration time
+func IncrementScore(ctx context.Context, key string, member string, by float64, expiration time.Duration) error {
+	pipe := rdb.TxPipeline()
+	pipe.ZIncrBy(ctx, key, by, member)
+	pipe.Expire(ctx, key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// Returns at most n members with the highest scores summed across the sorted sets at keys, highest first. Keys that don't exist count as empty sets.
+func TopScores(ctx context.Context, keys []string, n int) ([]ScoredMember, error) {
+	zs, err := rdb.ZUnionWithScores(ctx, redis.ZStore{Keys: keys, Aggregate: "SUM"}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(zs, func(i, j int) bool { return zs[i].Score > zs[j].Score })
+	if len(zs) > n {
+		zs = zs[:n]
+	}
+
+	members := make([]ScoredMember, len(zs))
+	for i, z := range zs {
+		members[i] = ScoredMember{Member: z.Member.(string), Score: z.Score}
+	}
+	return members, nil
+}
+
+// Adds by to the counter at key and returns the new count. The counter is created with the expiration time, which isn't reset by later increments.
+func IncrementCounter(ctx context.Context, key string, by int64, expiration time.Duration) (int64, error) {
+	pipe := rdb.TxPipeline()
+	pipe.SetNX(ctx, key, 0, expiration)
+	count := pipe.IncrBy(ctx, key, by)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
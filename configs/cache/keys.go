@@ -1,5 +1,7 @@
 package cache
 
+import "strconv"
+
 // Key format:
 //  1. "U" meaning "user"
 //  2. user_id of user
@@ -23,3 +25,74 @@ func NewUserConfirmCodeKey(contact string) string {
 func PasswordResetCodeKey(contact string) string {
 	return "P:" + contact + ":RC"
 }
+
+// Key format:
+//  1. "P" meaning "profile"
+//  2. profile_id of profile
+//  3. "S" meaning "suggestions"
+func SuggestionsKey(profile_id string) string {
+	return "P:" + profile_id + ":S"
+}
+
+// Key format:
+//  1. "J" meaning "job"
+//  2. name of the background job
+//  3. "L" meaning "lock"
+func JobLockKey(job_name string) string {
+	return "J:" + job_name + ":L"
+}
+
+// Key format:
+//  1. "PO" meaning "payout"
+//  2. profile_id of the creator being paid
+//  3. "L" meaning "lock"
+func PayoutLockKey(profile_id string) string {
+	return "PO:" + profile_id + ":L"
+}
+
+// Key format:
+//  1. "U" meaning "upload"
+//  2. id of the upload being written to
+//  3. "L" meaning "lock"
+func UploadLockKey(upload_id string) string {
+	return "U:" + upload_id + ":L"
+}
+
+// Key format:
+//  1. "PE" meaning "payment event"
+//  2. id of the event sent to the payments webhook
+func PaymentEventKey(event_id string) string {
+	return "PE:" + event_id
+}
+
+// Key format:
+//  1. "TR" meaning "trending"
+//  2. start of the time bucket in unix seconds
+func TrendingBucketKey(bucket int64) string {
+	return "TR:" + strconv.FormatInt(bucket, 10)
+}
+
+// Key format:
+//  1. "TR" meaning "trending"
+//  2. start of the time bucket in unix seconds
+//  3. "S" meaning "searcher"
+//  4. hash of the searching profile and the search, so searches are counted once per profile without storing who searched
+func TrendingSearcherKey(bucket int64, hash string) string {
+	return "TR:" + strconv.FormatInt(bucket, 10) + ":S:" + hash
+}
+
+// Key format:
+//  1. "TR" meaning "trending"
+//  2. name of the window, e.g. "24h"
+//  3. "R" meaning "result"
+func TrendingResultKey(window string) string {
+	return "TR:" + window + ":R"
+}
+
+// Key format:
+//  1. "GI" meaning "graph import"
+//  2. profile_id of the importing profile
+//  3. "C" meaning "count"
+func GraphImportCountKey(profile_id string) string {
+	return "GI:" + profile_id + ":C"
+}
@@ -15,7 +15,8 @@ var (
 )
 
 const (
-	queryTimeout = time.Second
+	queryTimeout    = time.Second
+	jobQueryTimeout = time.Minute
 )
 
 func InitDatabase() {
@@ -56,14 +57,35 @@ func migrate(db *gorm.DB) {
 		log.Fatalf("Error during join table setup: %v", err)
 	}
 
+	if err := prepareMigrations(db); err != nil {
+		log.Fatalf("Error while preparing migrations: %v", err)
+	}
+
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Profile{},
 		&models.SearchHistory{},
+		&models.MutedKeyword{},
 		&models.Post{},
+		&models.Audience{},
+		&models.SubscriptionTier{},
+		&models.LedgerTransaction{},
+		&models.LedgerEntry{},
 		&models.PostMedia{},
+		&models.PostDraft{},
+		&models.DraftMedia{},
+		&models.Upload{},
 		&models.Comment{},
+		&models.Hashtag{},
+		&models.Mention{},
+		&models.Revision{},
+		&models.Poll{},
+		&models.PollOption{},
+		&models.PollVote{},
 		&models.Notification{},
+		&models.ProfileUnfollow{},
+		&models.ProfileDailyStat{},
+		&models.PostDailyStat{},
 	); err != nil {
 		log.Fatalf("Error during migration: %v", err)
 	}
@@ -71,6 +93,72 @@ func migrate(db *gorm.DB) {
 	log.Println("Migrations ran successfully!")
 }
 
+/*
+Changes AutoMigrate can't make by itself to tables that already hold data. Each step checks whether it is still needed,
+so running them on every start does nothing once they have been applied.
+*/
+func prepareMigrations(db *gorm.DB) error {
+	if err := dropUniqueIndex(db, "idx_ledger_transactions_refund_of_id"); err != nil { // a charge can be refunded in parts, AutoMigrate recreates it as a plain index
+		return err
+	}
+
+	if err := dropCascadingForeignKey(db, "posts", "fk_posts_reposts"); err != nil { // quotes outlive the post they quote, AutoMigrate recreates it with ON DELETE SET NULL
+		return err
+	}
+
+	return dedupeSearchHistory(db)
+}
+
+/*
+Search history used to add a row for every search. Before the unique entry index is created, queries are normalized the way
+AddToSearchHistory stores them, SearchedAt is filled from CreatedAt and only the latest row of each profile and query is kept.
+*/
+func dedupeSearchHistory(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.SearchHistory{}) || migrator.HasIndex(&models.SearchHistory{}, "idx_search_histories_entry") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if !tx.Migrator().HasColumn(&models.SearchHistory{}, "SearchedAt") {
+			if err := tx.Migrator().AddColumn(&models.SearchHistory{}, "SearchedAt"); err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec(`UPDATE search_histories SET searched_at = created_at, query = lower(regexp_replace(btrim(query), '\s+', ' ', 'g'));`).Error; err != nil {
+			return err
+		}
+
+		query := "DELETE FROM search_histories AS older USING search_histories AS newer WHERE older.profile_id = newer.profile_id AND older.query = newer.query "
+		query += "AND (older.searched_at < newer.searched_at OR (older.searched_at = newer.searched_at AND older.id < newer.id));"
+		return tx.Exec(query).Error
+	})
+}
+
+// Drops the index if it exists and is unique
+func dropUniqueIndex(db *gorm.DB, indexName string) error {
+	var isUnique bool
+	if err := db.Raw("SELECT COUNT(*) > 0 FROM pg_indexes WHERE indexname = ? AND indexdef LIKE 'CREATE UNIQUE INDEX%'", indexName).Scan(&isUnique).Error; err != nil {
+		return err
+	}
+	if !isUnique {
+		return nil
+	}
+	return db.Exec("DROP INDEX " + indexName).Error
+}
+
+// Drops the foreign key constraint if it exists and deletes rows in cascade
+func dropCascadingForeignKey(db *gorm.DB, table, constraintName string) error {
+	var isCascading bool
+	if err := db.Raw("SELECT COUNT(*) > 0 FROM pg_constraint WHERE conname = ? AND contype = 'f' AND confdeltype = 'c'", constraintName).Scan(&isCascading).Error; err != nil {
+		return err
+	}
+	if !isCascading {
+		return nil
+	}
+	return db.Exec("ALTER TABLE " + table + " DROP CONSTRAINT " + constraintName).Error
+}
+
 func setupJoinTables(db *gorm.DB) error {
 	if err := db.SetupJoinTable(&models.Profile{}, "Followers", &models.ProfileFollower{}); err != nil {
 		return err
@@ -80,6 +168,22 @@ func setupJoinTables(db *gorm.DB) error {
 		return err
 	}
 
+	if err := db.SetupJoinTable(&models.Profile{}, "Blocked", &models.ProfileBlock{}); err != nil {
+		return err
+	}
+
+	if err := db.SetupJoinTable(&models.Profile{}, "Muted", &models.ProfileMute{}); err != nil {
+		return err
+	}
+
+	if err := db.SetupJoinTable(&models.Profile{}, "Dismissed", &models.ProfileSuggestionDismissal{}); err != nil {
+		return err
+	}
+
+	if err := db.SetupJoinTable(&models.Audience{}, "Members", &models.AudienceMember{}); err != nil {
+		return err
+	}
+
 	if err := db.SetupJoinTable(&models.Post{}, "Likes", &models.PostLike{}); err != nil {
 		return err
 	}
@@ -100,6 +204,14 @@ func setupJoinTables(db *gorm.DB) error {
 		return err
 	}
 
+	if err := db.SetupJoinTable(&models.Post{}, "Hashtags", &models.PostHashtag{}); err != nil {
+		return err
+	}
+
+	if err := db.SetupJoinTable(&models.Comment{}, "Hashtags", &models.CommentHashtag{}); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -107,3 +219,8 @@ func setupJoinTables(db *gorm.DB) error {
 func NewQueryContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), queryTimeout)
 }
+
+// Returns a context with a timeout of 1 minute, for background jobs whose queries go over whole tables
+func NewJobQueryContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), jobQueryTimeout)
+}
@@ -145,3 +145,98 @@ func EnvDbConnMaxLifetime() int {
 	}
 	return max
 }
+
+// returns either "local" or "s3"
+func EnvStorageDriver() string {
+	value, exists := os.LookupEnv("STORAGE_DRIVER")
+	if !exists {
+		log.Fatal("STORAGE_DRIVER not set")
+	}
+	if value != "local" && value != "s3" {
+		log.Fatalf("STORAGE_DRIVER must be either \"local\" or \"s3\"")
+	}
+	return value
+}
+
+// returns the base url that stored objects are publicly served from. Object keys are appended to it.
+func EnvStoragePublicUrl() string {
+	value, exists := os.LookupEnv("STORAGE_PUBLIC_URL")
+	if !exists {
+		log.Fatal("STORAGE_PUBLIC_URL not set")
+	}
+	return value
+}
+
+func EnvLocalStorageRoot() string {
+	value, exists := os.LookupEnv("LOCAL_STORAGE_ROOT")
+	if !exists {
+		log.Fatal("LOCAL_STORAGE_ROOT not set")
+	}
+	return value
+}
+
+func EnvS3EndpointRegionBucket() (endpoint, region, bucket string) {
+	value1, exists1 := os.LookupEnv("S3_ENDPOINT")
+	if !exists1 {
+		log.Fatal("S3_ENDPOINT not set")
+	}
+	value2, exists2 := os.LookupEnv("S3_REGION")
+	if !exists2 {
+		log.Fatal("S3_REGION not set")
+	}
+	value3, exists3 := os.LookupEnv("S3_BUCKET")
+	if !exists3 {
+		log.Fatal("S3_BUCKET not set")
+	}
+	endpoint, region, bucket = value1, value2, value3
+	return
+}
+
+func EnvS3Keys() (accessKey, secretKey string) {
+	value1, exists1 := os.LookupEnv("S3_ACCESS_KEY")
+	if !exists1 {
+		log.Fatal("S3_ACCESS_KEY not set")
+	}
+	value2, exists2 := os.LookupEnv("S3_SECRET_KEY")
+	if !exists2 {
+		log.Fatal("S3_SECRET_KEY not set")
+	}
+	accessKey, secretKey = value1, value2
+	return
+}
+
+// returns the payment provider subscriptions are charged through. Only "fake" is supported for now.
+func EnvPaymentsProvider() string {
+	value, exists := os.LookupEnv("PAYMENTS_PROVIDER")
+	if !exists {
+		log.Fatal("PAYMENTS_PROVIDER not set")
+	}
+	if value != "fake" {
+		log.Fatalf("PAYMENTS_PROVIDER must be \"fake\"")
+	}
+	return value
+}
+
+// returns the secret the payment provider signs webhook events with
+func EnvPaymentsWebhookSecret() string {
+	value, exists := os.LookupEnv("PAYMENTS_WEBHOOK_SECRET")
+	if !exists {
+		log.Fatal("PAYMENTS_WEBHOOK_SECRET not set")
+	}
+	if value == "" {
+		log.Fatal("PAYMENTS_WEBHOOK_SECRET must not be empty")
+	}
+	return value
+}
+
+// returns the secret searchers are hashed with when trending searches are counted
+func EnvTrendingSearchSecret() string {
+	value, exists := os.LookupEnv("TRENDING_SEARCH_SECRET")
+	if !exists {
+		log.Fatal("TRENDING_SEARCH_SECRET not set")
+	}
+	if value == "" {
+		log.Fatal("TRENDING_SEARCH_SECRET must not be empty")
+	}
+	return value
+}
@@ -0,0 +1,69 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Keeps charges in memory and never moves real money. Every charge succeeds unless its customer was declined with Decline.
+type FakeProvider struct {
+	mu          sync.Mutex
+	declined    map[string]bool
+	charges     map[string]int64  // charge ref -> amount left to refund
+	idempotency map[string]string // idempotency key -> charge ref
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		declined:    map[string]bool{},
+		charges:     map[string]int64{},
+		idempotency: map[string]string{},
+	}
+}
+
+// Makes every following charge to customerId fail with ErrDeclined
+func (p *FakeProvider) Decline(customerId string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.declined[customerId] = true
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, customerId string, amount int64, currency, idempotencyKey string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ref, ok := p.idempotency[idempotencyKey]; ok {
+		return ref, nil
+	}
+	if p.declined[customerId] {
+		return "", ErrDeclined
+	}
+
+	ref := "fake_ch_" + uuid.NewString()
+	p.charges[ref] = amount
+	p.idempotency[idempotencyKey] = ref
+	return ref, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, chargeRef string, amount int64) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	left, ok := p.charges[chargeRef]
+	if !ok {
+		return "", errors.New("charge not found")
+	}
+	if amount > left {
+		return "", errors.New("refund is larger than the charge")
+	}
+
+	p.charges[chargeRef] = left - amount
+	return "fake_re_" + uuid.NewString(), nil
+}
+
+func (p *FakeProvider) Payout(ctx context.Context, accountId string, amount int64, currency string) (string, error) {
+	return "fake_po_" + uuid.NewString(), nil
+}
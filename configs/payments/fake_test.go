@@ -0,0 +1,69 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFakeProviderChargeIsIdempotent(t *testing.T) {
+	p := NewFakeProvider()
+	ctx := context.Background()
+
+	first, err := p.Charge(ctx, "customer", 500, "usd", "key")
+	if err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+	second, err := p.Charge(ctx, "customer", 500, "usd", "key")
+	if err != nil {
+		t.Fatalf("retried charge: %v", err)
+	}
+	if first != second {
+		t.Fatalf("retried charge returned %q, want %q", second, first)
+	}
+
+	other, err := p.Charge(ctx, "customer", 500, "usd", "other key")
+	if err != nil {
+		t.Fatalf("charge with another key: %v", err)
+	}
+	if other == first {
+		t.Fatalf("charge with another key returned the reference of the first charge")
+	}
+}
+
+func TestFakeProviderDecline(t *testing.T) {
+	p := NewFakeProvider()
+	p.Decline("declined")
+
+	if _, err := p.Charge(context.Background(), "declined", 500, "usd", "key"); !errors.Is(err, ErrDeclined) {
+		t.Fatalf("charge of a declined customer returned %v, want ErrDeclined", err)
+	}
+	if _, err := p.Charge(context.Background(), "customer", 500, "usd", "other key"); err != nil {
+		t.Fatalf("charge of another customer: %v", err)
+	}
+}
+
+func TestFakeProviderPartialRefunds(t *testing.T) {
+	p := NewFakeProvider()
+	ctx := context.Background()
+
+	ref, err := p.Charge(ctx, "customer", 500, "usd", "key")
+	if err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+	if _, err := p.Refund(ctx, ref, 200); err != nil {
+		t.Fatalf("partial refund: %v", err)
+	}
+	if _, err := p.Refund(ctx, ref, 400); err == nil {
+		t.Fatalf("refund larger than what is left of the charge succeeded")
+	}
+	if _, err := p.Refund(ctx, ref, 300); err != nil {
+		t.Fatalf("refund of the rest: %v", err)
+	}
+	if _, err := p.Refund(ctx, ref, 1); err == nil {
+		t.Fatalf("refund of a fully refunded charge succeeded")
+	}
+	if _, err := p.Refund(ctx, "unknown", 1); err == nil {
+		t.Fatalf("refund of an unknown charge succeeded")
+	}
+}
@@ -0,0 +1,64 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+)
+
+var (
+	provider      Provider
+	webhookSecret []byte
+
+	ErrDeclined = errors.New("payment declined")
+)
+
+const (
+	paymentQueryTimeout = 30 * time.Second
+)
+
+// A Provider moves money in and out of the platform. Amounts are in the smallest unit of the currency (cents for usd).
+type Provider interface {
+	// Charges a customer. Calls with the same idempotencyKey charge at most once and return the same reference.
+	// Returns ErrDeclined if the customer's payment method was refused.
+	Charge(ctx context.Context, customerId string, amount int64, currency, idempotencyKey string) (ref string, err error)
+	// Returns amount of a previous charge to the customer.
+	Refund(ctx context.Context, chargeRef string, amount int64) (ref string, err error)
+	// Sends amount to the bank account connected to accountId.
+	Payout(ctx context.Context, accountId string, amount int64, currency string) (ref string, err error)
+}
+
+func Initialize() {
+	switch configs.EnvPaymentsProvider() {
+	case "fake":
+		provider = NewFakeProvider()
+	}
+	webhookSecret = []byte(configs.EnvPaymentsWebhookSecret())
+
+	log.Println("Payments initialized...")
+}
+
+// Replaces the provider, e.g. with a FakeProvider that declines some customers
+func Use(p Provider) {
+	provider = p
+}
+
+// Returns a new context with a timeout of 30 seconds
+func NewPaymentContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), paymentQueryTimeout)
+}
+
+func Charge(ctx context.Context, customerId string, amount int64, currency, idempotencyKey string) (string, error) {
+	return provider.Charge(ctx, customerId, amount, currency, idempotencyKey)
+}
+
+func Refund(ctx context.Context, chargeRef string, amount int64) (string, error) {
+	return provider.Refund(ctx, chargeRef, amount)
+}
+
+func Payout(ctx context.Context, accountId string, amount int64, currency string) (string, error) {
+	return provider.Payout(ctx, accountId, amount, currency)
+}
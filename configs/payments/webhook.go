@@ -0,0 +1,74 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+/*
+   The provider tells us about things that happen on its side (refunds issued from its dashboard, chargebacks) by posting events to our webhook.
+
+   Every event is signed with the shared webhook secret: SignatureHeader holds the hex encoded HMAC-SHA256 of "<timestamp>.<body>" and
+   TimestampHeader holds the unix time it was sent at. Events older than maxEventAge are rejected so a captured request can't be replayed later.
+*/
+
+const (
+	SignatureHeader = "X-Payments-Signature"
+	TimestampHeader = "X-Payments-Timestamp"
+
+	EventChargeRefunded = "charge.refunded" // the charge or a part of it was returned to the customer
+	EventChargeDisputed = "charge.disputed" // the customer's bank took back the charge
+
+	maxEventAge = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+type Event struct {
+	Id        string `json:"id"`
+	Type      string `json:"type"`
+	ChargeRef string `json:"charge_ref"`
+	RefundRef string `json:"refund_ref"` // reference of the refund or dispute that returned the money
+	Amount    int64  `json:"amount"`     // how much of the charge was returned, 0 means all of it
+}
+
+// Returns the signature of a payload sent at timestamp
+func Sign(payload []byte, timestamp int64) string {
+	return hex.EncodeToString(signature(payload, timestamp))
+}
+
+// Verifies the signature and age of a webhook request and decodes its event
+func ParseEvent(payload []byte, sig, timestamp string) (Event, error) {
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return Event{}, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(sentAt, 0)); age > maxEventAge || age < -maxEventAge {
+		return Event{}, ErrInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(expected, signature(payload, sentAt)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+func signature(payload []byte, timestamp int64) []byte {
+	mac := hmac.New(sha256.New, webhookSecret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
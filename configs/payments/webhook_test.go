@@ -0,0 +1,40 @@
+package payments
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseEvent(t *testing.T) {
+	webhookSecret = []byte("secret")
+	payload := []byte(`{"id":"evt_1","type":"charge.refunded","charge_ref":"ch_1","refund_ref":"re_1","amount":200}`)
+	now := time.Now().Unix()
+
+	event, err := ParseEvent(payload, Sign(payload, now), strconv.FormatInt(now, 10))
+	if err != nil {
+		t.Fatalf("parse signed event: %v", err)
+	}
+	if event.Id != "evt_1" || event.Type != EventChargeRefunded || event.ChargeRef != "ch_1" || event.RefundRef != "re_1" || event.Amount != 200 {
+		t.Fatalf("parsed event is %+v", event)
+	}
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		sig       string
+		timestamp string
+	}{
+		{"tampered payload", []byte(`{"id":"evt_2"}`), Sign(payload, now), strconv.FormatInt(now, 10)},
+		{"other timestamp", payload, Sign(payload, now), strconv.FormatInt(now+1, 10)},
+		{"too old", payload, Sign(payload, now-int64(maxEventAge.Seconds())-1), strconv.FormatInt(now-int64(maxEventAge.Seconds())-1, 10)},
+		{"not hex", payload, "not hex", strconv.FormatInt(now, 10)},
+		{"missing timestamp", payload, Sign(payload, now), ""},
+	}
+	for _, test := range tests {
+		if _, err := ParseEvent(test.payload, test.sig, test.timestamp); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: got %v, want ErrInvalidSignature", test.name, err)
+		}
+	}
+}
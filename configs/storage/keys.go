@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+   Every object belongs to a profile and lives under that profile's prefix. This lets us find (and delete) everything a profile owns with one List call.
+
+   Layout:
+      profiles/<profile_id>/avatar/<file_name>
+      profiles/<profile_id>/posts/<post_id>/<file_name>
+      profiles/<profile_id>/uploads/<upload_id>/chunks/<offset>
+      profiles/<profile_id>/uploads/<upload_id>/<file_name>
+      profiles/<profile_id>/uploads/<upload_id>/renditions/<file_name>
+*/
+
+const ProfilesPrefix = "profiles/"
+
+// Key format:
+//  1. "profiles"
+//  2. profile_id of owner
+//  3. "avatar"
+//  4. name of the file
+func AvatarKey(profile_id, file_name string) string {
+	return ProfilesPrefix + profile_id + "/avatar/" + file_name
+}
+
+// Key format:
+//  1. "profiles"
+//  2. profile_id of the post owner
+//  3. "posts"
+//  4. post_id of the post the media belongs to
+//  5. name of the file
+func PostMediaKey(profile_id, post_id, file_name string) string {
+	return PostPrefix(profile_id, post_id) + file_name
+}
+
+// Key format:
+//  1. "profiles"
+//  2. profile_id of the uploader
+//  3. "uploads"
+//  4. upload_id of the upload
+//  5. "chunks"
+//  6. offset of the chunk in the file, zero padded so listing the chunks returns them in order
+func UploadChunkKey(profile_id, upload_id string, offset int64) string {
+	return UploadChunksPrefix(profile_id, upload_id) + fmt.Sprintf("%020d", offset)
+}
+
+// Key format:
+//  1. "profiles"
+//  2. profile_id of the uploader
+//  3. "uploads"
+//  4. upload_id of the upload
+//  5. name of the file the chunks were joined into
+func UploadKey(profile_id, upload_id, file_name string) string {
+	return UploadPrefix(profile_id, upload_id) + file_name
+}
+
+// Key format:
+//  1. "profiles"
+//  2. profile_id of the uploader
+//  3. "uploads"
+//  4. upload_id of the upload
+//  5. "renditions"
+//  6. name of the rendition with the extension of its format, e.g. thumbnail.jpg
+func UploadRenditionKey(profile_id, upload_id, file_name string) string {
+	return UploadPrefix(profile_id, upload_id) + "renditions/" + file_name
+}
+
+// Prefix of every object owned by a profile
+func ProfilePrefix(profile_id string) string {
+	return ProfilesPrefix + profile_id + "/"
+}
+
+// Prefix of every object attached to a post
+func PostPrefix(profile_id, post_id string) string {
+	return ProfilePrefix(profile_id) + "posts/" + post_id + "/"
+}
+
+// Prefix of every object of an upload
+func UploadPrefix(profile_id, upload_id string) string {
+	return ProfilePrefix(profile_id) + "uploads/" + upload_id + "/"
+}
+
+// Prefix of the chunks of an upload that hasn't been joined yet
+func UploadChunksPrefix(profile_id, upload_id string) string {
+	return UploadPrefix(profile_id, upload_id) + "chunks/"
+}
+
+// Returns the offset encoded in the key of an upload chunk. ok is false if the key isn't one.
+func ParseChunkKey(key string) (offset int64, ok bool) {
+	index := strings.LastIndex(key, "/chunks/")
+	if index == -1 {
+		return 0, false
+	}
+	offset, err := strconv.ParseInt(key[index+len("/chunks/"):], 10, 64)
+	return offset, err == nil
+}
+
+// Returns the upload_id encoded in a key. ok is false if the object doesn't belong to an upload.
+func ParseUploadKey(key string) (upload_id string, ok bool) {
+	if !strings.HasPrefix(key, ProfilesPrefix) {
+		return "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(key, ProfilesPrefix), "/")
+	if len(parts) < 4 || parts[1] != "uploads" || parts[2] == "" {
+		return "", false
+	}
+	return parts[2], true
+}
+
+// Returns the ids encoded in a key. post_id is empty if the object doesn't belong to a post. ok is false if the key doesn't follow the layout.
+func ParseKey(key string) (profile_id, post_id string, ok bool) {
+	if !strings.HasPrefix(key, ProfilesPrefix) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(key, ProfilesPrefix), "/")
+	if len(parts) < 3 || parts[0] == "" {
+		return "", "", false
+	}
+	if parts[1] == "posts" {
+		if len(parts) < 4 || parts[2] == "" {
+			return "", "", false
+		}
+		return parts[0], parts[2], true
+	}
+	return parts[0], "", true
+}
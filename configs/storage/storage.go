@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+)
+
+var (
+	store     Storage
+	publicUrl string
+	mountPath string // path of the public url, local storage is served under it
+
+	ErrNotFound = errors.New("object not found")
+)
+
+const (
+	storageQueryTimeout = 30 * time.Second
+)
+
+// A Storage is a place media files are kept. Objects are addressed by a key, see keys.go for the key layout.
+type Storage interface {
+	// Writes size bytes from body to key, replacing any existing object.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Opens the object at key. Returns ErrNotFound if it doesn't exist. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Deletes the object at key. Deleting a key that doesn't exist is not an error.
+	Delete(ctx context.Context, key string) error
+	// Lists every object whose key begins with prefix.
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+func Initialize() {
+	publicUrl = strings.TrimSuffix(configs.EnvStoragePublicUrl(), "/")
+
+	switch configs.EnvStorageDriver() {
+	case "local":
+		// the files are served by this server, a url without a path would put them in front of every GET route
+		if u, err := url.Parse(publicUrl); err == nil {
+			mountPath = strings.TrimSuffix(u.Path, "/")
+		}
+		if mountPath == "" {
+			log.Fatal("STORAGE_PUBLIC_URL must have a path for local storage, e.g. http://localhost:8080/media")
+		}
+		s, err := newLocalStorage(configs.EnvLocalStorageRoot())
+		if err != nil {
+			log.Fatalf("Error initializing local storage: %v", err)
+		}
+		store = s
+	case "s3":
+		endpoint, region, bucket := configs.EnvS3EndpointRegionBucket()
+		accessKey, secretKey := configs.EnvS3Keys()
+		s, err := newS3Storage(endpoint, region, bucket, accessKey, secretKey)
+		if err != nil {
+			log.Fatalf("Error initializing s3 storage: %v", err)
+		}
+		store = s
+	}
+
+	log.Println("Storage initialized...")
+}
+
+// Returns a new context with a timeout of 30 seconds
+func NewStorageContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), storageQueryTimeout)
+}
+
+func Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	return store.Put(ctx, key, body, size, contentType)
+}
+
+func Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return store.Get(ctx, key)
+}
+
+// Delete key(s) from storage. Stops at and returns the first error.
+func Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func List(ctx context.Context, prefix string) ([]Object, error) {
+	return store.List(ctx, prefix)
+}
+
+// Deletes every object whose key begins with prefix. Stops at and returns the first error.
+func DeletePrefix(ctx context.Context, prefix string) error {
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := store.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the public url of the object at key
+func URL(key string) string {
+	return publicUrl + "/" + key
+}
+
+// Returns the key of the object a url points to. ok is false if the url is not served by our storage.
+func KeyFromURL(objectUrl string) (key string, ok bool) {
+	if !strings.HasPrefix(objectUrl, publicUrl+"/") {
+		return "", false
+	}
+	key = strings.TrimPrefix(objectUrl, publicUrl+"/")
+	return key, key != ""
+}
+
+/*
+Serves the objects of the local storage root at the path of the public url. Does nothing for other drivers.
+
+The handlers run before an object is sent and decide who may fetch it, the key of the object is the "*" param.
+Only image, video and audio files are sent inline. Anything else is sent as a download so a stored file can never run as a page of our origin.
+*/
+func Serve(app *fiber.App, handlers ...fiber.Handler) {
+	local, ok := store.(*localStorage)
+	if !ok {
+		return
+	}
+	// the mime tables of the system don't always know the extensions uploads are stored with
+	mime.AddExtensionType(".mp4", "video/mp4")
+	mime.AddExtensionType(".mp3", "audio/mpeg")
+	app.Get(mountPath+"/*", append(handlers, func(c *fiber.Ctx) error {
+		key := c.Params("*")
+		if err := c.SendFile(local.path(key)); err != nil {
+			return err
+		}
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if !strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "video/") && !strings.HasPrefix(contentType, "audio/") {
+			c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+			c.Set(fiber.HeaderContentDisposition, "attachment")
+		}
+		return nil
+	})...)
+}
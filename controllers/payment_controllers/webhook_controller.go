@@ -0,0 +1,76 @@
+package paymentcontrollers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/cache"
+	"nerajima.com/NeraJima/configs/payments"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	processedEventExpiry = time.Hour * 24 * 7 // providers stop retrying an event well before this
+)
+
+/*
+Receives events from the payment provider. Requests that aren't signed with the webhook secret are rejected.
+
+Providers deliver an event again until they get a 2xx response, so an event is remembered once it has been handled and later deliveries are ignored.
+If handling fails the event is forgotten again and the error response makes the provider retry it.
+*/
+func HandleWebhook(c *fiber.Ctx) error {
+	event, err := payments.ParseEvent(c.Body(), c.Get(payments.SignatureHeader), c.Get(payments.TimestampHeader))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(responses.NewErrorResponse(fiber.StatusUnauthorized, &fiber.Map{"data": "Invalid signature."}, err))
+	}
+
+	if event.Id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	defer cacheCancel()
+	isNew, err := cache.SetIfNotExists(cacheCtx, cache.PaymentEventKey(event.Id), time.Now(), processedEventExpiry)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !isNew {
+		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Event has already been processed."}))
+	}
+
+	if err := handleEvent(event); err != nil {
+		cacheCtx2, cacheCancel2 := cache.NewCacheContext()
+		defer cacheCancel2()
+		cache.Delete(cacheCtx2, cache.PaymentEventKey(event.Id))
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Event has been processed."}))
+}
+
+// Refunds and disputes both return money of a charge so both reverse it in the ledger, which ends the subscription once its current period is refunded in full. Other events are ignored.
+func handleEvent(event payments.Event) error {
+	if event.Type != payments.EventChargeRefunded && event.Type != payments.EventChargeDisputed {
+		return nil
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var charge models.LedgerTransaction
+	if err := configs.Database.WithContext(dbCtx).Model(&models.LedgerTransaction{}).Find(&charge, "provider_ref = ? AND kind = ?", event.ChargeRef, models.LedgerKindCharge).Error; err != nil {
+		return err
+	}
+	if charge.Id == "" { // not one of our charges, there is nothing to reverse
+		return nil
+	}
+
+	amount := event.Amount
+	if amount <= 0 || event.Type == payments.EventChargeDisputed { // disputes always take back the whole charge
+		amount = charge.Amount
+	}
+	return utils.RecordRefund(charge, event.RefundRef, amount)
+}
@@ -0,0 +1,88 @@
+package postcontrollers
+
+import (
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/utils"
+)
+
+// Returns true if profileId is a private account and viewerId is not one of its approved followers. Owners can always see their own posts.
+func isHiddenByPrivacy(viewerId, profileId string) (bool, error) {
+	if viewerId == profileId {
+		return false, nil
+	}
+
+	query := "SELECT profiles.is_private AND NOT EXISTS (SELECT 1 FROM profile_followers WHERE profile_followers.profile_id = profiles.id AND profile_followers.follower_id = ? AND profile_followers.is_pending = false) "
+	query += "FROM profiles WHERE profiles.id = ?;"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var isHidden bool
+	if err := configs.Database.WithContext(dbCtx).Raw(query, viewerId, profileId).Scan(&isHidden).Error; err != nil {
+		return false, err
+	}
+	return isHidden, nil
+}
+
+// Returns true if the viewer and the owner of the post have blocked one another
+func isPostBlocked(viewerId, postId string) (bool, error) {
+	query := "SELECT COUNT(*) > 0 FROM posts WHERE posts.id = ? AND NOT " + utils.NotBlockedCondition("posts.profile_id") + ";"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var isBlocked bool
+	if err := configs.Database.WithContext(dbCtx).Raw(query, postId, viewerId, viewerId).Scan(&isBlocked).Error; err != nil {
+		return false, err
+	}
+	return isBlocked, nil
+}
+
+// Returns true if the viewer has a block with the commenter or with the owner of the post the comment was made on
+func isCommentBlocked(viewerId, commentId string) (bool, error) {
+	query := "SELECT COUNT(*) > 0 FROM comments JOIN posts ON posts.id = comments.post_id "
+	query += "WHERE comments.id = ? AND NOT (" + utils.NotBlockedCondition("comments.commenter_id") + " AND " + utils.NotBlockedCondition("posts.profile_id") + ");"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var isBlocked bool
+	if err := configs.Database.WithContext(dbCtx).Raw(query, commentId, viewerId, viewerId, viewerId, viewerId).Scan(&isBlocked).Error; err != nil {
+		return false, err
+	}
+	return isBlocked, nil
+}
+
+// Returns true if the viewer can see the post the comment was made on. Returns false if the comment does not exist.
+func canAccessCommentPost(viewerId, commentId string) (bool, error) {
+	query := "SELECT " + utils.PostAccessCondition() + " FROM comments JOIN posts ON posts.id = comments.post_id JOIN profiles ON profiles.id = posts.profile_id WHERE comments.id = ?;"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var canAccess bool
+	if err := configs.Database.WithContext(dbCtx).Raw(query, append(utils.PostAccessArgs(viewerId), commentId)...).Scan(&canAccess).Error; err != nil {
+		return false, err
+	}
+	return canAccess, nil
+}
+
+// Returns true if the viewer is an accepted subscriber of the owner of the post and their tier reaches the post's minimum tier
+func isSubscribedToPost(viewerId, postId string) (bool, error) {
+	query := "SELECT " + utils.SubscribedToPostCondition() + " FROM posts WHERE posts.id = ?;"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var isSubscribed bool
+	if err := configs.Database.WithContext(dbCtx).Raw(query, viewerId, postId).Scan(&isSubscribed).Error; err != nil {
+		return false, err
+	}
+	return isSubscribed, nil
+}
+
+// Returns true if the profile is a member of the audience
+func isAudienceMember(profileId, audienceId string) (bool, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var numMembers int64
+	if err := configs.Database.WithContext(dbCtx).Table("audience_members").Where("audience_id = ? AND profile_id = ?", audienceId, profileId).Count(&numMembers).Error; err != nil {
+		return false, err
+	}
+	return numMembers > 0, nil
+}
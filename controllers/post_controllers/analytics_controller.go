@@ -0,0 +1,57 @@
+package postcontrollers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+// Returns a time series of the activity on one of the request user's posts. Every period of the range is included, periods without activity are zero.
+func GetPostAnalytics(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	r, errMessage := utils.ParseAnalyticsRange(c.Query("interval"), c.Query("from"), c.Query("to"))
+	if errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	// Only the owner of the post can see its analytics
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var numPosts int64
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Post{}).Where("id = ? AND profile_id = ?", c.Params("postId"), reqProfile.Id).Count(&numPosts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if numPosts == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+	}
+
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var rows []responses.PostStats
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.PostDailyStat{}).
+		Select("date_trunc(?, day)::date AS period_start, SUM(likes) AS likes, SUM(dislikes) AS dislikes, SUM(bookmarks) AS bookmarks, SUM(comments) AS comments", r.Interval).
+		Where("post_id = ? AND day >= ? AND day <= ?", c.Params("postId"), r.From, r.To).
+		Group("period_start").
+		Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	rowsByPeriod := make(map[string]responses.PostStats, len(rows))
+	for _, row := range rows {
+		rowsByPeriod[row.PeriodStart.Format("2006-01-02")] = row
+	}
+	series := []responses.PostStats{}
+	for _, period := range r.Periods() {
+		row, ok := rowsByPeriod[period.Format("2006-01-02")]
+		if !ok {
+			row = responses.PostStats{}
+		}
+		row.PeriodStart = period
+		series = append(series, row)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": series}))
+}
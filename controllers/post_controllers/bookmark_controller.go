@@ -9,11 +9,28 @@ import (
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
 	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
 )
 
 func BookmarkPost(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	isBlocked, err := isPostBlocked(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	canAccess, err := utils.CanAccessPost(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
 	newBookmarkObj := models.PostBookmark{
 		PostId:    c.Params("postId"),
 		ProfileId: reqProfile.Id,
@@ -56,7 +73,7 @@ func GetBookmarkedPosts(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		query := "WITH media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio) ORDER BY position) AS media_data FROM post_media GROUP BY post_id), "
+		query := "WITH " + mediaAggCTE + ", "
 		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
 		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
 		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
@@ -82,13 +99,13 @@ func GetBookmarkedPosts(c *fiber.Ctx) error {
 		query += "LEFT JOIN post_likes pl ON posts.id = pl.post_id AND pl.profile_id = ? "
 		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
 
-		query += "WHERE post_bookmarks.profile_id = ? AND posts.is_archived = false "
+		query += "WHERE post_bookmarks.profile_id = ? AND posts.is_archived = false AND " + utils.PublishedCondition() + " AND " + utils.NotBlockedCondition("posts.profile_id") + " AND " + utils.InAudienceCondition() + " "
 		query += "ORDER BY post_bookmarks.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&bookmarkedPosts).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&bookmarkedPosts).Error
 	}()
 
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
@@ -105,6 +122,16 @@ func GetBookmarkedPosts(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addPostEntities(reqProfile.Id, bookmarkedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, bookmarkedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, bookmarkedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
@@ -1,6 +1,7 @@
 package postcontrollers
 
 import (
+	"errors"
 	"math"
 	"strings"
 	"sync"
@@ -8,11 +9,19 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
 	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
 )
 
+// A comment as returned by CreateComment, with its hashtags and mentions resolved for the request user
+type commentWithEntities struct {
+	models.Comment
+	Entities []responses.Entity `json:"entities"`
+}
+
 func CreateComment(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 	reqBody := struct {
@@ -35,6 +44,26 @@ func CreateComment(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Comment is too long."}, nil))
 	}
 
+	// Users can not comment on the posts of, or reply to, a profile they have a block with
+	isBlocked, err := isPostBlocked(reqProfile.Id, c.Params("postId"))
+	if err == nil && !isBlocked && reqBody.RepliesTo != nil {
+		isBlocked, err = isCommentBlocked(reqProfile.Id, *reqBody.RepliesTo)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	canAccess, err := utils.CanAccessPost(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
 	newComment := models.Comment{
 		PostId:             c.Params("postId"),
 		CommenterId:        reqProfile.Id,
@@ -42,13 +71,28 @@ func CreateComment(c *fiber.Ctx) error {
 		Body:               reqBody.Body,
 		IsEdited:           false,
 	}
+	var mentionedIds []string
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Model(&models.Comment{}).Create(&newComment).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Comment{}).Create(&newComment).Error; err != nil {
+			return err
+		}
+		var err error
+		mentionedIds, err = saveEntities(tx, reqProfile.Id, newComment.PostId, &newComment.Id, commentEntityFields(newComment.Body))
+		return err
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
+	utils.NotifyMentions(reqProfile, mentionedIds, newComment.PostId, true)
 
-	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": newComment}))
+	mentions, err := getMentions(reqProfile.Id, []string{newComment.Id}, true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	resObj := commentWithEntities{Comment: newComment, Entities: buildEntities(commentEntityFields(newComment.Body), mentions[newComment.Id])}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": resObj}))
 }
 
 func EditComment(c *fiber.Ctx) error {
@@ -72,12 +116,22 @@ func EditComment(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Comment is too long."}, nil))
 	}
 
-	// Update the fields
+	// Update the fields, keeping the replaced version as a revision, and the hashtags and mentions parsed from them
+	var comment models.Comment
+	var mentionedIds []string
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Model(&models.Comment{}).Where("id = ? AND commenter_id = ?", c.Params("commentId"), reqProfile.Id).Updates(map[string]interface{}{"is_edited": true, "body": reqBody.Body}).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		comment, mentionedIds, err = editCommentText(tx, reqProfile.Id, c.Params("commentId"), reqBody.Body)
+		return err
+	}); err != nil {
+		if errors.Is(err, errCommentNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Comment not found."}, nil))
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
+	utils.NotifyMentions(reqProfile, mentionedIds, comment.PostId, true)
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Comment has been successfully updated."}))
 }
@@ -113,6 +167,22 @@ func RemoveComment(c *fiber.Ctx) error {
 func LikeComment(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	isBlocked, err := isCommentBlocked(reqProfile.Id, c.Params("commentId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	canAccess, err := canAccessCommentPost(reqProfile.Id, c.Params("commentId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
 	// Delete the dislike object(if it exists)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
@@ -138,6 +208,22 @@ func LikeComment(c *fiber.Ctx) error {
 func DislikeComment(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	isBlocked, err := isCommentBlocked(reqProfile.Id, c.Params("commentId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	canAccess, err := canAccessCommentPost(reqProfile.Id, c.Params("commentId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
 	// Delete the like object(if it exists)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
@@ -194,6 +280,14 @@ func GetComments(c *fiber.Ctx) error {
 	var offset int = c.Locals("offset").(int)
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	canAccess, err := utils.CanAccessPost(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
 	// Run both queries concurrently to reduce response time
 	errChan := make(chan error, 1) // make this buffered so that the goroutine doesn't block
 	wg := new(sync.WaitGroup)
@@ -216,20 +310,20 @@ func GetComments(c *fiber.Ctx) error {
 		query += "LEFT JOIN comment_likes dl ON c.id = dl.comment_id AND dl.profile_id = ? "
 		query += "LEFT JOIN comment_dislikes dd ON c.id = dd.comment_id AND dd.profile_id = ? "
 
-		query += "WHERE c.post_id = ? AND c.comment_replied_to_id IS NULL "
+		query += "WHERE c.post_id = ? AND c.comment_replied_to_id IS NULL AND " + utils.NotBlockedCondition("c.commenter_id") + " AND " + utils.NotMutedCondition("c.commenter_id") + " AND " + utils.NoMutedKeywordCondition("c.body") + " "
 		query += "ORDER BY c.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, c.Params("postId"), limit, offset).Scan(&comments).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, c.Params("postId"), reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&comments).Error
 	}()
 
 	// Get total number of comments exlcuding replies
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
 	var numComments int64
-	if err := configs.Database.WithContext(dbCtx2).Table("comments").Where("post_id = ? AND comment_replied_to_id IS NULL", c.Params("postId")).Count(&numComments).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx2).Table("comments").Where("post_id = ? AND comment_replied_to_id IS NULL AND "+utils.NotBlockedCondition("comments.commenter_id")+" AND "+utils.NotMutedCondition("comments.commenter_id")+" AND "+utils.NoMutedKeywordCondition("comments.body"), c.Params("postId"), reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id).Count(&numComments).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -240,6 +334,10 @@ func GetComments(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addCommentEntities(reqProfile.Id, comments); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
@@ -256,6 +354,14 @@ func GetReplies(c *fiber.Ctx) error {
 	var offset int = c.Locals("offset").(int)
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	canAccess, err := canAccessCommentPost(reqProfile.Id, c.Params("commentId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
 	// Run both queries concurrently to reduce response time
 	errChan := make(chan error, 1) // make this buffered so that the goroutine doesn't block
 	wg := new(sync.WaitGroup)
@@ -278,20 +384,20 @@ func GetReplies(c *fiber.Ctx) error {
 		query += "LEFT JOIN comment_likes dl ON c.id = dl.comment_id AND dl.profile_id = ? "
 		query += "LEFT JOIN comment_dislikes dd ON c.id = dd.comment_id AND dd.profile_id = ? "
 
-		query += "WHERE c.comment_replied_to_id = ? "
+		query += "WHERE c.comment_replied_to_id = ? AND " + utils.NotBlockedCondition("c.commenter_id") + " AND " + utils.NotMutedCondition("c.commenter_id") + " AND " + utils.NoMutedKeywordCondition("c.body") + " "
 		query += "ORDER BY c.created_at ASC "
 		query += "LIMIT ? OFFSET ?;"
 
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, c.Params("commentId"), limit, offset).Scan(&replies).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, c.Params("commentId"), reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&replies).Error
 	}()
 
 	// Get total number of replies
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
 	var numReplies int64
-	if err := configs.Database.WithContext(dbCtx2).Table("comments").Where("comment_replied_to_id = ?", c.Params("commentId")).Count(&numReplies).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx2).Table("comments").Where("comment_replied_to_id = ? AND "+utils.NotBlockedCondition("comments.commenter_id")+" AND "+utils.NotMutedCondition("comments.commenter_id")+" AND "+utils.NoMutedKeywordCondition("comments.body"), c.Params("commentId"), reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id).Count(&numReplies).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -302,6 +408,10 @@ func GetReplies(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addCommentEntities(reqProfile.Id, replies); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
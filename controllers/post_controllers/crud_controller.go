@@ -2,53 +2,83 @@ package postcontrollers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
 	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
 )
 
+// A media entry points to its file by the upload_id of a completed upload. media_url is only read to turn away entries that still send it,
+// the file behind a url is never read so what kind of media it is can't be checked.
 type mediaBody struct {
-	MediaUrl string `json:"media_url"`
-	IsImage  *bool  `json:"is_image"`
-	IsVideo  *bool  `json:"is_video"`
-	IsAudio  *bool  `json:"is_audio"`
+	MediaUrl string  `json:"media_url"`
+	UploadId *string `json:"upload_id"`
+	IsImage  *bool   `json:"is_image"`
+	IsVideo  *bool   `json:"is_video"`
+	IsAudio  *bool   `json:"is_audio"`
+}
+
+// The fields of a new post. Drafts are saved with the same fields, any of which may still be missing.
+type postBody struct {
+	Title              string      `json:"title"`
+	Caption            string      `json:"caption"`
+	ForSubscribersOnly *bool       `json:"for_subscribers_only"`
+	IsArchived         *bool       `json:"is_archived"`
+	AudienceId         *string     `json:"audience_id"` // this is allowed to be nil
+	MinTierId          *string     `json:"min_tier_id"` // this is allowed to be nil
+	PublishAt          *time.Time  `json:"publish_at"`  // this is allowed to be nil, the post is published right away then
+	Media              []mediaBody `json:"media"`
+	Poll               *pollBody   `json:"poll"` // this is allowed to be nil, most posts don't have a poll
+}
+
+var errDraftNotFound = errors.New("draft not found")
+
+// Checks every media entry has all fields and exactly one type. The message is empty if all entries are valid.
+func validateMedia(media []mediaBody) string {
+	for index, mediaObj := range media {
+		if mediaObj.MediaUrl != "" {
+			return fmt.Sprintf("Media entry #%d has a media_url, media has to be uploaded and added by its upload_id.", index+1)
+		}
+		if mediaObj.UploadId == nil || mediaObj.IsImage == nil || mediaObj.IsAudio == nil || mediaObj.IsVideo == nil {
+			return fmt.Sprintf("Media entry #%d does not include all fields.", index+1)
+		}
+		// this if block reads "if mediaObj is not just an image AND its not just a video AND its not just an audio, then either all fields are false or more than one field is true"
+		if !(*mediaObj.IsImage && !*mediaObj.IsVideo && !*mediaObj.IsAudio) && !(!*mediaObj.IsImage && *mediaObj.IsVideo && !*mediaObj.IsAudio) && !(!*mediaObj.IsImage && !*mediaObj.IsVideo && *mediaObj.IsAudio) {
+			return fmt.Sprintf("One and only one of the following media object fields must be true: is_image, is_audio, is_video. Media entry #%d violates this.", index+1)
+		}
+	}
+	return ""
 }
 
 func CreatePost(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
-	reqBody := struct {
-		Title              string      `json:"title"`
-		Caption            string      `json:"caption"`
-		ForSubscribersOnly *bool       `json:"for_subscribers_only"`
-		IsArchived         *bool       `json:"is_archived"`
-		Media              []mediaBody `json:"media"`
-	}{}
+	var reqBody postBody
 
 	if err := c.BodyParser(&reqBody); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
 	}
 
+	return createPost(c, reqProfile, reqBody, nil)
+}
+
+// Validates reqBody and creates the post. When draftId is set the draft is deleted in the same transaction, so a draft can only be published once.
+func createPost(c *fiber.Ctx, reqProfile models.Profile, reqBody postBody, draftId *string) error {
 	if reqBody.Title == "" || reqBody.Caption == "" || reqBody.IsArchived == nil || reqBody.ForSubscribersOnly == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
 	}
 	if len(reqBody.Media) > 5 {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Max number of attachments is 5."}, nil))
 	}
-	for index, mediaObj := range reqBody.Media {
-		if mediaObj.MediaUrl == "" || mediaObj.IsImage == nil || mediaObj.IsAudio == nil || mediaObj.IsVideo == nil {
-			errMessage := fmt.Sprintf("Media entry #%d does not include all fields.", index+1)
-			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
-		}
-		// this if block reads "if mediaObj is not just an image AND its not just a video AND its not just an audio, then either all fields are false or more than one field is true"
-		if !(*mediaObj.IsImage && !*mediaObj.IsVideo && !*mediaObj.IsAudio) && !(!*mediaObj.IsImage && *mediaObj.IsVideo && !*mediaObj.IsAudio) && !(!*mediaObj.IsImage && !*mediaObj.IsVideo && *mediaObj.IsAudio) {
-			errMessage := fmt.Sprintf("One and only one of the following media object fields must be true: is_image, is_audio, is_video. Media entry #%d violates this.", index+1)
-			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
-		}
+	if errMessage := validateMedia(reqBody.Media); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
 	}
 
 	reqBody.Title = strings.TrimSpace(reqBody.Title)     // remove leading and trailing whitespace
@@ -63,16 +93,44 @@ func CreatePost(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Caption is too long."}, nil))
 	}
 
-	var postMedia = []models.PostMedia{}
-	if len(reqBody.Media) > 0 {
-		for index, mediaObj := range reqBody.Media {
-			postMedia = append(postMedia, models.PostMedia{
-				Position: index,
-				MediaUrl: mediaObj.MediaUrl,
-				IsImage:  *mediaObj.IsImage,
-				IsVideo:  *mediaObj.IsVideo,
-				IsAudio:  *mediaObj.IsAudio,
-			})
+	if reqBody.PublishAt != nil {
+		if errMessage := validatePublishAt(*reqBody.PublishAt); errMessage != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+		}
+	}
+	if reqBody.Poll != nil {
+		if errMessage := validatePoll(reqBody.Poll, reqBody.PublishAt); errMessage != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+		}
+	}
+
+	// Posts can only be limited to audiences owned by the poster
+	if reqBody.AudienceId != nil {
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		var numAudiences int64
+		if err := configs.Database.WithContext(dbCtx).Model(&models.Audience{}).Where("id = ? AND profile_id = ?", *reqBody.AudienceId, reqProfile.Id).Count(&numAudiences).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if numAudiences == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Audience not found."}, nil))
+		}
+	}
+
+	// A minimum tier only applies to subscriber only posts and has to be one of the poster's tiers
+	if reqBody.MinTierId != nil {
+		if !*reqBody.ForSubscribersOnly {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Only subscriber only posts can require a tier."}, nil))
+		}
+
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		var numTiers int64
+		if err := configs.Database.WithContext(dbCtx).Model(&models.SubscriptionTier{}).Where("id = ? AND profile_id = ?", *reqBody.MinTierId, reqProfile.Id).Count(&numTiers).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if numTiers == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier not found."}, nil))
 		}
 	}
 
@@ -82,13 +140,58 @@ func CreatePost(c *fiber.Ctx) error {
 		Caption:            reqBody.Caption,
 		ForSubscribersOnly: *reqBody.ForSubscribersOnly,
 		IsArchived:         *reqBody.IsArchived,
-		Media:              postMedia,
+		AudienceId:         reqBody.AudienceId,
+		MinTierId:          reqBody.MinTierId,
+		PublishAt:          reqBody.PublishAt,
 	}
+	var postMedia []models.PostMedia
+	var mentionedIds []string
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Model(&models.Post{}).Create(&newPost).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if draftId != nil {
+			result := tx.Delete(&models.PostDraft{}, "id = ? AND profile_id = ?", *draftId, reqProfile.Id)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 { // the draft was deleted or published in the meantime
+				return errDraftNotFound
+			}
+		}
+		if err := tx.Model(&models.Post{}).Create(&newPost).Error; err != nil {
+			return err
+		}
+		var err error
+		postMedia, err = newPostMedia(tx, reqProfile.Id, newPost.Id, 0, reqBody.Media)
+		if err != nil {
+			return err
+		}
+		if len(postMedia) > 0 {
+			if err := tx.Create(&postMedia).Error; err != nil {
+				return err
+			}
+		}
+		if reqBody.Poll != nil {
+			if err := newPoll(tx, newPost.Id, *reqBody.Poll); err != nil {
+				return err
+			}
+		}
+		mentionedIds, err = saveEntities(tx, reqProfile.Id, newPost.Id, nil, postEntityFields(newPost.Title, newPost.Caption))
+		return err
+	}); err != nil {
+		if errors.Is(err, errDraftNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Draft not found."}, nil))
+		}
+		if errors.Is(err, errUploadNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Upload not found."}, nil))
+		}
+		var entryErr mediaEntryError
+		if errors.As(err, &entryErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": entryErr.message}, nil))
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
+	utils.NotifyMentions(reqProfile, mentionedIds, newPost.Id, false)
 
 	marshaledMedia, _ := json.Marshal(postMedia)
 
@@ -109,13 +212,35 @@ func CreatePost(c *fiber.Ctx) error {
 		IsLiked:      false,
 		IsDisliked:   false,
 		IsBookmarked: false,
+		PublishAt:    newPost.PublishAt,
+	}
+	resPosts := []responses.Post{resObj}
+	if err := addPostEntities(reqProfile.Id, resPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, resPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, resPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
+	resObj = resPosts[0]
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": resObj,
 	}))
 }
 
+// A post as returned by GetPost, with its hashtags, mentions, poll and reposts resolved for the request user
+type postWithEntities struct {
+	models.Post
+	Entities   []responses.Entity `json:"entities"`
+	Poll       *responses.Poll    `json:"poll"`
+	RepostOf   *responses.Post    `json:"repost_of"` // nil if the post isn't a repost or the request user can't see the original
+	NumReposts int                `json:"num_reposts"`
+	IsReposted bool               `json:"is_reposted"`
+}
+
 func GetPost(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
@@ -128,12 +253,59 @@ func GetPost(c *fiber.Ctx) error {
 	if post.Id == "" { // Id field is empty => post does not exist
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This post does not exist."}, nil))
 	}
+	if post.PublishAt != nil && post.ProfileId != reqProfile.Id { // scheduled posts don't exist for anyone but their owner until they are published
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This post does not exist."}, nil))
+	}
+
+	mentions, err := getMentions(reqProfile.Id, []string{post.Id}, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	polls, err := getPolls(reqProfile.Id, []string{post.Id})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	resPosts := []responses.Post{{PostId: post.Id}}
+	if err := addPostReposts(reqProfile.Id, resPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	resObj := postWithEntities{Post: post, Entities: buildEntities(postEntityFields(post.Title, post.Caption), mentions[post.Id]), Poll: polls[post.Id]}
+	resObj.RepostOf, resObj.NumReposts, resObj.IsReposted = resPosts[0].RepostOf, resPosts[0].NumReposts, resPosts[0].IsReposted
+
+	// Profiles with a block between them can not see each others posts
+	if post.ProfileId != reqProfile.Id {
+		isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, post.ProfileId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if isBlocked {
+			return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+		}
+
+		// Posts limited to an audience can only be seen by its members
+		if post.AudienceId != nil {
+			isMember, err := isAudienceMember(reqProfile.Id, *post.AudienceId)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+			}
+			if !isMember {
+				return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+			}
+		}
+	}
 
 	// if request user is owner, return the post because its the owner
 	if post.ProfileId == reqProfile.Id {
-		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": post}))
-	} else if !post.IsArchived && !post.ForSubscribersOnly { // if post is not archived and is not hidden, return it
-		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": post}))
+		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": resObj}))
+	} else if !post.IsArchived && !post.ForSubscribersOnly { // if post is not archived and is not hidden, return it unless the owner is private and request user is not an approved follower
+		isHidden, err := isHiddenByPrivacy(reqProfile.Id, post.ProfileId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if isHidden {
+			return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "This account is private."}, nil))
+		}
+		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": resObj}))
 	}
 
 	/*
@@ -149,18 +321,26 @@ func GetPost(c *fiber.Ctx) error {
 
 	// At this point, we know post.IsArchived is false so post.ForSubscribersOnly must be true
 
-	// Check if request user is subscribed to post owner
+	// Check if request user is an accepted subscriber of post owner whose subscription hasn't expired
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
 	var subscriberObj models.ProfileSubscriber
-	if err := configs.Database.WithContext(dbCtx2).Table("profile_subscribers").Find(&subscriberObj, "profile_id = ? AND subscriber_id = ?", post.ProfileId, reqProfile.Id).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx2).Table("profile_subscribers").Where(utils.UnexpiredSubscriptionCondition()).Find(&subscriberObj, "profile_id = ? AND subscriber_id = ? AND is_accepted = ?", post.ProfileId, reqProfile.Id, true).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
-	if subscriberObj.ProfileId != "" && subscriberObj.SubscriberId != "" { // if both fields are populated, request user is subscribed to post owner
-		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": post}))
-	} else {
+	if subscriberObj.ProfileId == "" || subscriberObj.SubscriberId == "" { // if either field is empty, request user is not subscribed to post owner
 		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "This post is limited to subscribers only."}, nil))
 	}
+
+	// Check if the tier of the subscription reaches the minimum tier of the post
+	isSubscribed, err := isSubscribedToPost(reqProfile.Id, post.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !isSubscribed {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "This post is limited to a higher subscription tier."}, nil))
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": resObj}))
 }
 
 func EditPost(c *fiber.Ctx) error {
@@ -175,7 +355,9 @@ func EditPost(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
 	}
 
-	if reqBody.Title == "" || reqBody.Caption == "" || reqBody.IsArchived == nil {
+	// Leaving out both the title and the caption only archives or unarchives the post, which is the only edit reposts allow
+	isArchiveOnly := reqBody.Title == "" && reqBody.Caption == ""
+	if (!isArchiveOnly && (reqBody.Title == "" || reqBody.Caption == "")) || reqBody.IsArchived == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
 	}
 
@@ -191,12 +373,27 @@ func EditPost(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Caption is too long."}, nil))
 	}
 
-	// Update the fields
+	// Update the fields, keeping the replaced version as a revision, and the hashtags and mentions parsed from them
+	var mentionedIds []string
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Model(&models.Post{}).Where("id = ? AND profile_id = ?", c.Params("postId"), reqProfile.Id).Updates(map[string]interface{}{"title": reqBody.Title, "caption": reqBody.Caption, "is_archived": *reqBody.IsArchived}).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if isArchiveOnly {
+			return setPostArchived(tx, reqProfile.Id, c.Params("postId"), *reqBody.IsArchived)
+		}
+		var err error
+		mentionedIds, err = editPostText(tx, reqProfile.Id, c.Params("postId"), reqBody.Title, reqBody.Caption, reqBody.IsArchived)
+		return err
+	}); err != nil {
+		if errors.Is(err, errPostNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+		}
+		if errors.Is(err, errRepostNotEditable) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Reposts can only be archived or unarchived, leave out the title and caption."}, nil))
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
+	utils.NotifyMentions(reqProfile, mentionedIds, c.Params("postId"), false)
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Post has been successfully updated."}))
 }
@@ -204,10 +401,17 @@ func EditPost(c *fiber.Ctx) error {
 func DeletePost(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
-	var post models.Post
+	// Plain reposts of the post go with it, quotes are kept without the post they quoted
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Model(&models.Post{}).Delete(&post, "id = ? AND profile_id = ?", c.Params("postId"), reqProfile.Id).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		var post models.Post
+		result := tx.Model(&models.Post{}).Delete(&post, "id = ? AND profile_id = ?", c.Params("postId"), reqProfile.Id)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.Post{}).Delete(&models.Post{}, "repost_of_id = ? AND caption = ?", c.Params("postId"), "").Error
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -0,0 +1,277 @@
+package postcontrollers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+)
+
+const maxDrafts = 100 // number of drafts a profile can keep at once
+
+// Checks the parts of a draft that are already known. Only the limits no post could get past are enforced, so autosaves of unfinished work go through.
+func validateDraft(reqBody postBody) string {
+	if len(reqBody.Media) > 5 {
+		return "Max number of attachments is 5."
+	}
+	if uniseg.GraphemeClusterCount(strings.TrimSpace(reqBody.Title)) > 150 {
+		return "Title is too long."
+	}
+	if uniseg.GraphemeClusterCount(strings.TrimSpace(reqBody.Caption)) > 200 {
+		return "Caption is too long."
+	}
+	if reqBody.Poll != nil {
+		if len(reqBody.Poll.Options) > maxPollOptions {
+			return fmt.Sprintf("Polls can have at most %d options.", maxPollOptions)
+		}
+		for index, option := range reqBody.Poll.Options {
+			if uniseg.GraphemeClusterCount(strings.TrimSpace(option)) > maxPollOptionLength {
+				return fmt.Sprintf("Poll option #%d is too long.", index+1)
+			}
+		}
+	}
+	return ""
+}
+
+func draftPollFromBody(poll *pollBody) *models.DraftPoll {
+	if poll == nil {
+		return nil
+	}
+	return &models.DraftPoll{Options: poll.Options, AllowsMultiple: poll.AllowsMultiple, ClosesAt: poll.ClosesAt}
+}
+
+func draftMediaFromBody(media []mediaBody) []models.DraftMedia {
+	draftMedia := make([]models.DraftMedia, len(media))
+	for index, mediaObj := range media {
+		draftMedia[index] = models.DraftMedia{
+			Position: index,
+			MediaUrl: mediaObj.MediaUrl,
+			UploadId: mediaObj.UploadId,
+			IsImage:  mediaObj.IsImage,
+			IsVideo:  mediaObj.IsVideo,
+			IsAudio:  mediaObj.IsAudio,
+		}
+	}
+	return draftMedia
+}
+
+// Returns the draft of the profile with its media in order. The Id of the draft is empty if it doesn't exist.
+func getDraft(db *gorm.DB, profileId, draftId string) (models.PostDraft, error) {
+	var draft models.PostDraft
+	err := db.Model(&models.PostDraft{}).Preload("Media", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Find(&draft, "id = ? AND profile_id = ?", draftId, profileId).Error
+	return draft, err
+}
+
+func CreateDraft(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	var reqBody postBody
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional, a draft can start out empty
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+	if errMessage := validateDraft(reqBody); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var numDrafts int64
+	if err := configs.Database.WithContext(dbCtx).Model(&models.PostDraft{}).Where("profile_id = ?", reqProfile.Id).Count(&numDrafts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if numDrafts >= maxDrafts {
+		errMessage := fmt.Sprintf("You can have at most %d drafts.", maxDrafts)
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	newDraft := models.PostDraft{
+		ProfileId:          reqProfile.Id,
+		Title:              strings.TrimSpace(reqBody.Title),
+		Caption:            strings.TrimSpace(reqBody.Caption),
+		ForSubscribersOnly: reqBody.ForSubscribersOnly,
+		IsArchived:         reqBody.IsArchived,
+		AudienceId:         reqBody.AudienceId,
+		MinTierId:          reqBody.MinTierId,
+		PublishAt:          reqBody.PublishAt,
+		Media:              draftMediaFromBody(reqBody.Media),
+		Poll:               draftPollFromBody(reqBody.Poll),
+	}
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.PostDraft{}).Create(&newDraft).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": newDraft}))
+}
+
+// Replaces every field and attachment of the draft with the ones in the body. Clients autosave by sending the whole draft as it is on screen.
+func SaveDraft(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	var reqBody postBody
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional, an empty body clears the draft
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+	if errMessage := validateDraft(reqBody); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	var draft models.PostDraft
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PostDraft{}).Where("id = ? AND profile_id = ?", c.Params("draftId"), reqProfile.Id).Updates(map[string]interface{}{
+			"title":                strings.TrimSpace(reqBody.Title),
+			"caption":              strings.TrimSpace(reqBody.Caption),
+			"for_subscribers_only": reqBody.ForSubscribersOnly,
+			"is_archived":          reqBody.IsArchived,
+			"audience_id":          reqBody.AudienceId,
+			"min_tier_id":          reqBody.MinTierId,
+			"publish_at":           reqBody.PublishAt,
+			"poll":                 draftPollFromBody(reqBody.Poll),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errDraftNotFound
+		}
+
+		if err := tx.Where("post_draft_id = ?", c.Params("draftId")).Delete(&models.DraftMedia{}).Error; err != nil {
+			return err
+		}
+		if len(reqBody.Media) > 0 {
+			draftMedia := draftMediaFromBody(reqBody.Media)
+			for index := range draftMedia {
+				draftMedia[index].PostDraftId = c.Params("draftId")
+			}
+			if err := tx.Create(&draftMedia).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		draft, err = getDraft(tx, reqProfile.Id, c.Params("draftId"))
+		return err
+	}); err != nil {
+		if errors.Is(err, errDraftNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Draft not found."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": draft}))
+}
+
+func GetDraft(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	draft, err := getDraft(configs.Database.WithContext(dbCtx), reqProfile.Id, c.Params("draftId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if draft.Id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Draft not found."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": draft}))
+}
+
+// Returns the request user's drafts, the most recently saved first
+func GetDrafts(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var drafts = []models.PostDraft{}
+	if err := configs.Database.WithContext(dbCtx).Model(&models.PostDraft{}).Preload("Media", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("profile_id = ?", reqProfile.Id).Order("updated_at DESC").Limit(limit).Offset(offset).Find(&drafts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numDrafts int64
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.PostDraft{}).Where("profile_id = ?", reqProfile.Id).Count(&numDrafts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numDrafts) / float64(limit))),
+			"data":         drafts,
+		},
+	}))
+}
+
+func DeleteDraft(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var draft models.PostDraft
+	if err := configs.Database.WithContext(dbCtx).Model(&models.PostDraft{}).Delete(&draft, "id = ? AND profile_id = ?", c.Params("draftId"), reqProfile.Id).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "The draft was deleted successfully."}))
+}
+
+// Turns the draft into a post. The draft has to pass every check of CreatePost and is deleted once the post is created.
+func PublishDraft(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	draft, err := getDraft(configs.Database.WithContext(dbCtx), reqProfile.Id, c.Params("draftId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if draft.Id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Draft not found."}, nil))
+	}
+
+	media := make([]mediaBody, len(draft.Media))
+	for index, mediaObj := range draft.Media {
+		media[index] = mediaBody{
+			MediaUrl: mediaObj.MediaUrl,
+			UploadId: mediaObj.UploadId,
+			IsImage:  mediaObj.IsImage,
+			IsVideo:  mediaObj.IsVideo,
+			IsAudio:  mediaObj.IsAudio,
+		}
+	}
+	draftBody := postBody{
+		Title:              draft.Title,
+		Caption:            draft.Caption,
+		ForSubscribersOnly: draft.ForSubscribersOnly,
+		IsArchived:         draft.IsArchived,
+		AudienceId:         draft.AudienceId,
+		MinTierId:          draft.MinTierId,
+		PublishAt:          draft.PublishAt,
+		Media:              media,
+	}
+	if draft.Poll != nil {
+		draftBody.Poll = &pollBody{Options: draft.Poll.Options, AllowsMultiple: draft.Poll.AllowsMultiple, ClosesAt: draft.Poll.ClosesAt}
+	}
+
+	return createPost(c, reqProfile, draftBody, &draft.Id)
+}
@@ -0,0 +1,273 @@
+package postcontrollers
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+const maxMentionsPerText = 10 // number of different profiles that can be mentioned in one post or comment, later mentions stay plain text
+
+// A text field of a post or comment that hashtags and mentions are parsed from
+type entityField struct {
+	name string
+	text string
+}
+
+func postEntityFields(title, caption string) []entityField {
+	return []entityField{{name: models.MentionFieldTitle, text: title}, {name: models.MentionFieldCaption, text: caption}}
+}
+
+func commentEntityFields(body string) []entityField {
+	return []entityField{{name: models.MentionFieldBody, text: body}}
+}
+
+/*
+Saves the hashtags and mentions in the fields of a post, or of one of its comments when commentId is set, replacing the ones saved before.
+Mentions of usernames that don't exist or of profiles that have a block with the mentioner are left as plain text.
+
+Returns the ids of the profiles that are mentioned now but weren't before, so an edit doesn't notify the same profiles twice.
+*/
+func saveEntities(tx *gorm.DB, mentionerId, postId string, commentId *string, fields []entityField) ([]string, error) {
+	type fieldMention struct {
+		field  string
+		entity utils.TextEntity
+	}
+	tags, usernames := []string{}, []string{}
+	seenTags, seenUsernames := map[string]bool{}, map[string]bool{}
+	mentions := []fieldMention{}
+	for _, field := range fields {
+		for _, entity := range utils.ParseEntities(field.text) {
+			switch entity.Type {
+			case utils.EntityTypeHashtag:
+				if !seenTags[entity.Value] {
+					seenTags[entity.Value] = true
+					tags = append(tags, entity.Value)
+				}
+			case utils.EntityTypeMention:
+				if !seenUsernames[entity.Value] {
+					if len(usernames) == maxMentionsPerText {
+						continue
+					}
+					seenUsernames[entity.Value] = true
+					usernames = append(usernames, entity.Value)
+				}
+				mentions = append(mentions, fieldMention{field: field.name, entity: entity})
+			}
+		}
+	}
+
+	// Replace the hashtags
+	hashtagTable, ownerCondition, ownerArgs := "post_hashtags", "post_id = ?", []interface{}{postId}
+	mentionCondition := "post_id = ? AND comment_id IS NULL"
+	if commentId != nil {
+		hashtagTable, ownerCondition, ownerArgs = "comment_hashtags", "comment_id = ?", []interface{}{*commentId}
+		mentionCondition = "comment_id = ?"
+	}
+	if err := tx.Exec("DELETE FROM "+hashtagTable+" WHERE "+ownerCondition, ownerArgs...).Error; err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		hashtags := make([]models.Hashtag, len(tags))
+		for i, tag := range tags {
+			hashtags[i] = models.Hashtag{Name: tag}
+		}
+		if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(&hashtags).Error; err != nil {
+			return nil, err
+		}
+		// hashtags that already existed keep their id, so the ids are read back instead of taken from the created rows
+		var hashtagIds []string
+		if err := tx.Model(&models.Hashtag{}).Where("name IN ?", tags).Pluck("id", &hashtagIds).Error; err != nil {
+			return nil, err
+		}
+
+		var err error
+		if commentId == nil {
+			links := make([]models.PostHashtag, len(hashtagIds))
+			for i, hashtagId := range hashtagIds {
+				links[i] = models.PostHashtag{PostId: postId, HashtagId: hashtagId}
+			}
+			err = tx.Table("post_hashtags").Create(&links).Error
+		} else {
+			links := make([]models.CommentHashtag, len(hashtagIds))
+			for i, hashtagId := range hashtagIds {
+				links[i] = models.CommentHashtag{CommentId: *commentId, HashtagId: hashtagId}
+			}
+			err = tx.Table("comment_hashtags").Create(&links).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Replace the mentions
+	var previouslyMentionedIds []string
+	if err := tx.Model(&models.Mention{}).Where(mentionCondition, ownerArgs...).Distinct().Pluck("profile_id", &previouslyMentionedIds).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where(mentionCondition, ownerArgs...).Delete(&models.Mention{}).Error; err != nil {
+		return nil, err
+	}
+	if len(usernames) == 0 {
+		return []string{}, nil
+	}
+
+	var profiles []models.Profile
+	if err := tx.Model(&models.Profile{}).Select("id, username").Where("username IN ? AND "+utils.NotBlockedCondition("profiles.id"), usernames, mentionerId, mentionerId).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	profileIds := make(map[string]string, len(profiles)) // username to id
+	for _, profile := range profiles {
+		profileIds[profile.Username] = profile.Id
+	}
+
+	newMentions := []models.Mention{}
+	for _, mention := range mentions {
+		profileId, ok := profileIds[mention.entity.Value]
+		if !ok {
+			continue
+		}
+		newMentions = append(newMentions, models.Mention{
+			ProfileId:   profileId,
+			MentionerId: mentionerId,
+			PostId:      postId,
+			CommentId:   commentId,
+			Field:       mention.field,
+			StartIndex:  mention.entity.Start,
+			EndIndex:    mention.entity.End,
+		})
+	}
+	if len(newMentions) > 0 {
+		if err := tx.Create(&newMentions).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	wasMentioned := make(map[string]bool, len(previouslyMentionedIds))
+	for _, profileId := range previouslyMentionedIds {
+		wasMentioned[profileId] = true
+	}
+	newlyMentionedIds := []string{}
+	for _, username := range usernames {
+		profileId, ok := profileIds[username]
+		if ok && profileId != mentionerId && !wasMentioned[profileId] {
+			newlyMentionedIds = append(newlyMentionedIds, profileId)
+		}
+	}
+	return newlyMentionedIds, nil
+}
+
+// A saved mention joined with the mentioned profile
+type mentionRow struct {
+	PostId     string
+	CommentId  *string
+	Field      string
+	StartIndex int
+	EndIndex   int
+	ProfileId  string
+	Username   string
+	Name       string
+	MiniAvatar string
+}
+
+// Returns the mentions in the posts, or in the comments when isComment is true, keyed by post or comment id. Mentions of profiles that have a block with the viewer are left out.
+func getMentions(viewerId string, ids []string, isComment bool) (map[string][]mentionRow, error) {
+	ownerCondition := "mentions.post_id IN ? AND mentions.comment_id IS NULL"
+	if isComment {
+		ownerCondition = "mentions.comment_id IN ?"
+	}
+	query := "SELECT mentions.post_id, mentions.comment_id, mentions.field, mentions.start_index, mentions.end_index, "
+	query += "profiles.id AS profile_id, profiles.username, profiles.name, profiles.mini_avatar "
+	query += "FROM mentions JOIN profiles ON profiles.id = mentions.profile_id "
+	query += "WHERE " + ownerCondition + " AND " + utils.NotBlockedCondition("mentions.profile_id") + ";"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var rows []mentionRow
+	if err := configs.Database.WithContext(dbCtx).Raw(query, ids, viewerId, viewerId).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	mentions := make(map[string][]mentionRow, len(ids))
+	for _, row := range rows {
+		ownerId := row.PostId
+		if isComment {
+			ownerId = *row.CommentId
+		}
+		mentions[ownerId] = append(mentions[ownerId], row)
+	}
+	return mentions, nil
+}
+
+// Returns the hashtags and mentions in the fields in the order they appear. Hashtags are parsed again instead of being read back since they don't need resolving.
+func buildEntities(fields []entityField, mentions []mentionRow) []responses.Entity {
+	entities := []responses.Entity{}
+	for _, field := range fields {
+		fieldEntities := []responses.Entity{}
+		for _, entity := range utils.ParseEntities(field.text) {
+			if entity.Type == utils.EntityTypeHashtag {
+				fieldEntities = append(fieldEntities, responses.Entity{Type: entity.Type, Field: field.name, Start: entity.Start, End: entity.End, Tag: entity.Value})
+			}
+		}
+		for _, mention := range mentions {
+			if mention.Field != field.name {
+				continue
+			}
+			fieldEntities = append(fieldEntities, responses.Entity{
+				Type:    utils.EntityTypeMention,
+				Field:   field.name,
+				Start:   mention.StartIndex,
+				End:     mention.EndIndex,
+				Profile: &responses.MiniProfile{Id: mention.ProfileId, Username: mention.Username, Name: mention.Name, MiniAvatar: mention.MiniAvatar},
+			})
+		}
+		sort.Slice(fieldEntities, func(i, j int) bool { return fieldEntities[i].Start < fieldEntities[j].Start })
+		entities = append(entities, fieldEntities...)
+	}
+	return entities
+}
+
+// Sets the entities of every post for the viewer
+func addPostEntities(viewerId string, posts []responses.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	postIds := make([]string, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.PostId
+	}
+
+	mentions, err := getMentions(viewerId, postIds, false)
+	if err != nil {
+		return err
+	}
+	for i, post := range posts {
+		posts[i].Entities = buildEntities(postEntityFields(post.Title, post.Caption), mentions[post.PostId])
+	}
+	return nil
+}
+
+// Sets the entities of every comment for the viewer
+func addCommentEntities(viewerId string, comments []responses.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	commentIds := make([]string, len(comments))
+	for i, comment := range comments {
+		commentIds[i] = comment.CommentId
+	}
+
+	mentions, err := getMentions(viewerId, commentIds, true)
+	if err != nil {
+		return err
+	}
+	for i, comment := range comments {
+		comments[i].Entities = buildEntities(commentEntityFields(comment.Body), mentions[comment.CommentId])
+	}
+	return nil
+}
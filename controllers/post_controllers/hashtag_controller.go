@@ -0,0 +1,114 @@
+package postcontrollers
+
+import (
+	"math"
+	"net/url"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+// Returns the posts using a hashtag that the request user can see, newest first
+func GetHashtagPosts(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// hashtags can contain any letter, so the path parameter is percent encoded
+	rawTag, err := url.PathUnescape(c.Params("tag"))
+	tag, ok := utils.NormalizeHashtag(rawTag)
+	if err != nil || !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid hashtag."}, nil))
+	}
+
+	fromClause := "FROM posts "
+	fromClause += "JOIN profiles ON profiles.id = posts.profile_id "
+	fromClause += "JOIN post_hashtags ON post_hashtags.post_id = posts.id "
+	fromClause += "JOIN hashtags ON hashtags.id = post_hashtags.hashtag_id "
+	whereClause := "WHERE hashtags.name = ? AND " + utils.PostAccessCondition() + " AND " + utils.NotMutedCondition("posts.profile_id") + " AND " + utils.NoMutedKeywordCondition("posts.title", "posts.caption") + " "
+	whereArgs := append(append([]interface{}{tag}, utils.PostAccessArgs(reqProfile.Id)...), reqProfile.Id, reqProfile.Id)
+
+	// Run both queries concurrently to reduce response time
+	errChan := make(chan error, 1) // make this buffered so that the goroutine doesn't block
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	var hashtagPosts = []responses.Post{}
+	go func() {
+		defer wg.Done()
+
+		query := "WITH " + mediaAggCTE + ", "
+		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
+		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
+		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
+		query += "comments_agg AS (SELECT post_id, COUNT(*) AS comments FROM comments GROUP BY post_id) "
+
+		query += "SELECT "
+		query += "profiles.id AS profile_id, profiles.username AS profile_username, profiles.name AS profile_name, profiles.mini_avatar AS profile_mini_avatar, "
+		query += "posts.id AS post_id, posts.title AS post_title, posts.caption AS post_caption, posts.created_at AS created_at, "
+		query += "COALESCE(media_agg.media_data, '[]') AS media_data, "
+		query += "COALESCE(likes_agg.likes, 0) AS num_likes, COALESCE(dislikes_agg.dislikes, 0) AS num_dislikes, COALESCE(bookmarks_agg.bookmarks, 0) AS num_bookmarks, COALESCE(comments_agg.comments, 0) AS num_comments, "
+		query += "CASE WHEN pl.profile_id IS NOT NULL THEN true ELSE false END AS is_liked, "
+		query += "CASE WHEN pd.profile_id IS NOT NULL THEN true ELSE false END AS is_disliked, "
+		query += "CASE WHEN pb.profile_id IS NOT NULL THEN true ELSE false END AS is_bookmarked "
+
+		query += fromClause
+		query += "LEFT JOIN media_agg ON posts.id = media_agg.post_id "
+		query += "LEFT JOIN likes_agg ON posts.id = likes_agg.post_id "
+		query += "LEFT JOIN dislikes_agg ON posts.id = dislikes_agg.post_id "
+		query += "LEFT JOIN bookmarks_agg ON posts.id = bookmarks_agg.post_id "
+		query += "LEFT JOIN comments_agg ON posts.id = comments_agg.post_id "
+		query += "LEFT JOIN post_likes pl ON posts.id = pl.post_id AND pl.profile_id = ? "
+		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
+		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
+
+		query += whereClause
+		query += "ORDER BY posts.created_at DESC "
+		query += "LIMIT ? OFFSET ?;"
+
+		args := append([]interface{}{reqProfile.Id, reqProfile.Id, reqProfile.Id}, whereArgs...)
+		args = append(args, limit, offset)
+
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, args...).Scan(&hashtagPosts).Error
+	}()
+
+	// Get total number of posts using the hashtag
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numHashtagPosts int64
+	if err := configs.Database.WithContext(dbCtx2).Raw("SELECT COUNT(*) "+fromClause+whereClause+";", whereArgs...).Scan(&numHashtagPosts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	wg.Wait()
+
+	if err := <-errChan; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	close(errChan)
+
+	if err := addPostEntities(reqProfile.Id, hashtagPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, hashtagPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, hashtagPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numHashtagPosts) / float64(limit))),
+			"data":         hashtagPosts,
+		},
+	}))
+}
@@ -0,0 +1,372 @@
+package postcontrollers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/storage"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+var (
+	errTooManyMedia      = errors.New("too many media")
+	errInvalidPosition   = errors.New("invalid position")
+	errMediaNotFound     = errors.New("media not found")
+	errInvalidMediaOrder = errors.New("invalid media order")
+	errUploadNotFound    = errors.New("upload not found")
+)
+
+// The media of every post as a json array in order of position, joined into post queries as media_agg.media_data
+const mediaAggCTE = "media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio, 'format', format, 'size', size, 'width', width, 'height', height, 'duration', duration, 'video_codec', video_codec, 'audio_codec', audio_codec, 'thumbnail_url', thumbnail_url, 'medium_url', medium_url, 'full_url', full_url, 'blurhash', blurhash) ORDER BY position) AS media_data FROM post_media GROUP BY post_id)"
+
+// Limits on uploaded files, checked against what the server read from the file and not what the client claims
+const (
+	maxImageSize      = 20 << 20  // 20MB
+	maxAudioSize      = 200 << 20 // 200MB
+	maxVideoSize      = 1 << 30   // 1GB, the most an upload can be
+	maxImageDimension = 10000     // in pixels, for either side
+)
+
+// An upload that doesn't fit its media entry. The message is returned to the client as is.
+type mediaEntryError struct {
+	message string
+}
+
+func (e mediaEntryError) Error() string {
+	return e.message
+}
+
+// Checks the file of an uploaded media entry is the type of media the entry claims and within the size limits of that type
+func checkUploadedMedia(entry int, mediaObj mediaBody, metadata models.MediaMetadata) error {
+	kind := metadata.Kind()
+	if kind == "" {
+		return mediaEntryError{fmt.Sprintf("Media entry #%d is not a supported file type. Images must be JPEG, PNG or WebP, videos MP4 and audio MP3 or MP4.", entry)}
+	}
+	if (kind == models.MediaKindImage) != *mediaObj.IsImage || (kind == models.MediaKindVideo) != *mediaObj.IsVideo || (kind == models.MediaKindAudio) != *mediaObj.IsAudio {
+		return mediaEntryError{fmt.Sprintf("Media entry #%d is marked as the wrong type, its file is %s.", entry, kind)}
+	}
+
+	maxSize := map[string]int64{models.MediaKindImage: maxImageSize, models.MediaKindAudio: maxAudioSize, models.MediaKindVideo: maxVideoSize}[kind]
+	if metadata.Size > maxSize {
+		return mediaEntryError{fmt.Sprintf("Media entry #%d is too large, the max size of %s files is %dMB.", entry, kind, maxSize>>20)}
+	}
+	if metadata.Width > maxImageDimension || metadata.Height > maxImageDimension {
+		return mediaEntryError{fmt.Sprintf("Media entry #%d is too large, the max width and height is %d pixels.", entry, maxImageDimension)}
+	}
+	return nil
+}
+
+/*
+Locks the profile's post until the transaction ends, so concurrent edits of its media can't leave gaps or duplicates in the positions.
+Returns the media of the post ordered by position, errPostNotFound if the post doesn't exist or isn't the profile's, or errRepostNotEditable if it is a repost.
+*/
+func lockPostMedia(tx *gorm.DB, profileId, postId string) ([]models.PostMedia, error) {
+	var post models.Post
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Post{}).Select("id", "repost_of_id").Find(&post, "id = ? AND profile_id = ?", postId, profileId).Error; err != nil {
+		return nil, err
+	}
+	if post.Id == "" {
+		return nil, errPostNotFound
+	}
+	if post.RepostOfId != nil {
+		return nil, errRepostNotEditable
+	}
+
+	var media []models.PostMedia
+	if err := tx.Model(&models.PostMedia{}).Where("post_id = ?", postId).Order("position").Find(&media).Error; err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+/*
+Builds the media rows of entries added to a post at position. Every entry gets the url of its upload's file,
+and the upload is attached to the post so it no longer expires and can't be used for another post.
+
+Returns errUploadNotFound if an upload isn't the profile's, isn't complete, has expired or is attached already,
+and a mediaEntryError if the uploaded file doesn't fit its entry.
+*/
+func newPostMedia(tx *gorm.DB, profileId, postId string, position int, media []mediaBody) ([]models.PostMedia, error) {
+	postMedia := make([]models.PostMedia, len(media))
+	for index, mediaObj := range media {
+		result := tx.Model(&models.Upload{}).Where("id = ? AND profile_id = ? AND post_id IS NULL AND completed_at IS NOT NULL AND "+utils.UnexpiredUploadCondition(), *mediaObj.UploadId, profileId).Update("post_id", postId)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, errUploadNotFound
+		}
+		var upload models.Upload
+		if err := tx.Model(&models.Upload{}).Find(&upload, "id = ?", *mediaObj.UploadId).Error; err != nil {
+			return nil, err
+		}
+		if err := checkUploadedMedia(index+1, mediaObj, upload.MediaMetadata); err != nil {
+			return nil, err
+		}
+
+		postMedia[index] = models.PostMedia{
+			PostId:   postId,
+			Position: position + index,
+			MediaUrl: storage.URL(upload.Key),
+			IsImage:  *mediaObj.IsImage,
+			IsVideo:  *mediaObj.IsVideo,
+			IsAudio:  *mediaObj.IsAudio,
+
+			MediaMetadata:   upload.MediaMetadata,
+			MediaRenditions: upload.MediaRenditions,
+		}
+	}
+	return postMedia, nil
+}
+
+func getPostMedia(tx *gorm.DB, postId string) ([]models.PostMedia, error) {
+	var media = []models.PostMedia{}
+	err := tx.Model(&models.PostMedia{}).Where("post_id = ?", postId).Order("position").Find(&media).Error
+	return media, err
+}
+
+/*
+Deletes the stored files of media removed from a post. The media cleanup job only removes files of posts that no longer exist, so these have to be deleted here.
+
+Only files stored under the post or uploaded for it are deleted since a media url can point to any file, and files still used by one of the remaining media are kept.
+*/
+func deleteRemovedMediaFiles(profileId, postId string, removed, remaining []models.PostMedia) {
+	inUse := make(map[string]bool, len(remaining))
+	for _, mediaObj := range remaining {
+		inUse[mediaObj.MediaUrl] = true
+	}
+
+	keys, uploadPrefixes := []string{}, []string{}
+	for _, mediaObj := range removed {
+		if inUse[mediaObj.MediaUrl] {
+			continue
+		}
+		key, ok := storage.KeyFromURL(mediaObj.MediaUrl)
+		if !ok {
+			continue
+		}
+		if uploadId, isUpload := storage.ParseUploadKey(key); isUpload {
+			// the file of an upload goes with the upload, which can only be attached to one post
+			dbCtx, dbCancel := configs.NewQueryContext()
+			result := configs.Database.WithContext(dbCtx).Delete(&models.Upload{}, "id = ? AND post_id = ?", uploadId, postId)
+			dbCancel()
+			if result.Error != nil {
+				log.Printf("could not delete upload %s of post %s: %v", uploadId, postId, result.Error)
+			}
+			if result.RowsAffected > 0 { // its renditions are removed too
+				uploadPrefixes = append(uploadPrefixes, storage.UploadPrefix(profileId, uploadId))
+			}
+			continue
+		}
+		if strings.HasPrefix(key, storage.PostPrefix(profileId, postId)) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 && len(uploadPrefixes) == 0 {
+		return
+	}
+
+	ctx, cancel := storage.NewStorageContext()
+	defer cancel()
+	if err := storage.Delete(ctx, keys...); err != nil {
+		log.Printf("could not delete removed media files of post %s: %v", postId, err)
+	}
+	for _, prefix := range uploadPrefixes {
+		if err := storage.DeletePrefix(ctx, prefix); err != nil {
+			log.Printf("could not delete removed media files of post %s: %v", postId, err)
+		}
+	}
+}
+
+// Adds media to the request user's post, at the end unless a position is given
+func AddPostMedia(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		Media    []mediaBody `json:"media"`
+		Position *int        `json:"position"` // this is allowed to be nil
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if len(reqBody.Media) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+	if len(reqBody.Media) > 5 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Max number of attachments is 5."}, nil))
+	}
+	if errMessage := validateMedia(reqBody.Media); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	var media []models.PostMedia
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		currentMedia, err := lockPostMedia(tx, reqProfile.Id, c.Params("postId"))
+		if err != nil {
+			return err
+		}
+		if len(currentMedia)+len(reqBody.Media) > 5 {
+			return errTooManyMedia
+		}
+		position := len(currentMedia)
+		if reqBody.Position != nil {
+			if *reqBody.Position < 0 || *reqBody.Position > len(currentMedia) {
+				return errInvalidPosition
+			}
+			position = *reqBody.Position
+		}
+
+		// make room for the new media so positions stay contiguous
+		if err := tx.Model(&models.PostMedia{}).Where("post_id = ? AND position >= ?", c.Params("postId"), position).Update("position", gorm.Expr("position + ?", len(reqBody.Media))).Error; err != nil {
+			return err
+		}
+		newMedia, err := newPostMedia(tx, reqProfile.Id, c.Params("postId"), position, reqBody.Media)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&newMedia).Error; err != nil {
+			return err
+		}
+
+		media, err = getPostMedia(tx, c.Params("postId"))
+		return err
+	}); err != nil {
+		var entryErr mediaEntryError
+		switch {
+		case errors.Is(err, errPostNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+		case errors.Is(err, errRepostNotEditable):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Reposts cannot be edited."}, nil))
+		case errors.Is(err, errTooManyMedia):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Max number of attachments is 5."}, nil))
+		case errors.Is(err, errInvalidPosition):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid position."}, nil))
+		case errors.Is(err, errUploadNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Upload not found."}, nil))
+		case errors.As(err, &entryErr):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": entryErr.message}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": media}))
+}
+
+// Removes one media from the request user's post and deletes its file from storage
+func RemovePostMedia(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	var media []models.PostMedia
+	var removed models.PostMedia
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		currentMedia, err := lockPostMedia(tx, reqProfile.Id, c.Params("postId"))
+		if err != nil {
+			return err
+		}
+		for _, mediaObj := range currentMedia {
+			if mediaObj.Id == c.Params("mediaId") {
+				removed = mediaObj
+			}
+		}
+		if removed.Id == "" {
+			return errMediaNotFound
+		}
+
+		if err := tx.Delete(&models.PostMedia{}, "id = ?", removed.Id).Error; err != nil {
+			return err
+		}
+		// close the gap so positions stay contiguous
+		if err := tx.Model(&models.PostMedia{}).Where("post_id = ? AND position > ?", c.Params("postId"), removed.Position).Update("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+
+		media, err = getPostMedia(tx, c.Params("postId"))
+		return err
+	}); err != nil {
+		switch {
+		case errors.Is(err, errPostNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+		case errors.Is(err, errRepostNotEditable):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Reposts cannot be edited."}, nil))
+		case errors.Is(err, errMediaNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Media not found."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	go deleteRemovedMediaFiles(reqProfile.Id, removed.PostId, []models.PostMedia{removed}, media)
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": media}))
+}
+
+// Puts the media of the request user's post in the order of media_ids, which has to include every media of the post exactly once
+func ReorderPostMedia(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		MediaIds []string `json:"media_ids"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if len(reqBody.MediaIds) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	var media []models.PostMedia
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		currentMedia, err := lockPostMedia(tx, reqProfile.Id, c.Params("postId"))
+		if err != nil {
+			return err
+		}
+		if len(reqBody.MediaIds) != len(currentMedia) {
+			return errInvalidMediaOrder
+		}
+		isCurrent := make(map[string]bool, len(currentMedia))
+		for _, mediaObj := range currentMedia {
+			isCurrent[mediaObj.Id] = true
+		}
+		for _, mediaId := range reqBody.MediaIds {
+			if !isCurrent[mediaId] { // unknown or listed twice
+				return errInvalidMediaOrder
+			}
+			isCurrent[mediaId] = false
+		}
+
+		for position, mediaId := range reqBody.MediaIds {
+			if err := tx.Model(&models.PostMedia{}).Where("id = ?", mediaId).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+
+		media, err = getPostMedia(tx, c.Params("postId"))
+		return err
+	}); err != nil {
+		switch {
+		case errors.Is(err, errPostNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+		case errors.Is(err, errRepostNotEditable):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Reposts cannot be edited."}, nil))
+		case errors.Is(err, errInvalidMediaOrder):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "The new order must include every attachment of the post exactly once."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": media}))
+}
@@ -0,0 +1,358 @@
+package postcontrollers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 6
+	maxPollOptionLength = 80 // in grapheme clusters
+	minPollDuration     = time.Minute * 5
+	maxPollDuration     = time.Hour * 24 * 30
+)
+
+var (
+	errPollNotFound      = errors.New("poll not found")
+	errPollClosed        = errors.New("poll closed")
+	errOwnPoll           = errors.New("own poll")
+	errInvalidPollOption = errors.New("invalid poll option")
+)
+
+// The poll of a new post
+type pollBody struct {
+	Options        []string   `json:"options"`
+	AllowsMultiple *bool      `json:"allows_multiple"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+// Checks the poll of a post that goes live at publishAt, or right away when it is nil, and trims its options. The message is empty if the poll is valid.
+func validatePoll(poll *pollBody, publishAt *time.Time) string {
+	if poll.AllowsMultiple == nil || poll.ClosesAt == nil {
+		return "Please include all poll fields."
+	}
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return fmt.Sprintf("Polls must have between %d and %d options.", minPollOptions, maxPollOptions)
+	}
+	seen := make(map[string]bool, len(poll.Options))
+	for index, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return fmt.Sprintf("Poll option #%d is empty.", index+1)
+		}
+		if uniseg.GraphemeClusterCount(option) > maxPollOptionLength {
+			return fmt.Sprintf("Poll option #%d is too long.", index+1)
+		}
+		if seen[option] {
+			return fmt.Sprintf("Poll option #%d is the same as an earlier option.", index+1)
+		}
+		seen[option] = true
+		poll.Options[index] = option
+	}
+
+	opensAt := time.Now()
+	if publishAt != nil {
+		opensAt = *publishAt
+	}
+	if poll.ClosesAt.Before(opensAt.Add(minPollDuration)) {
+		return "Polls must stay open for at least 5 minutes after the post goes live."
+	}
+	if poll.ClosesAt.After(opensAt.Add(maxPollDuration)) {
+		return "Polls can stay open for at most 30 days after the post goes live."
+	}
+	return ""
+}
+
+// Creates the poll of a post along with its options
+func newPoll(tx *gorm.DB, postId string, poll pollBody) error {
+	newPoll := models.Poll{
+		PostId:         postId,
+		AllowsMultiple: *poll.AllowsMultiple,
+		ClosesAt:       *poll.ClosesAt,
+		Options:        make([]models.PollOption, len(poll.Options)),
+	}
+	for index, option := range poll.Options {
+		newPoll.Options[index] = models.PollOption{Position: index, Text: option}
+	}
+	return tx.Create(&newPoll).Error
+}
+
+/*
+Returns the polls of the posts that have one, by post id, as seen by the viewer.
+
+Vote counts are left out while the poll is open and the viewer has no vote in it, so they're hidden before a first vote and again
+after a retraction. Viewers who voted can still change or retract their vote after seeing the results, hiding them only keeps the
+results from being read without taking part. Owners always see the results of their own polls since they can't vote in them.
+*/
+func getPolls(viewerId string, postIds []string) (map[string]*responses.Poll, error) {
+	polls := map[string]*responses.Poll{}
+	if len(postIds) == 0 {
+		return polls, nil
+	}
+
+	type pollRow struct {
+		Id             string
+		PostId         string
+		AllowsMultiple bool
+		ClosesAt       time.Time
+		OwnerId        string
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var pollRows []pollRow
+	query := "SELECT polls.id, polls.post_id, polls.allows_multiple, polls.closes_at, posts.profile_id AS owner_id FROM polls JOIN posts ON posts.id = polls.post_id WHERE polls.post_id IN ?;"
+	if err := configs.Database.WithContext(dbCtx).Raw(query, postIds).Scan(&pollRows).Error; err != nil {
+		return nil, err
+	}
+	if len(pollRows) == 0 {
+		return polls, nil
+	}
+	pollIds := make([]string, len(pollRows))
+	for i, row := range pollRows {
+		pollIds[i] = row.Id
+	}
+
+	type optionRow struct {
+		Id       string
+		PollId   string
+		Text     string
+		NumVotes int
+	}
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var optionRows []optionRow
+	query = "SELECT poll_options.id, poll_options.poll_id, poll_options.text, COUNT(poll_votes.id) AS num_votes FROM poll_options LEFT JOIN poll_votes ON poll_votes.option_id = poll_options.id "
+	query += "WHERE poll_options.poll_id IN ? GROUP BY poll_options.id ORDER BY poll_options.position;"
+	if err := configs.Database.WithContext(dbCtx2).Raw(query, pollIds).Scan(&optionRows).Error; err != nil {
+		return nil, err
+	}
+
+	type voterRow struct {
+		PollId    string
+		NumVoters int
+	}
+	dbCtx3, dbCancel3 := configs.NewQueryContext()
+	defer dbCancel3()
+	var voterRows []voterRow
+	query = "SELECT poll_id, COUNT(DISTINCT profile_id) AS num_voters FROM poll_votes WHERE poll_id IN ? GROUP BY poll_id;"
+	if err := configs.Database.WithContext(dbCtx3).Raw(query, pollIds).Scan(&voterRows).Error; err != nil {
+		return nil, err
+	}
+	numVoters := make(map[string]int, len(voterRows))
+	for _, row := range voterRows {
+		numVoters[row.PollId] = row.NumVoters
+	}
+
+	dbCtx4, dbCancel4 := configs.NewQueryContext()
+	defer dbCancel4()
+	var viewerVotes []models.PollVote
+	if err := configs.Database.WithContext(dbCtx4).Model(&models.PollVote{}).Where("poll_id IN ? AND profile_id = ?", pollIds, viewerId).Find(&viewerVotes).Error; err != nil {
+		return nil, err
+	}
+	votedOptionIds := map[string][]string{}
+	for _, vote := range viewerVotes {
+		votedOptionIds[vote.PollId] = append(votedOptionIds[vote.PollId], vote.OptionId)
+	}
+
+	byPollId := make(map[string]*responses.Poll, len(pollRows))
+	for _, row := range pollRows {
+		poll := &responses.Poll{
+			PollId:         row.Id,
+			AllowsMultiple: row.AllowsMultiple,
+			ClosesAt:       row.ClosesAt,
+			IsClosed:       !time.Now().Before(row.ClosesAt),
+			Options:        []responses.PollOption{},
+			VotedOptionIds: votedOptionIds[row.Id],
+		}
+		if poll.VotedOptionIds == nil {
+			poll.VotedOptionIds = []string{}
+		}
+		if poll.IsClosed || len(poll.VotedOptionIds) > 0 || row.OwnerId == viewerId {
+			voters := numVoters[row.Id]
+			poll.NumVoters = &voters
+		}
+		polls[row.PostId] = poll
+		byPollId[row.Id] = poll
+	}
+	for _, row := range optionRows {
+		poll := byPollId[row.PollId]
+		option := responses.PollOption{OptionId: row.Id, Text: row.Text}
+		if poll.NumVoters != nil {
+			numVotes := row.NumVotes
+			option.NumVotes = &numVotes
+		}
+		poll.Options = append(poll.Options, option)
+	}
+	return polls, nil
+}
+
+// Sets the poll of every post that has one for the viewer
+func addPostPolls(viewerId string, posts []responses.Post) error {
+	postIds := make([]string, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.PostId
+	}
+
+	polls, err := getPolls(viewerId, postIds)
+	if err != nil {
+		return err
+	}
+	for i, post := range posts {
+		posts[i].Poll = polls[post.PostId]
+	}
+	return nil
+}
+
+// Locks the poll of the post until the transaction ends so votes of the same profile can't interleave. Returns errPollNotFound if the post has no poll.
+func lockPoll(tx *gorm.DB, postId string) (models.Poll, string, error) {
+	var poll models.Poll
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Poll{}).Find(&poll, "post_id = ?", postId).Error; err != nil {
+		return models.Poll{}, "", err
+	}
+	if poll.Id == "" {
+		return models.Poll{}, "", errPollNotFound
+	}
+	var ownerIds []string
+	if err := tx.Model(&models.Post{}).Where("id = ?", postId).Pluck("profile_id", &ownerIds).Error; err != nil {
+		return models.Poll{}, "", err
+	}
+	if len(ownerIds) == 0 {
+		return models.Poll{}, "", errPollNotFound
+	}
+	return poll, ownerIds[0], nil
+}
+
+/*
+Replaces the request user's vote in the poll of a post with the options in the body. Voting follows the visibility of the post,
+so only subscribers can vote in the poll of a subscriber only post. Owners can't vote in their own polls.
+*/
+func VotePoll(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		OptionIds []string `json:"option_ids"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+	optionIds := []string{}
+	seen := map[string]bool{}
+	for _, optionId := range reqBody.OptionIds {
+		if !seen[optionId] {
+			seen[optionId] = true
+			optionIds = append(optionIds, optionId)
+		}
+	}
+	if len(optionIds) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please choose at least one option."}, nil))
+	}
+
+	isBlocked, err := isPostBlocked(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	canAccess, err := utils.CanAccessPost(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		poll, ownerId, err := lockPoll(tx, c.Params("postId"))
+		if err != nil {
+			return err
+		}
+		if ownerId == reqProfile.Id {
+			return errOwnPoll
+		}
+		if !time.Now().Before(poll.ClosesAt) {
+			return errPollClosed
+		}
+		if len(optionIds) > 1 && !poll.AllowsMultiple {
+			return errInvalidPollOption
+		}
+
+		var numOptions int64
+		if err := tx.Model(&models.PollOption{}).Where("id IN ? AND poll_id = ?", optionIds, poll.Id).Count(&numOptions).Error; err != nil {
+			return err
+		}
+		if int(numOptions) != len(optionIds) {
+			return errInvalidPollOption
+		}
+
+		if err := tx.Delete(&models.PollVote{}, "poll_id = ? AND profile_id = ?", poll.Id, reqProfile.Id).Error; err != nil {
+			return err
+		}
+		votes := make([]models.PollVote, len(optionIds))
+		for i, optionId := range optionIds {
+			votes[i] = models.PollVote{PollId: poll.Id, OptionId: optionId, ProfileId: reqProfile.Id}
+		}
+		return tx.Create(&votes).Error
+	}); err != nil {
+		switch {
+		case errors.Is(err, errPollNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Poll not found."}, nil))
+		case errors.Is(err, errOwnPoll):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot vote in your own poll."}, nil))
+		case errors.Is(err, errPollClosed):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This poll has closed."}, nil))
+		case errors.Is(err, errInvalidPollOption):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid poll options."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	polls, err := getPolls(reqProfile.Id, []string{c.Params("postId")})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": polls[c.Params("postId")]}))
+}
+
+// Removes the request user's vote from the poll of a post, which hides its results from them again until they vote. Votes in closed polls are final.
+func RetractPollVote(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		poll, _, err := lockPoll(tx, c.Params("postId"))
+		if err != nil {
+			return err
+		}
+		if !time.Now().Before(poll.ClosesAt) {
+			return errPollClosed
+		}
+		return tx.Delete(&models.PollVote{}, "poll_id = ? AND profile_id = ?", poll.Id, reqProfile.Id).Error
+	}); err != nil {
+		switch {
+		case errors.Is(err, errPollNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Poll not found."}, nil))
+		case errors.Is(err, errPollClosed):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This poll has closed."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Vote has been retracted."}))
+}
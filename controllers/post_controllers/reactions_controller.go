@@ -9,11 +9,28 @@ import (
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
 	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
 )
 
 func LikePost(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	isBlocked, err := isPostBlocked(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	canAccess, err := utils.CanAccessPost(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
 	// Delete the dislike object(if it exists)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
@@ -39,6 +56,22 @@ func LikePost(c *fiber.Ctx) error {
 func DislikePost(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	isBlocked, err := isPostBlocked(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	canAccess, err := utils.CanAccessPost(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
 	// Delete the like object(if it exists)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
@@ -93,10 +126,20 @@ func GetLikesOfPost(c *fiber.Ctx) error {
 	var page int = c.Locals("page").(int)
 	var limit int = c.Locals("limit").(int)
 	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	canAccess, err := utils.CanAccessPost(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
 
 	query := configs.Database.Table("post_likes").
 		Select("profile.id, profile.username, profile.name, profile.mini_avatar").
 		Joins("JOIN profiles as profile ON post_likes.post_id = ? AND profile.id = post_likes.profile_id", c.Params("postId")).
+		Where(utils.NotBlockedCondition("profile.id"), reqProfile.Id, reqProfile.Id).
 		Order("post_likes.created_at DESC").
 		Limit(limit).Offset(offset)
 
@@ -111,7 +154,10 @@ func GetLikesOfPost(c *fiber.Ctx) error {
 	// Get total number of likes
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
-	numLikes := configs.Database.WithContext(dbCtx2).Model(&models.Post{}).Where("id = ?", c.Params("postId")).Association("Likes").Count()
+	var numLikes int64
+	if err := configs.Database.WithContext(dbCtx2).Table("post_likes").Where("post_id = ? AND "+utils.NotBlockedCondition("post_likes.profile_id"), c.Params("postId"), reqProfile.Id, reqProfile.Id).Count(&numLikes).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
@@ -127,10 +173,20 @@ func GetDislikesOfPost(c *fiber.Ctx) error {
 	var page int = c.Locals("page").(int)
 	var limit int = c.Locals("limit").(int)
 	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	canAccess, err := utils.CanAccessPost(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canAccess {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
 
 	query := configs.Database.Table("post_dislikes").
 		Select("profile.id, profile.username, profile.name, profile.mini_avatar").
 		Joins("JOIN profiles as profile ON post_dislikes.post_id = ? AND profile.id = post_dislikes.profile_id", c.Params("postId")).
+		Where(utils.NotBlockedCondition("profile.id"), reqProfile.Id, reqProfile.Id).
 		Order("post_dislikes.created_at DESC").
 		Limit(limit).Offset(offset)
 
@@ -145,7 +201,10 @@ func GetDislikesOfPost(c *fiber.Ctx) error {
 	// Get total number of dislikes
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
-	numDislikes := configs.Database.WithContext(dbCtx2).Model(&models.Post{}).Where("id = ?", c.Params("postId")).Association("Dislikes").Count()
+	var numDislikes int64
+	if err := configs.Database.WithContext(dbCtx2).Table("post_dislikes").Where("post_id = ? AND "+utils.NotBlockedCondition("post_dislikes.profile_id"), c.Params("postId"), reqProfile.Id, reqProfile.Id).Count(&numDislikes).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
@@ -171,7 +230,7 @@ func GetLikedPosts(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		query := "WITH media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio) ORDER BY position) AS media_data FROM post_media GROUP BY post_id), "
+		query := "WITH " + mediaAggCTE + ", "
 		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
 		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
 		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
@@ -197,13 +256,13 @@ func GetLikedPosts(c *fiber.Ctx) error {
 		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
 		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
 
-		query += "WHERE post_likes.profile_id = ? AND posts.is_archived = false "
+		query += "WHERE post_likes.profile_id = ? AND posts.is_archived = false AND " + utils.PublishedCondition() + " AND " + utils.NotBlockedCondition("posts.profile_id") + " AND " + utils.InAudienceCondition() + " "
 		query += "ORDER BY post_likes.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&likedPosts).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&likedPosts).Error
 	}()
 
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
@@ -220,6 +279,16 @@ func GetLikedPosts(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addPostEntities(reqProfile.Id, likedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, likedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, likedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
@@ -244,7 +313,7 @@ func GetDislikedPosts(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		query := "WITH media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio) ORDER BY position) AS media_data FROM post_media GROUP BY post_id), "
+		query := "WITH " + mediaAggCTE + ", "
 		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
 		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
 		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
@@ -270,13 +339,13 @@ func GetDislikedPosts(c *fiber.Ctx) error {
 		query += "LEFT JOIN post_likes pl ON posts.id = pl.post_id AND pl.profile_id = ? "
 		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
 
-		query += "WHERE post_dislikes.profile_id = ? AND posts.is_archived = false "
+		query += "WHERE post_dislikes.profile_id = ? AND posts.is_archived = false AND " + utils.PublishedCondition() + " AND " + utils.NotBlockedCondition("posts.profile_id") + " AND " + utils.InAudienceCondition() + " "
 		query += "ORDER BY post_dislikes.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&dislikedPosts).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&dislikedPosts).Error
 	}()
 
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
@@ -293,6 +362,16 @@ func GetDislikedPosts(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addPostEntities(reqProfile.Id, dislikedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, dislikedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, dislikedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
@@ -0,0 +1,355 @@
+package postcontrollers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+/*
+A repost is a post of the reposter that points to the original with RepostOfId. A plain repost has no text of its own and is shown as the original
+with attribution to the reposter, a quote adds a caption. Reposts never have a title, media or a poll, and they can't be edited.
+*/
+
+var (
+	errAlreadyReposted   = errors.New("post already reposted")
+	errRepostNotEditable = errors.New("reposts cannot be edited")
+)
+
+// A post that is about to be reposted, with the fields that decide whether it can be
+type repostTarget struct {
+	Id                 string
+	ProfileId          string
+	Caption            string
+	RepostOfId         *string
+	IsArchived         bool
+	ForSubscribersOnly bool
+	AudienceId         *string
+	PublishAt          *time.Time
+	IsPrivate          bool
+}
+
+// Returns the post with the privacy of its owner. The Id field is empty if the post does not exist.
+func getRepostTarget(postId string) (repostTarget, error) {
+	query := "SELECT posts.id, posts.profile_id, posts.caption, posts.repost_of_id, posts.is_archived, posts.for_subscribers_only, posts.audience_id, posts.publish_at, profiles.is_private "
+	query += "FROM posts JOIN profiles ON profiles.id = posts.profile_id WHERE posts.id = ?;"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var target repostTarget
+	if err := configs.Database.WithContext(dbCtx).Raw(query, postId).Scan(&target).Error; err != nil {
+		return repostTarget{}, err
+	}
+	return target, nil
+}
+
+/*
+Reposts a post to the request user's followers. With a caption the repost quotes the post, without one it shares the post as is.
+Reposting a plain repost reposts its original instead.
+
+Only posts that anyone can see can be reposted, so archived, subscriber only and audience posts are refused, as are posts of private
+accounts other than the request user's own. Profiles with a block between them can't repost each other's posts.
+*/
+func RepostPost(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		Caption string `json:"caption"`
+	}{}
+
+	if len(c.Body()) > 0 { // the body is optional, plain reposts don't have one
+		if err := c.BodyParser(&reqBody); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+		}
+	}
+
+	reqBody.Caption = strings.TrimSpace(reqBody.Caption) // remove leading and trailing whitespace
+	if uniseg.GraphemeClusterCount(reqBody.Caption) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Caption is too long."}, nil))
+	}
+
+	target, err := getRepostTarget(c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if target.Id != "" && target.RepostOfId != nil && target.Caption == "" { // a plain repost only shares its original
+		target, err = getRepostTarget(*target.RepostOfId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+	}
+	if target.Id == "" || (target.PublishAt != nil && target.ProfileId != reqProfile.Id) { // scheduled posts don't exist for anyone but their owner until they are published
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+	}
+	if target.PublishAt != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Scheduled posts cannot be reposted."}, nil))
+	}
+
+	isBlocked, err := isPostBlocked(reqProfile.Id, target.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	if target.IsArchived {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Archived posts cannot be reposted."}, nil))
+	}
+	if target.ForSubscribersOnly {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Subscriber only posts cannot be reposted."}, nil))
+	}
+	if target.AudienceId != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Posts limited to an audience cannot be reposted."}, nil))
+	}
+	if target.IsPrivate && target.ProfileId != reqProfile.Id {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Posts of private accounts cannot be reposted."}, nil))
+	}
+
+	newPost := models.Post{
+		ProfileId:  reqProfile.Id,
+		Caption:    reqBody.Caption,
+		RepostOfId: &target.Id,
+	}
+	var mentionedIds []string
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		// Lock the original so two plain reposts of the same profile can't both pass the check below
+		var original models.Post
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Post{}).Select("id").Find(&original, "id = ?", target.Id).Error; err != nil {
+			return err
+		}
+		if original.Id == "" {
+			return errPostNotFound
+		}
+
+		if newPost.Caption == "" {
+			var numReposts int64
+			if err := tx.Model(&models.Post{}).Where("repost_of_id = ? AND profile_id = ? AND caption = ?", target.Id, reqProfile.Id, "").Count(&numReposts).Error; err != nil {
+				return err
+			}
+			if numReposts > 0 {
+				return errAlreadyReposted
+			}
+		}
+
+		if err := tx.Model(&models.Post{}).Create(&newPost).Error; err != nil {
+			return err
+		}
+		var err error
+		mentionedIds, err = saveEntities(tx, reqProfile.Id, newPost.Id, nil, postEntityFields(newPost.Title, newPost.Caption))
+		return err
+	}); err != nil {
+		if errors.Is(err, errPostNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+		}
+		if errors.Is(err, errAlreadyReposted) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You have already reposted this post."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	utils.NotifyMentions(reqProfile, mentionedIds, newPost.Id, false)
+
+	resPosts := []responses.Post{{
+		PostId:     newPost.Id,
+		Title:      newPost.Title,
+		Caption:    newPost.Caption,
+		CreatedAt:  newPost.CreatedAt,
+		ProfileId:  newPost.ProfileId,
+		Username:   reqProfile.Username,
+		Name:       reqProfile.Name,
+		MiniAvatar: reqProfile.MiniAvatar,
+		Media:      json.RawMessage("[]"),
+	}}
+	if err := addPostEntities(reqProfile.Id, resPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, resPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": resPosts[0],
+	}))
+}
+
+// Removes the request user's plain repost of a post. Quotes are deleted like any other post.
+func RemoveRepost(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	result := configs.Database.WithContext(dbCtx).Model(&models.Post{}).Delete(&models.Post{}, "repost_of_id = ? AND profile_id = ? AND caption = ?", c.Params("postId"), reqProfile.Id, "")
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Repost not found."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Repost has been removed."}))
+}
+
+// What a post reposts and how it has been reposted, as seen by a viewer
+type repostInfo struct {
+	repostOfId *string
+	numReposts int
+	isReposted bool
+}
+
+// Returns the repost info of the posts by post id. Only published reposts are counted.
+func getRepostInfo(viewerId string, postIds []string) (map[string]repostInfo, error) {
+	infos := make(map[string]repostInfo, len(postIds))
+	if len(postIds) == 0 {
+		return infos, nil
+	}
+
+	type postRow struct {
+		Id         string
+		RepostOfId *string
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var postRows []postRow
+	if err := configs.Database.WithContext(dbCtx).Raw("SELECT id, repost_of_id FROM posts WHERE id IN ?;", postIds).Scan(&postRows).Error; err != nil {
+		return nil, err
+	}
+
+	type countRow struct {
+		RepostOfId string
+		NumReposts int
+	}
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var countRows []countRow
+	query := "SELECT posts.repost_of_id, COUNT(*) AS num_reposts FROM posts WHERE posts.repost_of_id IN ? AND " + utils.PublishedCondition() + " GROUP BY posts.repost_of_id;"
+	if err := configs.Database.WithContext(dbCtx2).Raw(query, postIds).Scan(&countRows).Error; err != nil {
+		return nil, err
+	}
+	numReposts := make(map[string]int, len(countRows))
+	for _, row := range countRows {
+		numReposts[row.RepostOfId] = row.NumReposts
+	}
+
+	dbCtx3, dbCancel3 := configs.NewQueryContext()
+	defer dbCancel3()
+	var repostedIds []string
+	if err := configs.Database.WithContext(dbCtx3).Model(&models.Post{}).Where("repost_of_id IN ? AND profile_id = ? AND caption = ?", postIds, viewerId, "").Pluck("repost_of_id", &repostedIds).Error; err != nil {
+		return nil, err
+	}
+	isReposted := make(map[string]bool, len(repostedIds))
+	for _, postId := range repostedIds {
+		isReposted[postId] = true
+	}
+
+	for _, row := range postRows {
+		infos[row.Id] = repostInfo{repostOfId: row.RepostOfId, numReposts: numReposts[row.Id], isReposted: isReposted[row.Id]}
+	}
+	return infos, nil
+}
+
+// Returns the posts the viewer can see out of postIds by post id, with their hashtags, mentions and polls
+func getAccessiblePosts(viewerId string, postIds []string) (map[string]*responses.Post, error) {
+	accessiblePosts := map[string]*responses.Post{}
+	if len(postIds) == 0 {
+		return accessiblePosts, nil
+	}
+
+	query := "WITH " + mediaAggCTE + ", "
+	query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
+	query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
+	query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
+	query += "comments_agg AS (SELECT post_id, COUNT(*) AS comments FROM comments GROUP BY post_id) "
+
+	query += "SELECT "
+	query += "profiles.id AS profile_id, profiles.username AS profile_username, profiles.name AS profile_name, profiles.mini_avatar AS profile_mini_avatar, "
+	query += "posts.id AS post_id, posts.title AS post_title, posts.caption AS post_caption, posts.created_at AS created_at, "
+	query += "COALESCE(media_agg.media_data, '[]') AS media_data, "
+	query += "COALESCE(likes_agg.likes, 0) AS num_likes, COALESCE(dislikes_agg.dislikes, 0) AS num_dislikes, COALESCE(bookmarks_agg.bookmarks, 0) AS num_bookmarks, COALESCE(comments_agg.comments, 0) AS num_comments, "
+	query += "CASE WHEN pl.profile_id IS NOT NULL THEN true ELSE false END AS is_liked, "
+	query += "CASE WHEN pd.profile_id IS NOT NULL THEN true ELSE false END AS is_disliked, "
+	query += "CASE WHEN pb.profile_id IS NOT NULL THEN true ELSE false END AS is_bookmarked "
+
+	query += "FROM posts "
+	query += "JOIN profiles ON profiles.id = posts.profile_id "
+	query += "LEFT JOIN media_agg ON posts.id = media_agg.post_id "
+	query += "LEFT JOIN likes_agg ON posts.id = likes_agg.post_id "
+	query += "LEFT JOIN dislikes_agg ON posts.id = dislikes_agg.post_id "
+	query += "LEFT JOIN bookmarks_agg ON posts.id = bookmarks_agg.post_id "
+	query += "LEFT JOIN comments_agg ON posts.id = comments_agg.post_id "
+	query += "LEFT JOIN post_likes pl ON posts.id = pl.post_id AND pl.profile_id = ? "
+	query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
+	query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
+
+	query += "WHERE posts.id IN ? AND " + utils.PostAccessCondition() + ";"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var posts []responses.Post
+	if err := configs.Database.WithContext(dbCtx).Raw(query, append([]interface{}{viewerId, viewerId, viewerId, postIds}, utils.PostAccessArgs(viewerId)...)...).Scan(&posts).Error; err != nil {
+		return nil, err
+	}
+	if err := addPostEntities(viewerId, posts); err != nil {
+		return nil, err
+	}
+	if err := addPostPolls(viewerId, posts); err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		accessiblePosts[posts[i].PostId] = &posts[i]
+	}
+	return accessiblePosts, nil
+}
+
+/*
+Sets the repost count of every post and whether the viewer reposted it. Reposts also get the post they repost, which is left nil
+when the viewer can't see it. The reposted posts get their own counts but not what they repost in turn, so quotes of quotes stay one level deep.
+*/
+func addPostReposts(viewerId string, posts []responses.Post) error {
+	postIds := make([]string, len(posts))
+	for i, post := range posts {
+		postIds[i] = post.PostId
+	}
+
+	infos, err := getRepostInfo(viewerId, postIds)
+	if err != nil {
+		return err
+	}
+	originalIds := []string{}
+	for _, info := range infos {
+		if info.repostOfId != nil {
+			originalIds = append(originalIds, *info.repostOfId)
+		}
+	}
+	originals, err := getAccessiblePosts(viewerId, originalIds)
+	if err != nil {
+		return err
+	}
+	originalInfos, err := getRepostInfo(viewerId, originalIds)
+	if err != nil {
+		return err
+	}
+	for _, original := range originals {
+		info := originalInfos[original.PostId]
+		original.RepostOfId, original.NumReposts, original.IsReposted = info.repostOfId, info.numReposts, info.isReposted
+	}
+
+	for i, post := range posts {
+		info := infos[post.PostId]
+		posts[i].RepostOfId, posts[i].NumReposts, posts[i].IsReposted = info.repostOfId, info.numReposts, info.isReposted
+		if info.repostOfId != nil {
+			posts[i].RepostOf = originals[*info.repostOfId]
+		}
+	}
+	return nil
+}
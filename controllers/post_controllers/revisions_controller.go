@@ -0,0 +1,240 @@
+package postcontrollers
+
+import (
+	"errors"
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+var (
+	errPostNotFound    = errors.New("post not found")
+	errCommentNotFound = errors.New("comment not found")
+)
+
+// Saves the version of a post or comment that an edit replaces. Nothing is saved when the edit leaves the text as it was.
+func saveRevision(tx *gorm.DB, postId string, commentId *string, oldFields, newFields []entityField) error {
+	revision := models.Revision{PostId: postId, CommentId: commentId, Diff: map[string][]models.DiffChange{}}
+	for i, field := range oldFields {
+		switch field.name {
+		case models.MentionFieldTitle:
+			revision.Title = field.text
+		case models.MentionFieldCaption:
+			revision.Caption = field.text
+		case models.MentionFieldBody:
+			revision.Body = field.text
+		}
+		if field.text != newFields[i].text {
+			revision.Diff[field.name] = utils.DiffWords(field.text, newFields[i].text)
+		}
+	}
+	if len(revision.Diff) == 0 {
+		return nil
+	}
+	return tx.Create(&revision).Error
+}
+
+// Archives or unarchives the profile's post without changing its text. Returns errPostNotFound if the post doesn't exist or isn't the profile's.
+func setPostArchived(tx *gorm.DB, profileId, postId string, isArchived bool) error {
+	result := tx.Model(&models.Post{}).Where("id = ? AND profile_id = ?", postId, profileId).Update("is_archived", isArchived)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errPostNotFound
+	}
+	return nil
+}
+
+/*
+Replaces the title and caption of the profile's post, along with whether it is archived if isArchived is set.
+The replaced version is saved as a revision and the hashtags and mentions are parsed again.
+
+Returns the ids of the newly mentioned profiles, errPostNotFound if the post doesn't exist or isn't the profile's, or errRepostNotEditable if it is a repost.
+*/
+func editPostText(tx *gorm.DB, profileId, postId, title, caption string, isArchived *bool) ([]string, error) {
+	var post models.Post
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Post{}).Find(&post, "id = ? AND profile_id = ?", postId, profileId).Error; err != nil {
+		return nil, err
+	}
+	if post.Id == "" {
+		return nil, errPostNotFound
+	}
+	if post.RepostOfId != nil {
+		return nil, errRepostNotEditable
+	}
+
+	updates := map[string]interface{}{"title": title, "caption": caption}
+	if isArchived != nil {
+		updates["is_archived"] = *isArchived
+	}
+	if err := tx.Model(&models.Post{}).Where("id = ?", post.Id).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := saveRevision(tx, post.Id, nil, postEntityFields(post.Title, post.Caption), postEntityFields(title, caption)); err != nil {
+		return nil, err
+	}
+	return saveEntities(tx, profileId, post.Id, nil, postEntityFields(title, caption))
+}
+
+/*
+Replaces the body of the profile's comment and marks it as edited. The replaced version is saved as a revision and the hashtags and mentions are parsed again.
+
+Returns the comment as it was before the edit and the ids of the newly mentioned profiles, or errCommentNotFound if the comment doesn't exist or isn't the profile's.
+*/
+func editCommentText(tx *gorm.DB, commenterId, commentId, body string) (models.Comment, []string, error) {
+	var comment models.Comment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Comment{}).Find(&comment, "id = ? AND commenter_id = ?", commentId, commenterId).Error; err != nil {
+		return comment, nil, err
+	}
+	if comment.Id == "" {
+		return comment, nil, errCommentNotFound
+	}
+
+	if err := tx.Model(&models.Comment{}).Where("id = ?", comment.Id).Updates(map[string]interface{}{"is_edited": true, "body": body}).Error; err != nil {
+		return comment, nil, err
+	}
+	if err := saveRevision(tx, comment.PostId, &comment.Id, commentEntityFields(comment.Body), commentEntityFields(body)); err != nil {
+		return comment, nil, err
+	}
+	mentionedIds, err := saveEntities(tx, commenterId, comment.PostId, &comment.Id, commentEntityFields(body))
+	return comment, mentionedIds, err
+}
+
+// Returns true if the viewer can read the edit history of the post. Its owner always can, everyone else only if they can see the post.
+func canReadHistory(viewerId, postId string) (bool, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var ownerIds []string
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Post{}).Where("id = ?", postId).Pluck("profile_id", &ownerIds).Error; err != nil {
+		return false, err
+	}
+	if len(ownerIds) == 0 {
+		return false, nil
+	}
+	if ownerIds[0] == viewerId {
+		return true, nil
+	}
+	return utils.CanAccessPost(viewerId, postId)
+}
+
+// Returns a page of the revisions matching the condition, the most recent edit first
+func getRevisions(c *fiber.Ctx, condition string, args ...interface{}) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var revisions = []models.Revision{}
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Revision{}).Where(condition, args...).Order("created_at DESC").Limit(limit).Offset(offset).Find(&revisions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numRevisions int64
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.Revision{}).Where(condition, args...).Count(&numRevisions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numRevisions) / float64(limit))),
+			"data":         revisions,
+		},
+	}))
+}
+
+func GetPostRevisions(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	canRead, err := canReadHistory(reqProfile.Id, c.Params("postId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canRead {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
+	return getRevisions(c, "post_id = ? AND comment_id IS NULL", c.Params("postId"))
+}
+
+func GetCommentRevisions(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var comment models.Comment
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Comment{}).Find(&comment, "id = ?", c.Params("commentId")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if comment.Id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Comment not found."}, nil))
+	}
+
+	canRead, err := canReadHistory(reqProfile.Id, comment.PostId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !canRead {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+	}
+
+	// Profiles with a block between them can not see each others comments
+	if comment.CommenterId != reqProfile.Id {
+		isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, comment.CommenterId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if isBlocked {
+			return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this comment."}, nil))
+		}
+	}
+
+	return getRevisions(c, "comment_id = ?", comment.Id)
+}
+
+// Owner puts an earlier version of their post or comment back. Restoring is an edit itself, so the version it replaces is kept as a new revision.
+func RestoreRevision(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var revision models.Revision
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Revision{}).Find(&revision, "id = ?", c.Params("revisionId")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if revision.Id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Revision not found."}, nil))
+	}
+
+	var mentionedIds []string
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if revision.CommentId == nil {
+			mentionedIds, err = editPostText(tx, reqProfile.Id, revision.PostId, revision.Title, revision.Caption, nil)
+		} else {
+			_, mentionedIds, err = editCommentText(tx, reqProfile.Id, *revision.CommentId, revision.Body)
+		}
+		return err
+	}); err != nil {
+		if errors.Is(err, errPostNotFound) || errors.Is(err, errCommentNotFound) { // only the owner can restore, to everyone else the revision doesn't exist
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Revision not found."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	utils.NotifyMentions(reqProfile, mentionedIds, revision.PostId, revision.CommentId != nil)
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "The revision has been restored."}))
+}
@@ -0,0 +1,183 @@
+package postcontrollers
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+)
+
+var (
+	errPollClosesTooSoon = errors.New("poll would close too soon after the post goes live")
+	errPollClosesTooLate = errors.New("poll would stay open too long after the post goes live")
+)
+
+const (
+	minScheduleDelay = time.Minute          // the publisher runs every minute, so a post scheduled any sooner might as well be posted right away
+	maxScheduleAhead = time.Hour * 24 * 365 // how far ahead a post can be scheduled
+)
+
+// Checks the time a post is scheduled for. The message is empty if publishAt is valid.
+func validatePublishAt(publishAt time.Time) string {
+	if publishAt.Before(time.Now().Add(minScheduleDelay)) {
+		return "Posts must be scheduled at least a minute ahead."
+	}
+	if publishAt.After(time.Now().Add(maxScheduleAhead)) {
+		return "Posts can be scheduled at most a year ahead."
+	}
+	return ""
+}
+
+// Returns the request user's scheduled posts, the one going live first comes first
+func GetScheduledPosts(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Run both queries concurrently to reduce response time
+	errChan := make(chan error, 1) // make this buffered so that the goroutine doesn't block
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	var scheduledPosts = []responses.Post{}
+	go func() {
+		defer wg.Done()
+
+		// Scheduled posts can't be reacted to or commented on yet, so only the media is aggregated
+		query := "WITH " + mediaAggCTE + " "
+
+		query += "SELECT "
+		query += "profiles.id AS profile_id, profiles.username AS profile_username, profiles.name AS profile_name, profiles.mini_avatar AS profile_mini_avatar, "
+		query += "posts.id AS post_id, posts.title AS post_title, posts.caption AS post_caption, posts.created_at AS created_at, posts.publish_at AS publish_at, "
+		query += "COALESCE(media_agg.media_data, '[]') AS media_data "
+
+		query += "FROM profiles "
+		query += "JOIN posts ON posts.profile_id = profiles.id "
+		query += "LEFT JOIN media_agg ON posts.id = media_agg.post_id "
+
+		query += "WHERE profiles.id = ? AND posts.publish_at IS NOT NULL "
+		query += "ORDER BY posts.publish_at ASC "
+		query += "LIMIT ? OFFSET ?;"
+
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, limit, offset).Scan(&scheduledPosts).Error
+	}()
+
+	// Get total number of scheduled posts
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numScheduledPosts int64
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.Post{}).Where("profile_id = ? AND publish_at IS NOT NULL", reqProfile.Id).Count(&numScheduledPosts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	wg.Wait()
+
+	if err := <-errChan; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	close(errChan)
+
+	if err := addPostEntities(reqProfile.Id, scheduledPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, scheduledPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, scheduledPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numScheduledPosts) / float64(limit))),
+			"data":         scheduledPosts,
+		},
+	}))
+}
+
+// Moves a scheduled post to another time. Posts that were already published can't be rescheduled.
+func ReschedulePost(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		PublishAt *time.Time `json:"publish_at"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if reqBody.PublishAt == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+	if errMessage := validatePublishAt(*reqBody.PublishAt); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	// The post is locked while its poll is checked, so the publisher can't make it live in the meantime and have it pulled back.
+	// The poll of a post can't be changed, so the post can only move to when its poll stays open for as long as a new poll could.
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		var post models.Post
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Post{}).Select("id").Find(&post, "id = ? AND profile_id = ? AND publish_at IS NOT NULL", c.Params("postId"), reqProfile.Id).Error; err != nil {
+			return err
+		}
+		if post.Id == "" {
+			return errPostNotFound
+		}
+
+		var pollClosesAt []time.Time
+		if err := tx.Model(&models.Poll{}).Where("post_id = ?", post.Id).Pluck("closes_at", &pollClosesAt).Error; err != nil {
+			return err
+		}
+		if len(pollClosesAt) > 0 && pollClosesAt[0].Before(reqBody.PublishAt.Add(minPollDuration)) {
+			return errPollClosesTooSoon
+		}
+		if len(pollClosesAt) > 0 && pollClosesAt[0].After(reqBody.PublishAt.Add(maxPollDuration)) {
+			return errPollClosesTooLate
+		}
+
+		return tx.Model(&models.Post{}).Where("id = ?", post.Id).Update("publish_at", *reqBody.PublishAt).Error
+	}); err != nil {
+		switch {
+		case errors.Is(err, errPostNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+		case errors.Is(err, errPollClosesTooSoon):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "The poll of this post would close less than 5 minutes after it goes live."}, nil))
+		case errors.Is(err, errPollClosesTooLate):
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "The poll of this post would stay open for more than 30 days after it goes live."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Post has been rescheduled."}))
+}
+
+// Deletes a scheduled post before it goes live. Posts that were already published have to be deleted with DeletePost.
+func CancelScheduledPost(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var post models.Post
+	result := configs.Database.WithContext(dbCtx).Model(&models.Post{}).Delete(&post, "id = ? AND profile_id = ? AND publish_at IS NOT NULL", c.Params("postId"), reqProfile.Id)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Post not found."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Scheduled post has been cancelled."}))
+}
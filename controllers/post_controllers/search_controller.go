@@ -0,0 +1,150 @@
+package postcontrollers
+
+import (
+	"html"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rivo/uniseg"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	maxPostSearchLength = 100
+
+	// ts_headline wraps the matched words in these, they are swapped for <mark> tags once the rest of the text is html escaped
+	highlightStartSel = "\x02"
+	highlightStopSel  = "\x03"
+)
+
+var (
+	titleHeadlineOptions   = `StartSel="` + highlightStartSel + `", StopSel="` + highlightStopSel + `", HighlightAll=true`
+	captionHeadlineOptions = `StartSel="` + highlightStartSel + `", StopSel="` + highlightStopSel + `", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
+)
+
+// Returns the text of a headline made by ts_headline html escaped and with the matched words wrapped in <mark> tags
+func markHighlights(headline string) string {
+	headline = html.EscapeString(headline)
+	headline = strings.ReplaceAll(headline, highlightStartSel, "<mark>")
+	return strings.ReplaceAll(headline, highlightStopSel, "</mark>")
+}
+
+/*
+Searches the titles and captions of the posts the request user can see, the same posts GetPost would return to them. Posts of muted
+profiles or with muted keywords are left out like in the feeds.
+
+The q parameter supports the web search syntax of postgres: "quoted phrases", OR and -excluded words. Results are ranked by relevance
+with matches in the title counting more than ones in the caption, and come with highlighted snippets.
+*/
+func SearchPosts(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	searchQuery := strings.TrimSpace(c.Query("q"))
+	if searchQuery == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+	if uniseg.GraphemeClusterCount(searchQuery) > maxPostSearchLength {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Search is too long."}, nil))
+	}
+
+	searchClause := "search AS (SELECT websearch_to_tsquery('simple', ?) AS query) "
+	fromClause := "FROM posts "
+	fromClause += "JOIN profiles ON profiles.id = posts.profile_id "
+	fromClause += "CROSS JOIN search "
+	whereClause := "WHERE posts.search_vector @@ search.query AND " + utils.PostAccessCondition() + " AND " + utils.NotMutedCondition("posts.profile_id") + " AND " + utils.NoMutedKeywordCondition("posts.title", "posts.caption") + " "
+	whereArgs := append(utils.PostAccessArgs(reqProfile.Id), reqProfile.Id, reqProfile.Id)
+
+	// Run both queries concurrently to reduce response time
+	errChan := make(chan error, 1) // make this buffered so that the goroutine doesn't block
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	var foundPosts = []responses.Post{}
+	go func() {
+		defer wg.Done()
+
+		query := "WITH " + searchClause + ", "
+		query += mediaAggCTE + ", "
+		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
+		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
+		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
+		query += "comments_agg AS (SELECT post_id, COUNT(*) AS comments FROM comments GROUP BY post_id) "
+
+		query += "SELECT "
+		query += "profiles.id AS profile_id, profiles.username AS profile_username, profiles.name AS profile_name, profiles.mini_avatar AS profile_mini_avatar, "
+		query += "posts.id AS post_id, posts.title AS post_title, posts.caption AS post_caption, posts.created_at AS created_at, "
+		query += "COALESCE(media_agg.media_data, '[]') AS media_data, "
+		query += "COALESCE(likes_agg.likes, 0) AS num_likes, COALESCE(dislikes_agg.dislikes, 0) AS num_dislikes, COALESCE(bookmarks_agg.bookmarks, 0) AS num_bookmarks, COALESCE(comments_agg.comments, 0) AS num_comments, "
+		query += "CASE WHEN pl.profile_id IS NOT NULL THEN true ELSE false END AS is_liked, "
+		query += "CASE WHEN pd.profile_id IS NOT NULL THEN true ELSE false END AS is_disliked, "
+		query += "CASE WHEN pb.profile_id IS NOT NULL THEN true ELSE false END AS is_bookmarked, "
+		query += "ts_headline('simple', posts.title, search.query, ?) AS title_highlight, "
+		query += "ts_headline('simple', posts.caption, search.query, ?) AS caption_highlight "
+
+		query += fromClause
+		query += "LEFT JOIN media_agg ON posts.id = media_agg.post_id "
+		query += "LEFT JOIN likes_agg ON posts.id = likes_agg.post_id "
+		query += "LEFT JOIN dislikes_agg ON posts.id = dislikes_agg.post_id "
+		query += "LEFT JOIN bookmarks_agg ON posts.id = bookmarks_agg.post_id "
+		query += "LEFT JOIN comments_agg ON posts.id = comments_agg.post_id "
+		query += "LEFT JOIN post_likes pl ON posts.id = pl.post_id AND pl.profile_id = ? "
+		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
+		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
+
+		query += whereClause
+		query += "ORDER BY ts_rank(posts.search_vector, search.query) DESC, posts.created_at DESC "
+		query += "LIMIT ? OFFSET ?;"
+
+		args := []interface{}{searchQuery, titleHeadlineOptions, captionHeadlineOptions, reqProfile.Id, reqProfile.Id, reqProfile.Id}
+		args = append(append(args, whereArgs...), limit, offset)
+
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, args...).Scan(&foundPosts).Error
+	}()
+
+	// Get total number of matching posts
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numFoundPosts int64
+	if err := configs.Database.WithContext(dbCtx2).Raw("WITH "+searchClause+"SELECT COUNT(*) "+fromClause+whereClause+";", append([]interface{}{searchQuery}, whereArgs...)...).Scan(&numFoundPosts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	wg.Wait()
+
+	if err := <-errChan; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	close(errChan)
+
+	for i := range foundPosts {
+		foundPosts[i].TitleHighlight = markHighlights(foundPosts[i].TitleHighlight)
+		foundPosts[i].CaptionHighlight = markHighlights(foundPosts[i].CaptionHighlight)
+	}
+	if err := addPostEntities(reqProfile.Id, foundPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, foundPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, foundPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numFoundPosts) / float64(limit))),
+			"data":         foundPosts,
+		},
+	}))
+}
@@ -8,8 +8,16 @@ import (
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
 	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
 )
 
+// Leaves a plain repost out of the following feed when the post it reposts is in the feed as well, since the viewer follows its author too.
+// The placeholder takes the viewer's profile id.
+const repostOfFollowedCondition = "(posts.repost_of_id IS NULL OR posts.caption <> '' OR NOT EXISTS (" +
+	"SELECT 1 FROM posts AS originals JOIN profile_followers AS original_followers ON original_followers.profile_id = originals.profile_id " +
+	"WHERE originals.id = posts.repost_of_id AND original_followers.follower_id = ? AND original_followers.is_pending = false " +
+	"AND originals.is_archived = false AND originals.publish_at IS NULL AND originals.for_subscribers_only = false))"
+
 func GetFollowingsFeed(c *fiber.Ctx) error {
 	var page int = c.Locals("page").(int)
 	var limit int = c.Locals("limit").(int)
@@ -24,7 +32,7 @@ func GetFollowingsFeed(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		query := "WITH media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio) ORDER BY position) AS media_data FROM post_media GROUP BY post_id), "
+		query := "WITH " + mediaAggCTE + ", "
 		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
 		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
 		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
@@ -51,20 +59,22 @@ func GetFollowingsFeed(c *fiber.Ctx) error {
 		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
 		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
 
-		query += "WHERE profile_followers.follower_id = ? AND posts.is_archived = false AND posts.for_subscribers_only = false "
+		query += "WHERE profile_followers.follower_id = ? AND profile_followers.is_pending = false AND posts.is_archived = false AND " + utils.PublishedCondition() + " AND posts.for_subscribers_only = false AND " + utils.NotBlockedCondition("posts.profile_id") + " AND " + utils.InAudienceCondition() + " AND " + utils.NotMutedCondition("posts.profile_id") + " AND " + utils.NoMutedKeywordCondition("posts.title", "posts.caption") + " AND " + utils.RepostAccessCondition() + " AND " + utils.RepostNotMutedCondition() + " AND " + repostOfFollowedCondition + " "
 		query += "ORDER BY posts.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
+		queryArgs := append([]interface{}{reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id}, utils.PostAccessArgs(reqProfile.Id)...)
+		queryArgs = append(queryArgs, reqProfile.Id, reqProfile.Id, reqProfile.Id)
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&feedPosts).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, append(queryArgs, limit, offset)...).Scan(&feedPosts).Error
 	}()
 
 	// Get total number of posts in following feed
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
 	var numFeedPosts int64
-	if err := configs.Database.WithContext(dbCtx2).Table("posts").Where("profile_id IN (SELECT profile_id FROM profile_followers WHERE follower_id = ?) AND is_archived = ? AND for_subscribers_only = ?", reqProfile.Id, false, false).Count(&numFeedPosts).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx2).Table("posts").Where("profile_id IN (SELECT profile_id FROM profile_followers WHERE follower_id = ? AND is_pending = false) AND is_archived = ? AND "+utils.PublishedCondition()+" AND for_subscribers_only = ? AND "+utils.NotBlockedCondition("posts.profile_id")+" AND "+utils.InAudienceCondition()+" AND "+utils.NotMutedCondition("posts.profile_id")+" AND "+utils.NoMutedKeywordCondition("posts.title", "posts.caption")+" AND "+utils.RepostAccessCondition()+" AND "+utils.RepostNotMutedCondition()+" AND "+repostOfFollowedCondition, append(append([]interface{}{reqProfile.Id, false, false, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id}, utils.PostAccessArgs(reqProfile.Id)...), reqProfile.Id, reqProfile.Id, reqProfile.Id)...).Count(&numFeedPosts).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -75,6 +85,16 @@ func GetFollowingsFeed(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addPostEntities(reqProfile.Id, feedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, feedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, feedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
@@ -99,7 +119,7 @@ func GetSubscriptionsFeed(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		query := "WITH media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio) ORDER BY position) AS media_data FROM post_media GROUP BY post_id), "
+		query := "WITH " + mediaAggCTE + ", "
 		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
 		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
 		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
@@ -126,20 +146,20 @@ func GetSubscriptionsFeed(c *fiber.Ctx) error {
 		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
 		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
 
-		query += "WHERE profile_subscribers.subscriber_id = ? AND profile_subscribers.is_accepted = true AND posts.is_archived = false AND posts.for_subscribers_only = true "
+		query += "WHERE profile_subscribers.subscriber_id = ? AND profile_subscribers.is_accepted = true AND " + utils.UnexpiredSubscriptionCondition() + " AND posts.is_archived = false AND " + utils.PublishedCondition() + " AND posts.for_subscribers_only = true AND " + utils.MeetsMinTierCondition("profile_subscribers.tier_id") + " AND " + utils.NotBlockedCondition("posts.profile_id") + " AND " + utils.InAudienceCondition() + " AND " + utils.NotMutedCondition("posts.profile_id") + " AND " + utils.NoMutedKeywordCondition("posts.title", "posts.caption") + " "
 		query += "ORDER BY posts.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&feedPosts).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&feedPosts).Error
 	}()
 
 	// Get total number of following feed posts
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
 	var numFeedPosts int64
-	if err := configs.Database.WithContext(dbCtx2).Table("posts").Where("profile_id IN (SELECT profile_id FROM profile_subscribers WHERE subscriber_id = ? AND is_accepted = true) AND is_archived = ? AND for_subscribers_only = ?", reqProfile.Id, false, true).Count(&numFeedPosts).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx2).Table("posts").Where(utils.SubscribedToPostCondition()+" AND is_archived = ? AND "+utils.PublishedCondition()+" AND for_subscribers_only = ? AND "+utils.NotBlockedCondition("posts.profile_id")+" AND "+utils.InAudienceCondition()+" AND "+utils.NotMutedCondition("posts.profile_id")+" AND "+utils.NoMutedKeywordCondition("posts.title", "posts.caption"), reqProfile.Id, false, true, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id).Count(&numFeedPosts).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -150,6 +170,16 @@ func GetSubscriptionsFeed(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addPostEntities(reqProfile.Id, feedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, feedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, feedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
@@ -174,7 +204,7 @@ func GetArchivedPosts(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		query := "WITH media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio) ORDER BY position) AS media_data FROM post_media GROUP BY post_id), "
+		query := "WITH " + mediaAggCTE + ", "
 		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
 		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
 		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
@@ -200,7 +230,7 @@ func GetArchivedPosts(c *fiber.Ctx) error {
 		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
 		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
 
-		query += "WHERE profiles.id = ? AND posts.is_archived = true "
+		query += "WHERE profiles.id = ? AND posts.is_archived = true AND " + utils.PublishedCondition() + " "
 		query += "ORDER BY posts.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
@@ -212,7 +242,7 @@ func GetArchivedPosts(c *fiber.Ctx) error {
 	// Get total number of archived posts
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
-	numArchivedPosts := configs.Database.WithContext(dbCtx2).Model(&reqProfile).Where("is_archived = ?", true).Association("Posts").Count()
+	numArchivedPosts := configs.Database.WithContext(dbCtx2).Model(&reqProfile).Where("is_archived = ? AND "+utils.PublishedCondition(), true).Association("Posts").Count()
 
 	wg.Wait()
 
@@ -221,6 +251,16 @@ func GetArchivedPosts(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addPostEntities(reqProfile.Id, archivedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, archivedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, archivedPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
@@ -237,6 +277,24 @@ func GetPublicPosts(c *fiber.Ctx) error {
 	var offset int = c.Locals("offset").(int)
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	// Posts are hidden from profiles that have a block with the owner
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this user's posts."}, nil))
+	}
+
+	// Public posts of a private account are limited to its approved followers
+	isHidden, err := isHiddenByPrivacy(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isHidden {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "This account is private."}, nil))
+	}
+
 	// Run both queries concurrently to reduce response time
 	errChan := make(chan error, 1) // make this buffered so that the goroutine doesn't block
 	wg := new(sync.WaitGroup)
@@ -245,7 +303,7 @@ func GetPublicPosts(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		query := "WITH media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio) ORDER BY position) AS media_data FROM post_media GROUP BY post_id), "
+		query := "WITH " + mediaAggCTE + ", "
 		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
 		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
 		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
@@ -271,20 +329,21 @@ func GetPublicPosts(c *fiber.Ctx) error {
 		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
 		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
 
-		query += "WHERE profiles.id = ? AND posts.is_archived = false AND posts.for_subscribers_only = false "
+		query += "WHERE profiles.id = ? AND posts.is_archived = false AND " + utils.PublishedCondition() + " AND posts.for_subscribers_only = false AND " + utils.InAudienceCondition() + " AND " + utils.RepostAccessCondition() + " "
 		query += "ORDER BY posts.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
+		queryArgs := append([]interface{}{reqProfile.Id, reqProfile.Id, reqProfile.Id, c.Params("profileId"), reqProfile.Id, reqProfile.Id}, utils.PostAccessArgs(reqProfile.Id)...)
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, c.Params("profileId"), limit, offset).Scan(&publicPosts).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, append(queryArgs, limit, offset)...).Scan(&publicPosts).Error
 	}()
 
 	// Get total number of public posts
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
 	var numPublicPosts int64
-	if err := configs.Database.WithContext(dbCtx2).Table("posts").Where("profile_id = ? AND is_archived = ? AND for_subscribers_only = ?", c.Params("profileId"), false, false).Count(&numPublicPosts).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx2).Table("posts").Where("profile_id = ? AND is_archived = ? AND "+utils.PublishedCondition()+" AND for_subscribers_only = ? AND "+utils.InAudienceCondition()+" AND "+utils.RepostAccessCondition(), append([]interface{}{c.Params("profileId"), false, false, reqProfile.Id, reqProfile.Id}, utils.PostAccessArgs(reqProfile.Id)...)...).Count(&numPublicPosts).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -295,6 +354,16 @@ func GetPublicPosts(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addPostEntities(reqProfile.Id, publicPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, publicPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, publicPosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
@@ -311,6 +380,15 @@ func GetExclusivePosts(c *fiber.Ctx) error {
 	var offset int = c.Locals("offset").(int)
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	// Posts are hidden from profiles that have a block with the owner
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this user's posts."}, nil))
+	}
+
 	// Run both queries concurrently to reduce response time
 	errChan := make(chan error, 1) // make this buffered so that the goroutine doesn't block
 	wg := new(sync.WaitGroup)
@@ -319,7 +397,7 @@ func GetExclusivePosts(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		query := "WITH media_agg AS (SELECT post_id, json_agg(json_build_object('media_url', media_url, 'is_image', is_image, 'is_video', is_video, 'is_audio', is_audio) ORDER BY position) AS media_data FROM post_media GROUP BY post_id), "
+		query := "WITH " + mediaAggCTE + ", "
 		query += "likes_agg AS (SELECT post_id, COUNT(*) AS likes FROM post_likes GROUP BY post_id), "
 		query += "dislikes_agg AS (SELECT post_id, COUNT(*) AS dislikes FROM post_dislikes GROUP BY post_id), "
 		query += "bookmarks_agg AS (SELECT post_id, COUNT(*) AS bookmarks FROM post_bookmarks GROUP BY post_id), "
@@ -345,20 +423,20 @@ func GetExclusivePosts(c *fiber.Ctx) error {
 		query += "LEFT JOIN post_dislikes pd ON posts.id = pd.post_id AND pd.profile_id = ? "
 		query += "LEFT JOIN post_bookmarks pb ON posts.id = pb.post_id AND pb.profile_id = ? "
 
-		query += "WHERE profiles.id = ? AND posts.is_archived = false AND posts.for_subscribers_only = true "
+		query += "WHERE profiles.id = ? AND posts.is_archived = false AND " + utils.PublishedCondition() + " AND posts.for_subscribers_only = true AND " + utils.InAudienceCondition() + " AND (posts.profile_id = ? OR " + utils.SubscribedToPostCondition() + ") "
 		query += "ORDER BY posts.created_at DESC "
 		query += "LIMIT ? OFFSET ?;"
 
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, c.Params("profileId"), limit, offset).Scan(&exclusivePosts).Error
+		errChan <- configs.Database.WithContext(dbCtx).Raw(query, reqProfile.Id, reqProfile.Id, reqProfile.Id, c.Params("profileId"), reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id, limit, offset).Scan(&exclusivePosts).Error
 	}()
 
 	// Get total number of exclusive posts
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
 	var numExclusivePosts int64
-	if err := configs.Database.WithContext(dbCtx2).Table("posts").Where("profile_id = ? AND is_archived = ? AND for_subscribers_only = ?", c.Params("profileId"), false, true).Count(&numExclusivePosts).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx2).Table("posts").Where("profile_id = ? AND is_archived = ? AND "+utils.PublishedCondition()+" AND for_subscribers_only = ? AND "+utils.InAudienceCondition()+" AND (posts.profile_id = ? OR "+utils.SubscribedToPostCondition()+")", c.Params("profileId"), false, true, reqProfile.Id, reqProfile.Id, reqProfile.Id, reqProfile.Id).Count(&numExclusivePosts).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -369,6 +447,16 @@ func GetExclusivePosts(c *fiber.Ctx) error {
 	}
 	close(errChan)
 
+	if err := addPostEntities(reqProfile.Id, exclusivePosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostPolls(reqProfile.Id, exclusivePosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if err := addPostReposts(reqProfile.Id, exclusivePosts); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
 			"current_page": page,
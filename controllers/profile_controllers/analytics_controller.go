@@ -0,0 +1,46 @@
+package profilecontrollers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+// Returns a time series of the activity on the request user's profile. Every period of the range is included, periods without activity are zero.
+func GetProfileAnalytics(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	r, errMessage := utils.ParseAnalyticsRange(c.Query("interval"), c.Query("from"), c.Query("to"))
+	if errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var rows []responses.ProfileStats
+	if err := configs.Database.WithContext(dbCtx).Model(&models.ProfileDailyStat{}).
+		Select("date_trunc(?, day)::date AS period_start, SUM(new_followers) AS new_followers, SUM(unfollows) AS unfollows, SUM(new_subscribers) AS new_subscribers, SUM(likes) AS likes, SUM(dislikes) AS dislikes, SUM(bookmarks) AS bookmarks, SUM(comments) AS comments", r.Interval).
+		Where("profile_id = ? AND day >= ? AND day <= ?", reqProfile.Id, r.From, r.To).
+		Group("period_start").
+		Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	rowsByPeriod := make(map[string]responses.ProfileStats, len(rows))
+	for _, row := range rows {
+		rowsByPeriod[row.PeriodStart.Format("2006-01-02")] = row
+	}
+	series := []responses.ProfileStats{}
+	for _, period := range r.Periods() {
+		row, ok := rowsByPeriod[period.Format("2006-01-02")]
+		if !ok {
+			row = responses.ProfileStats{}
+		}
+		row.PeriodStart = period
+		series = append(series, row)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": series}))
+}
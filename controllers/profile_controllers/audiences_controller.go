@@ -0,0 +1,220 @@
+package profilecontrollers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rivo/uniseg"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	maxAudiences = 20
+)
+
+// Returns the audience if it is owned by profileId. The Id of the returned audience is empty if it isn't found.
+func findOwnedAudience(profileId, audienceId string) (models.Audience, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var audience models.Audience
+	err := configs.Database.WithContext(dbCtx).Model(&models.Audience{}).Find(&audience, "id = ? AND profile_id = ?", audienceId, profileId).Error
+	return audience, err
+}
+
+func CreateAudience(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		Name string `json:"name"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	reqBody.Name = strings.TrimSpace(reqBody.Name) // remove leading and trailing whitespace
+
+	if reqBody.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	if uniseg.GraphemeClusterCount(reqBody.Name) > 50 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Name is too long."}, nil))
+	}
+
+	// Get number of audiences
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	numAudiences := configs.Database.WithContext(dbCtx).Model(&reqProfile).Association("Audiences").Count()
+	if numAudiences >= maxAudiences {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": fmt.Sprintf("Max number of audiences is %d.", maxAudiences)}, nil))
+	}
+
+	newAudience := models.Audience{
+		ProfileId: reqProfile.Id,
+		Name:      reqBody.Name,
+	}
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.Audience{}).Create(&newAudience).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": newAudience}))
+}
+
+func RenameAudience(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		Name string `json:"name"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	reqBody.Name = strings.TrimSpace(reqBody.Name) // remove leading and trailing whitespace
+
+	if reqBody.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	if uniseg.GraphemeClusterCount(reqBody.Name) > 50 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Name is too long."}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Audience{}).Where("id = ? AND profile_id = ?", c.Params("audienceId"), reqProfile.Id).Update("name", reqBody.Name).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Audience has been renamed."}))
+}
+
+// Deleting an audience does not make its posts public. They stay limited to an audience that no longer has members so only the owner can see them.
+func DeleteAudience(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Members are removed by the cascade on audience_members
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Delete(&models.Audience{}, "id = ? AND profile_id = ?", c.Params("audienceId"), reqProfile.Id).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Audience has been deleted."}))
+}
+
+func GetAudiences(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var audiences = []responses.Audience{}
+	if err := configs.Database.WithContext(dbCtx).Table("audiences").
+		Select("audiences.id, audiences.name, audiences.created_at, COUNT(audience_members.profile_id) AS num_members").
+		Joins("LEFT JOIN audience_members ON audience_members.audience_id = audiences.id").
+		Where("audiences.profile_id = ?", reqProfile.Id).
+		Group("audiences.id").
+		Order("audiences.created_at DESC").
+		Scan(&audiences).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": audiences}))
+}
+
+func AddToAudience(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	audience, err := findOwnedAudience(reqProfile.Id, c.Params("audienceId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if audience.Id == "" { // Id field is empty => audience does not exist or isn't owned by the request user
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Audience not found."}, nil))
+	}
+
+	if reqProfile.Id == c.Params("profileId") {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot add yourself to an audience."}, nil))
+	}
+
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	newMemberObj := models.AudienceMember{
+		AudienceId: audience.Id,
+		ProfileId:  c.Params("profileId"),
+		CreatedAt:  time.Now(),
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Table("audience_members").Where("audience_id = ? AND profile_id = ?", newMemberObj.AudienceId, newMemberObj.ProfileId).FirstOrCreate(&newMemberObj).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "User has been added to the audience."}))
+}
+
+func RemoveFromAudience(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Delete the object, the USING clause makes sure only the owner of the audience can remove members
+	query := "DELETE FROM audience_members USING audiences WHERE audience_members.audience_id = ? AND audience_members.profile_id = ? AND audiences.id = audience_members.audience_id AND audiences.profile_id = ?"
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Exec(query, c.Params("audienceId"), c.Params("profileId"), reqProfile.Id).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "User has been removed from the audience."}))
+}
+
+func GetAudienceMembers(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	audience, err := findOwnedAudience(reqProfile.Id, c.Params("audienceId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if audience.Id == "" { // Id field is empty => audience does not exist or isn't owned by the request user
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Audience not found."}, nil))
+	}
+
+	// Get members(paginated)
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
+	var members = []responses.MiniProfile{}
+	if err := configs.Database.WithContext(dbCtx).Model(&audience).Offset(offset).Limit(limit).Order("audience_members.created_at DESC").Where("username LIKE ?", regexMatch).Association("Members").Find(&members); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Get total number of members
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	numMembers := configs.Database.WithContext(dbCtx2).Model(&audience).Where("username LIKE ?", regexMatch).Association("Members").Count()
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numMembers) / float64(limit))),
+			"data":         members,
+		},
+	}))
+}
@@ -0,0 +1,216 @@
+package profilecontrollers
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/payments"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+func newTransactionResponse(transaction models.LedgerTransaction) responses.Transaction {
+	return responses.Transaction{
+		Id:         transaction.Id,
+		Kind:       transaction.Kind,
+		PayerId:    transaction.PayerId,
+		CreatorId:  transaction.CreatorId,
+		TierId:     transaction.TierId,
+		RefundOfId: transaction.RefundOfId,
+		Amount:     transaction.Amount,
+		Currency:   transaction.Currency,
+		CreatedAt:  transaction.CreatedAt,
+	}
+}
+
+// Subscribes the request user to a paid tier of profileId by charging the first billing period
+func SubscribeToTier(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		TierId string `json:"tier_id"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if reqBody.TierId == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	if reqProfile.Id == c.Params("profileId") {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot subscribe to yourself."}, nil))
+	}
+
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	tier, err := findOwnedTier(c.Params("profileId"), reqBody.TierId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if tier.Id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier not found."}, nil))
+	}
+	if !tier.IsPaid() {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This tier is free, send a request to subscribe instead."}, nil))
+	}
+
+	// Subscribers that want another tier have to change their tier instead. An expired subscription that hasn't been removed yet is paid for again.
+	isSubscribed, err := hasSubscription(c.Params("profileId"), reqProfile.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isSubscribed {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You are already subscribed to this user."}, nil))
+	}
+
+	if err := utils.ChargeSubscription(reqProfile.Id, tier, time.Now(), uuid.NewString()); err != nil {
+		if errors.Is(err, payments.ErrDeclined) {
+			return c.Status(fiber.StatusPaymentRequired).JSON(responses.NewErrorResponse(fiber.StatusPaymentRequired, &fiber.Map{"data": "Your payment was declined."}, err))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "You are now subscribed."}))
+}
+
+// Turns the renewal of the request user's paid subscription to profileId on or off. A subscription that isn't renewed ends with its current period.
+func SetSubscriptionRenewal(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		AutoRenew *bool `json:"auto_renew"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if reqBody.AutoRenew == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	result := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_accepted = ? AND current_period_end IS NOT NULL", c.Params("profileId"), reqProfile.Id, true).Update("cancel_at_period_end", !*reqBody.AutoRenew)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You do not have a paid subscription to this user."}, nil))
+	}
+
+	if *reqBody.AutoRenew {
+		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Subscription will be renewed."}))
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Subscription will end with the current period."}))
+}
+
+// Returns what the platform owes the request user, by currency
+func GetBalance(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	balances, err := utils.CreatorBalances(reqProfile.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": balances}))
+}
+
+func RequestPayout(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	payouts, err := utils.PayoutCreator(reqProfile.Id)
+	if err != nil {
+		if errors.Is(err, utils.ErrPayoutInProgress) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "A payout is already in progress."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	if len(payouts) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "There is nothing to pay out."}, nil))
+	}
+
+	resObjs := make([]responses.Transaction, 0, len(payouts))
+	for _, payout := range payouts {
+		resObjs = append(resObjs, newTransactionResponse(payout))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": resObjs}))
+}
+
+// Refunds what is left of a charge paid to the request user. If the charge paid for the current period of the subscription, the subscription ends immediately.
+func RefundACharge(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var charge models.LedgerTransaction
+	if err := configs.Database.WithContext(dbCtx).Model(&models.LedgerTransaction{}).Find(&charge, "id = ? AND creator_id = ? AND kind = ?", c.Params("transactionId"), reqProfile.Id, models.LedgerKindCharge).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if charge.Id == "" { // Id field is empty => charge does not exist or wasn't paid to the request user
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Charge not found."}, nil))
+	}
+
+	if err := utils.RefundCharge(charge); err != nil {
+		if errors.Is(err, utils.ErrAlreadyRefunded) {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This charge has already been refunded."}, nil))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Charge has been refunded."}))
+}
+
+// Returns the charges, refunds and payouts the request user paid or received
+func GetTransactions(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	query := configs.Database.Model(&models.LedgerTransaction{}).Where("payer_id = ? OR creator_id = ?", reqProfile.Id, reqProfile.Id)
+
+	// Get transactions(paginated)
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var transactions = []models.LedgerTransaction{}
+	if err := query.WithContext(dbCtx).Order("created_at DESC").Limit(limit).Offset(offset).Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Get total number of transactions
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numTransactions int64
+	if err := query.WithContext(dbCtx2).Count(&numTransactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	resObjs := make([]responses.Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		resObjs = append(resObjs, newTransactionResponse(transaction))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numTransactions) / float64(limit))),
+			"data":         resObjs,
+		},
+	}))
+}
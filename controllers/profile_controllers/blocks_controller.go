@@ -0,0 +1,94 @@
+package profilecontrollers
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+func BlockAUser(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	if reqProfile.Id == c.Params("profileId") {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot block yourself."}, nil))
+	}
+
+	// Create the block and remove every follower and subscriber relation between the two profiles, in both directions
+	newBlockObj := models.ProfileBlock{
+		ProfileId: reqProfile.Id,
+		BlockedId: c.Params("profileId"),
+		CreatedAt: time.Now(),
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("profile_blocks").Where("profile_id = ? AND blocked_id = ?", newBlockObj.ProfileId, newBlockObj.BlockedId).FirstOrCreate(&newBlockObj).Error; err != nil {
+			return err
+		}
+
+		if err := utils.DeleteFollow(tx, reqProfile.Id, c.Params("profileId")); err != nil {
+			return err
+		}
+		if err := utils.DeleteFollow(tx, c.Params("profileId"), reqProfile.Id); err != nil {
+			return err
+		}
+
+		var subscriberObj models.ProfileSubscriber
+		return tx.Table("profile_subscribers").Delete(&subscriberObj, "(profile_id = ? AND subscriber_id = ?) OR (profile_id = ? AND subscriber_id = ?)", reqProfile.Id, c.Params("profileId"), c.Params("profileId"), reqProfile.Id).Error
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "User has been blocked."}))
+}
+
+func UnblockAUser(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Delete the object
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var blockObj models.ProfileBlock
+	if err := configs.Database.WithContext(dbCtx).Table("profile_blocks").Delete(&blockObj, "profile_id = ? AND blocked_id = ?", reqProfile.Id, c.Params("profileId")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "User has been unblocked."}))
+}
+
+func GetBlocked(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Get blocked profiles(paginated)
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
+	var blocked = []responses.MiniProfile{}
+	if err := configs.Database.WithContext(dbCtx).Model(&reqProfile).Offset(offset).Limit(limit).Order("profile_blocks.created_at DESC").Where("username LIKE ?", regexMatch).Association("Blocked").Find(&blocked); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Get total number of blocked profiles
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	numBlocked := configs.Database.WithContext(dbCtx2).Model(&reqProfile).Where("username LIKE ?", regexMatch).Association("Blocked").Count()
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numBlocked) / float64(limit))),
+			"data":         blocked,
+		},
+	}))
+}
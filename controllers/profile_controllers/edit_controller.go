@@ -5,6 +5,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/configs/cache"
 	"nerajima.com/NeraJima/models"
@@ -150,6 +151,55 @@ func EditBio(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Bio has been updated."}))
 }
 
+func EditPrivacy(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		IsPrivate *bool `json:"is_private"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	// Check if all fields are included
+	if reqBody.IsPrivate == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	if *reqBody.IsPrivate == reqProfile.IsPrivate {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This is your current privacy setting."}, nil))
+	}
+
+	// Update privacy. When going public, every pending follow request is accepted since there is nothing left to approve.
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&reqProfile).Update("is_private", *reqBody.IsPrivate).Error; err != nil {
+			return err
+		}
+		if !*reqBody.IsPrivate {
+			return tx.Table("profile_followers").Where("profile_id = ? AND is_pending = ?", reqProfile.Id, true).Update("is_pending", false).Error
+		}
+		return nil
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Update cached profile
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	defer cacheCancel()
+	var key = cache.ProfileKey(reqProfile.UserId)
+	var exp = cache.ProfileExp
+	if err := cache.Set(cacheCtx, key, reqProfile, exp); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	if reqProfile.IsPrivate {
+		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Account is now private."}))
+	}
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Account is now public."}))
+}
+
 func EditAvatar(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Edit Avatar"}))
 }
@@ -6,9 +6,11 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
 	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
 )
 
 func FollowAUser(c *fiber.Ctx) error {
@@ -18,17 +20,41 @@ func FollowAUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot follow yourself."}, nil))
 	}
 
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	// Get the profile being followed to see if it is private
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var profile models.Profile
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Profile{}).Select("id", "is_private").Find(&profile, "id = ?", c.Params("profileId")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if profile.Id == "" { // Id field is empty => Account is not found
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Account not found."}, nil))
+	}
+
+	// If the profile is private, the follow is created as a pending request. If the row already exists, FirstOrCreate loads it into newFollowerObj instead.
 	newFollowerObj := models.ProfileFollower{
 		ProfileId:  c.Params("profileId"),
 		FollowerId: reqProfile.Id,
+		IsPending:  profile.IsPrivate,
 		CreatedAt:  time.Now(),
 	}
-	dbCtx, dbCancel := configs.NewQueryContext()
-	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Table("profile_followers").Where("profile_id = ? AND follower_id = ?", c.Params("profileId"), reqProfile.Id).FirstOrCreate(&newFollowerObj).Error; err != nil {
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Table("profile_followers").Where("profile_id = ? AND follower_id = ?", c.Params("profileId"), reqProfile.Id).FirstOrCreate(&newFollowerObj).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
+	if newFollowerObj.IsPending {
+		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Follow request has been sent."}))
+	}
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "User has been followed."}))
 }
 
@@ -42,8 +68,9 @@ func UnfollowAUser(c *fiber.Ctx) error {
 	// Delete followers object
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	var followerObj models.ProfileFollower
-	if err := configs.Database.WithContext(dbCtx).Table("profile_followers").Delete(&followerObj, "profile_id = ? AND follower_id = ?", c.Params("profileId"), reqProfile.Id).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		return utils.DeleteFollow(tx, c.Params("profileId"), reqProfile.Id)
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -60,8 +87,9 @@ func RemoveAFollower(c *fiber.Ctx) error {
 	// Delete followers object
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	var followerObj models.ProfileFollower
-	if err := configs.Database.WithContext(dbCtx).Table("profile_followers").Delete(&followerObj, "profile_id = ? AND follower_id = ?", reqProfile.Id, c.Params("profileId")).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		return utils.DeleteFollow(tx, reqProfile.Id, c.Params("profileId"))
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -72,20 +100,21 @@ func GetFollowers(c *fiber.Ctx) error {
 	var page int = c.Locals("page").(int)
 	var limit int = c.Locals("limit").(int)
 	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
 	// Get followers(paginated)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
 	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
 	var followers = []responses.MiniProfile{}
-	if err := configs.Database.WithContext(dbCtx).Model(&models.Profile{Base: models.Base{Id: c.Params("profileId")}}).Offset(offset).Limit(limit).Order("profile_followers.created_at DESC").Where("username LIKE ?", regexMatch).Association("Followers").Find(&followers); err != nil {
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Profile{Base: models.Base{Id: c.Params("profileId")}}).Offset(offset).Limit(limit).Order("profile_followers.created_at DESC").Where("is_pending = ?", false).Where("username LIKE ?", regexMatch).Where(utils.NotBlockedCondition("profiles.id"), reqProfile.Id, reqProfile.Id).Association("Followers").Find(&followers); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
 	// Get total number of followers
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
-	numFollowers := configs.Database.WithContext(dbCtx2).Model(&models.Profile{Base: models.Base{Id: c.Params("profileId")}}).Where("username LIKE ?", regexMatch).Association("Followers").Count()
+	numFollowers := configs.Database.WithContext(dbCtx2).Model(&models.Profile{Base: models.Base{Id: c.Params("profileId")}}).Where("is_pending = ?", false).Where("username LIKE ?", regexMatch).Where(utils.NotBlockedCondition("profiles.id"), reqProfile.Id, reqProfile.Id).Association("Followers").Count()
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
@@ -101,6 +130,7 @@ func GetFollowing(c *fiber.Ctx) error {
 	var page int = c.Locals("page").(int)
 	var limit int = c.Locals("limit").(int)
 	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
 	/*
 	   IMPORTANT:
@@ -111,7 +141,8 @@ func GetFollowing(c *fiber.Ctx) error {
 	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
 	query := configs.Database.Table("profiles").
 		Joins("JOIN profile_followers ON profile_followers.follower_id = ? AND profile_followers.profile_id = profiles.id", c.Params("profileId")).
-		Where("username LIKE ?", regexMatch)
+		Where("is_pending = ? AND username LIKE ?", false, regexMatch).
+		Where(utils.NotBlockedCondition("profiles.id"), reqProfile.Id, reqProfile.Id)
 
 		// Get following(paginated)
 	dbCtx, dbCancel := configs.NewQueryContext()
@@ -138,3 +169,135 @@ func GetFollowing(c *fiber.Ctx) error {
 		},
 	}))
 }
+
+func CancelFollowRequest(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Delete the object
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var followerObj models.ProfileFollower
+	if err := configs.Database.WithContext(dbCtx).Table("profile_followers").Delete(&followerObj, "profile_id = ? AND follower_id = ? AND is_pending = ?", c.Params("profileId"), reqProfile.Id, true).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Follow request has been canceled."}))
+}
+
+func AcceptFollowRequest(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	result := configs.Database.WithContext(dbCtx).Table("profile_followers").Where("profile_id = ? AND follower_id = ? AND is_pending = ?", reqProfile.Id, c.Params("senderId"), true).Update("is_pending", false)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Follow request not found."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Follow request has been accepted."}))
+}
+
+func DeclineFollowRequest(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Delete the object
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var followerObj models.ProfileFollower
+	result := configs.Database.WithContext(dbCtx).Table("profile_followers").Delete(&followerObj, "profile_id = ? AND follower_id = ? AND is_pending = ?", reqProfile.Id, c.Params("senderId"), true)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Follow request not found."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Follow request has been declined."}))
+}
+
+func GetFollowRequestsSent(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	/*
+	   IMPORTANT:
+	      To build the raw queries below, I used the raw query from the GetFollowing endpoint made a few changes
+	*/
+
+	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
+	query := configs.Database.Table("profiles").
+		Joins("JOIN profile_followers ON profile_followers.follower_id = ? AND profile_followers.profile_id = profiles.id", reqProfile.Id).
+		Where("is_pending = ? AND username LIKE ?", true, regexMatch)
+
+	// Get requests sent(paginated)
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var requestsSent = []responses.MiniProfile{}
+	if err := query.WithContext(dbCtx).Order("profile_followers.created_at DESC").Limit(limit).Offset(offset).Scan(&requestsSent).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Get total number of requests sent
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numRequestsSent int64
+	if err := query.WithContext(dbCtx2).Count(&numRequestsSent).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numRequestsSent) / float64(limit))),
+			"data":         requestsSent,
+		},
+	}))
+}
+
+func GetFollowRequestsReceived(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	/*
+	   IMPORTANT:
+	      To build the raw queries below, I used the raw query from the GetFollowing endpoint made a few changes
+	*/
+
+	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
+	query := configs.Database.Table("profiles").
+		Joins("JOIN profile_followers ON profile_followers.profile_id = ? AND profile_followers.follower_id = profiles.id", reqProfile.Id).
+		Where("is_pending = ? AND username LIKE ?", true, regexMatch)
+
+	// Get requests received(paginated)
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var requestsReceived = []responses.MiniProfile{}
+	if err := query.WithContext(dbCtx).Order("profile_followers.created_at DESC").Limit(limit).Offset(offset).Scan(&requestsReceived).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Get total number of requests received
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numRequestsReceived int64
+	if err := query.WithContext(dbCtx2).Count(&numRequestsReceived).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numRequestsReceived) / float64(limit))),
+			"data":         requestsReceived,
+		},
+	}))
+}
@@ -0,0 +1,381 @@
+package profilecontrollers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/cache"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+/*
+   The follow graph of a profile is the list of profiles it follows and is subscribed to. It can be exported as JSON or CSV and
+   imported again, into the same or another account, to bulk follow and bulk request subscriptions.
+*/
+
+const (
+	graphEntryFollow       = "follow"
+	graphEntrySubscription = "subscription"
+
+	maxGraphImportEntries  = 200  // entries a single import can hold
+	graphImportDailyLimit  = 1000 // entries a profile can import per day
+	graphImportLimitWindow = time.Hour * 24
+)
+
+// What happened to an imported entry
+const (
+	importStatusFollowed              = "followed"
+	importStatusFollowRequested       = "follow_requested"
+	importStatusAlreadyFollowing      = "already_following"
+	importStatusSubscriptionRequested = "subscription_requested"
+	importStatusAlreadySubscribed     = "already_subscribed"
+	importStatusNotFound              = "not_found"
+	importStatusBlocked               = "blocked"
+	importStatusSelf                  = "self"
+	importStatusInvalid               = "invalid"
+	importStatusTierNotFound          = "tier_not_found"
+	importStatusTierRequiresPayment   = "tier_requires_payment"
+	importStatusRateLimited           = "rate_limited"
+)
+
+var graphCSVHeader = []string{"type", "username", "profile_id", "tier_id", "is_pending", "since"}
+
+type graphImportEntry struct {
+	Type     string  `json:"type"`
+	Username string  `json:"username"`
+	TierId   *string `json:"tier_id"` // this is allowed to be nil
+}
+
+// Reads the entries of a CSV import. Columns are found by the names in the header row, so an export can be imported as it is.
+func parseGraphCSV(body []byte) ([]graphImportEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1 // rows are allowed to leave out trailing columns
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return []graphImportEntry{}, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	typeColumn, hasType := columns["type"]
+	usernameColumn, hasUsername := columns["username"]
+	if !hasType || !hasUsername {
+		return nil, errors.New("the header row must have a type and a username column")
+	}
+	tierColumn, hasTier := columns["tier_id"]
+
+	entries := make([]graphImportEntry, 0, len(records)-1)
+	for _, record := range records[1:] {
+		field := func(column int) string {
+			if column < len(record) {
+				return strings.TrimSpace(record[column])
+			}
+			return ""
+		}
+
+		entry := graphImportEntry{Type: field(typeColumn), Username: field(usernameColumn)}
+		if tierId := field(tierColumn); hasTier && tierId != "" {
+			entry.TierId = &tierId
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Exports the profiles the request user follows, including follow requests, and is subscribed to. The "format" query parameter is json (default) or csv.
+func ExportGraph(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Format must be json or csv."}, nil))
+	}
+
+	// Get follows
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var follows = []responses.GraphEntry{}
+	if err := configs.Database.WithContext(dbCtx).Table("profile_followers").
+		Select("? AS type, profiles.id AS profile_id, profiles.username, profile_followers.is_pending, profile_followers.created_at AS since", graphEntryFollow).
+		Joins("JOIN profiles ON profiles.id = profile_followers.profile_id").
+		Where("profile_followers.follower_id = ?", reqProfile.Id).
+		Order("profile_followers.created_at").
+		Scan(&follows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Get subscriptions
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var subscriptions = []responses.GraphEntry{}
+	if err := configs.Database.WithContext(dbCtx2).Table("profile_subscribers").
+		Select("? AS type, profiles.id AS profile_id, profiles.username, profile_subscribers.tier_id, profile_subscribers.created_at AS since", graphEntrySubscription).
+		Joins("JOIN profiles ON profiles.id = profile_subscribers.profile_id").
+		Where("profile_subscribers.subscriber_id = ? AND profile_subscribers.is_accepted = ? AND "+utils.UnexpiredSubscriptionCondition(), reqProfile.Id, true).
+		Order("profile_subscribers.created_at").
+		Scan(&subscriptions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	entries := append(follows, subscriptions...)
+	if format == "json" {
+		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": entries}))
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(graphCSVHeader)
+	for _, entry := range entries {
+		tierId := ""
+		if entry.TierId != nil {
+			tierId = *entry.TierId
+		}
+		writer.Write([]string{entry.Type, entry.Username, entry.ProfileId, tierId, strconv.FormatBool(entry.IsPending), entry.Since.Format(time.RFC3339)})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="follow-graph.csv"`)
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+/*
+Follows and requests subscriptions to the profiles in an imported follow graph. The body is either JSON ({"entries": [{"type", "username", "tier_id"}]})
+or, with a text/csv content type, a CSV file with a header row such as the one made by ExportGraph. Profiles are matched by username.
+
+Private profiles get a follow request. Subscriptions are requested and have to be accepted by the creator, paid tiers can't be requested.
+Every entry gets a status in the result report. Entries past the daily import limit are reported as rate limited and not imported.
+*/
+func ImportGraph(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	var entries []graphImportEntry
+	if c.Is("csv") {
+		parsedEntries, err := parseGraphCSV(c.Body())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+		}
+		entries = parsedEntries
+	} else {
+		reqBody := struct {
+			Entries []graphImportEntry `json:"entries"`
+		}{}
+		if err := c.BodyParser(&reqBody); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+		}
+		entries = reqBody.Entries
+	}
+
+	// Check if all fields are included
+	if len(entries) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+	if len(entries) > maxGraphImportEntries {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": fmt.Sprintf("Imports can hold at most %d entries.", maxGraphImportEntries)}, nil))
+	}
+
+	// Count the entries towards the daily limit. The ones past the limit are not imported.
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	defer cacheCancel()
+	count, err := cache.IncrementCounter(cacheCtx, cache.GraphImportCountKey(reqProfile.Id), int64(len(entries)), graphImportLimitWindow)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	numAllowed := len(entries)
+	if count > graphImportDailyLimit {
+		numAllowed -= int(count - graphImportDailyLimit)
+	}
+	if numAllowed <= 0 {
+		return c.Status(fiber.StatusTooManyRequests).JSON(responses.NewErrorResponse(fiber.StatusTooManyRequests, &fiber.Map{"data": "You have imported too many accounts today. Please try again later."}, nil))
+	}
+
+	results := make([]responses.GraphImportResult, len(entries))
+	usernames := []string{}
+	tierIds := []string{}
+	for i := range entries {
+		entries[i].Type = strings.ToLower(strings.TrimSpace(entries[i].Type))
+		entries[i].Username = strings.TrimSpace(entries[i].Username)
+		results[i] = responses.GraphImportResult{Index: i, Type: entries[i].Type, Username: entries[i].Username}
+
+		switch {
+		case i >= numAllowed:
+			results[i].Status = importStatusRateLimited
+		case entries[i].Username == "" || (entries[i].Type != graphEntryFollow && entries[i].Type != graphEntrySubscription):
+			results[i].Status = importStatusInvalid
+		default:
+			usernames = append(usernames, entries[i].Username)
+			if entries[i].Type == graphEntrySubscription && entries[i].TierId != nil {
+				tierIds = append(tierIds, *entries[i].TierId)
+			}
+		}
+	}
+
+	// Find the imported profiles
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var profiles []models.Profile
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Profile{}).Select("id", "username", "is_private").Where("username IN ?", usernames).Find(&profiles).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	profilesByUsername := make(map[string]models.Profile, len(profiles))
+	profileIds := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		profilesByUsername[profile.Username] = profile
+		profileIds = append(profileIds, profile.Id)
+	}
+
+	// Find blocks in either direction
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var blocks []models.ProfileBlock
+	if err := configs.Database.WithContext(dbCtx2).Table("profile_blocks").Where("(profile_id = ? AND blocked_id IN ?) OR (blocked_id = ? AND profile_id IN ?)", reqProfile.Id, profileIds, reqProfile.Id, profileIds).Find(&blocks).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	isBlocked := map[string]bool{}
+	for _, block := range blocks {
+		isBlocked[block.ProfileId] = true
+		isBlocked[block.BlockedId] = true
+	}
+
+	// Find existing follows and subscriptions, including pending ones that haven't expired
+	dbCtx3, dbCancel3 := configs.NewQueryContext()
+	defer dbCancel3()
+	var follows []models.ProfileFollower
+	if err := configs.Database.WithContext(dbCtx3).Table("profile_followers").Where("follower_id = ? AND profile_id IN ?", reqProfile.Id, profileIds).Find(&follows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	isFollowing := map[string]bool{}
+	for _, follow := range follows {
+		isFollowing[follow.ProfileId] = true
+	}
+
+	now := time.Now()
+	dbCtx4, dbCancel4 := configs.NewQueryContext()
+	defer dbCancel4()
+	var subscriptions []models.ProfileSubscriber
+	if err := configs.Database.WithContext(dbCtx4).Table("profile_subscribers").Where("subscriber_id = ? AND profile_id IN ? AND ((is_accepted = ? AND "+utils.UnexpiredSubscriptionCondition()+") OR (is_accepted = ? AND (pending_expires_at IS NULL OR pending_expires_at > ?)))", reqProfile.Id, profileIds, true, false, now).Find(&subscriptions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	isSubscribed := map[string]bool{}
+	for _, subscription := range subscriptions {
+		isSubscribed[subscription.ProfileId] = true
+	}
+
+	// Find the tiers subscriptions are requested to
+	dbCtx5, dbCancel5 := configs.NewQueryContext()
+	defer dbCancel5()
+	var tiers []models.SubscriptionTier
+	if err := configs.Database.WithContext(dbCtx5).Model(&models.SubscriptionTier{}).Where("id IN ?", tierIds).Find(&tiers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	tiersById := make(map[string]models.SubscriptionTier, len(tiers))
+	for _, tier := range tiers {
+		tiersById[tier.Id] = tier
+	}
+
+	newFollows := []models.ProfileFollower{}
+	newRequests := []models.ProfileSubscriber{}
+	requestExpiresAt := now.Add(requestExpiry)
+	for i, entry := range entries {
+		if results[i].Status != "" {
+			continue
+		}
+
+		profile, ok := profilesByUsername[entry.Username]
+		switch {
+		case !ok:
+			results[i].Status = importStatusNotFound
+		case profile.Id == reqProfile.Id:
+			results[i].Status = importStatusSelf
+		case isBlocked[profile.Id]:
+			results[i].Status = importStatusBlocked
+		case entry.Type == graphEntryFollow && isFollowing[profile.Id]:
+			results[i].Status = importStatusAlreadyFollowing
+		case entry.Type == graphEntryFollow:
+			newFollows = append(newFollows, models.ProfileFollower{
+				ProfileId:  profile.Id,
+				FollowerId: reqProfile.Id,
+				IsPending:  profile.IsPrivate,
+				CreatedAt:  now,
+			})
+			isFollowing[profile.Id] = true // the same profile can appear twice in an import
+			results[i].Status = importStatusFollowed
+			if profile.IsPrivate {
+				results[i].Status = importStatusFollowRequested
+			}
+		case isSubscribed[profile.Id]:
+			results[i].Status = importStatusAlreadySubscribed
+		default:
+			if entry.TierId != nil {
+				tier, ok := tiersById[*entry.TierId]
+				if !ok || tier.ProfileId != profile.Id {
+					results[i].Status = importStatusTierNotFound
+					continue
+				}
+				if tier.IsPaid() { // paid tiers are joined by paying, not by being accepted
+					results[i].Status = importStatusTierRequiresPayment
+					continue
+				}
+			}
+			newRequests = append(newRequests, models.ProfileSubscriber{
+				ProfileId:        profile.Id,
+				SubscriberId:     reqProfile.Id,
+				IsInvite:         false,
+				IsRequest:        true,
+				TierId:           entry.TierId,
+				PendingExpiresAt: &requestExpiresAt,
+			})
+			isSubscribed[profile.Id] = true
+			results[i].Status = importStatusSubscriptionRequested
+		}
+	}
+
+	// Create the follows and subscription requests. Expired requests and subscriptions to the same profiles are removed first so they can be sent again.
+	dbCtx6, dbCancel6 := configs.NewQueryContext()
+	defer dbCancel6()
+	if err := configs.Database.WithContext(dbCtx6).Transaction(func(tx *gorm.DB) error {
+		if len(newFollows) > 0 {
+			if err := tx.Table("profile_followers").Clauses(clause.OnConflict{DoNothing: true}).Create(&newFollows).Error; err != nil {
+				return err
+			}
+		}
+		if len(newRequests) > 0 {
+			var subscriberObj models.ProfileSubscriber
+			if err := tx.Table("profile_subscribers").Where("subscriber_id = ? AND profile_id IN ?", reqProfile.Id, profileIds).Delete(&subscriberObj, "(is_accepted = ? AND pending_expires_at <= ?) OR (is_accepted = ? AND NOT "+utils.UnexpiredSubscriptionCondition()+")", false, now, true).Error; err != nil {
+				return err
+			}
+			if err := tx.Table("profile_subscribers").Clauses(clause.OnConflict{DoNothing: true}).Create(&newRequests).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"num_imported": len(newFollows) + len(newRequests),
+			"results":      results,
+		},
+	}))
+}
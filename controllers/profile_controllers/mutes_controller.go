@@ -0,0 +1,174 @@
+package profilecontrollers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rivo/uniseg"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+)
+
+// Returns the time a mute ends. expiresIn is in seconds and nil means the mute does not expire.
+func muteExpiry(expiresIn *int64) *time.Time {
+	if expiresIn == nil {
+		return nil
+	}
+	expiresAt := time.Now().Add(time.Duration(*expiresIn) * time.Second)
+	return &expiresAt
+}
+
+func MuteAUser(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		ExpiresIn *int64 `json:"expires_in"` // in seconds, this is allowed to be nil
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if reqProfile.Id == c.Params("profileId") {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot mute yourself."}, nil))
+	}
+
+	if reqBody.ExpiresIn != nil && *reqBody.ExpiresIn <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Mute duration must be positive."}, nil))
+	}
+
+	// If the user is already muted, the expiry is replaced
+	newMuteObj := models.ProfileMute{
+		ProfileId: reqProfile.Id,
+		MutedId:   c.Params("profileId"),
+		CreatedAt: time.Now(),
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Table("profile_mutes").Where("profile_id = ? AND muted_id = ?", newMuteObj.ProfileId, newMuteObj.MutedId).Assign(map[string]interface{}{"expires_at": muteExpiry(reqBody.ExpiresIn)}).FirstOrCreate(&newMuteObj).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "User has been muted."}))
+}
+
+func UnmuteAUser(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Delete the object
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var muteObj models.ProfileMute
+	if err := configs.Database.WithContext(dbCtx).Table("profile_mutes").Delete(&muteObj, "profile_id = ? AND muted_id = ?", reqProfile.Id, c.Params("profileId")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "User has been unmuted."}))
+}
+
+func GetMuted(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
+	query := configs.Database.Table("profiles").
+		Select("profiles.id, profiles.username, profiles.name, profiles.mini_avatar, profile_mutes.expires_at").
+		Joins("JOIN profile_mutes ON profile_mutes.profile_id = ? AND profile_mutes.muted_id = profiles.id", reqProfile.Id).
+		Where("(profile_mutes.expires_at IS NULL OR profile_mutes.expires_at > ?) AND username LIKE ?", time.Now(), regexMatch)
+
+	// Get muted profiles(paginated)
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var muted = []responses.MutedProfile{}
+	if err := query.WithContext(dbCtx).Order("profile_mutes.created_at DESC").Limit(limit).Offset(offset).Scan(&muted).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Get total number of muted profiles
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numMuted int64
+	if err := query.WithContext(dbCtx2).Count(&numMuted).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numMuted) / float64(limit))),
+			"data":         muted,
+		},
+	}))
+}
+
+func AddMutedKeyword(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		Keyword   string `json:"keyword"`
+		ExpiresIn *int64 `json:"expires_in"` // in seconds, this is allowed to be nil
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	reqBody.Keyword = strings.ToLower(strings.TrimSpace(reqBody.Keyword)) // keywords are matched case insensitively so they are stored in lower case
+
+	if reqBody.Keyword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	if uniseg.GraphemeClusterCount(reqBody.Keyword) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Keyword is too long."}, nil))
+	}
+
+	if reqBody.ExpiresIn != nil && *reqBody.ExpiresIn <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Mute duration must be positive."}, nil))
+	}
+
+	// If the keyword is already muted, the expiry is replaced
+	newKeywordObj := models.MutedKeyword{
+		ProfileId: reqProfile.Id,
+		Keyword:   reqBody.Keyword,
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Model(&models.MutedKeyword{}).Where("profile_id = ? AND keyword = ?", newKeywordObj.ProfileId, newKeywordObj.Keyword).Assign(map[string]interface{}{"expires_at": muteExpiry(reqBody.ExpiresIn)}).FirstOrCreate(&newKeywordObj).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": newKeywordObj}))
+}
+
+func RemoveMutedKeyword(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Delete the object
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Delete(&models.MutedKeyword{}, "profile_id = ? AND id = ?", reqProfile.Id, c.Params("keywordId")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Keyword has been unmuted."}))
+}
+
+func GetMutedKeywords(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Find the keywords that haven't expired
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var keywords = []models.MutedKeyword{}
+	if err := configs.Database.WithContext(dbCtx).Model(&reqProfile).Where("expires_at IS NULL OR expires_at > ?", time.Now()).Order("muted_keywords.created_at DESC").Association("MutedKeywords").Find(&keywords); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": keywords}))
+}
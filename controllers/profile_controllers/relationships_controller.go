@@ -0,0 +1,104 @@
+package profilecontrollers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	maxRelationshipIds = 100
+)
+
+// Returns the profiles the request user follows that also follow profileId
+func GetMutualFollowers(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
+	query := configs.Database.Table("profiles").
+		Joins("JOIN profile_followers ON profile_followers.profile_id = ? AND profile_followers.follower_id = profiles.id AND profile_followers.is_pending = false", c.Params("profileId")).
+		Joins("JOIN profile_followers AS following ON following.follower_id = ? AND following.profile_id = profiles.id AND following.is_pending = false", reqProfile.Id).
+		Where("username LIKE ?", regexMatch).
+		Where(utils.NotBlockedCondition("profiles.id"), reqProfile.Id, reqProfile.Id)
+
+	// Get mutual followers(paginated)
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var mutualFollowers = []responses.MiniProfile{}
+	if err := query.WithContext(dbCtx).Select("profiles.id, profiles.username, profiles.name, profiles.mini_avatar").Order("profile_followers.created_at DESC").Limit(limit).Offset(offset).Scan(&mutualFollowers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// Get total number of mutual followers
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var numMutualFollowers int64
+	if err := query.WithContext(dbCtx2).Count(&numMutualFollowers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numMutualFollowers) / float64(limit))),
+			"data":         mutualFollowers,
+		},
+	}))
+}
+
+// Returns how the request user is related to each of the comma separated profile ids in the "ids" query
+func GetRelationships(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	ids := []string{}
+	for _, id := range strings.Split(c.Query("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	if len(ids) > maxRelationshipIds {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": fmt.Sprintf("You can request at most %d profiles.", maxRelationshipIds)}, nil))
+	}
+
+	query := "SELECT profiles.id AS profile_id, "
+	query += "EXISTS (SELECT 1 FROM profile_followers WHERE profile_id = profiles.id AND follower_id = @viewer AND is_pending = false) AS is_following, "
+	query += "EXISTS (SELECT 1 FROM profile_followers WHERE profile_id = profiles.id AND follower_id = @viewer AND is_pending = true) AS is_follow_requested, "
+	query += "EXISTS (SELECT 1 FROM profile_followers WHERE profile_id = @viewer AND follower_id = profiles.id AND is_pending = false) AS is_followed_by, "
+	query += "EXISTS (SELECT 1 FROM profile_subscribers WHERE profile_id = profiles.id AND subscriber_id = @viewer AND is_accepted = true AND " + utils.UnexpiredSubscriptionCondition() + ") AS is_subscribed, "
+	query += "EXISTS (SELECT 1 FROM profile_subscribers WHERE profile_id = @viewer AND subscriber_id = profiles.id AND is_accepted = true AND " + utils.UnexpiredSubscriptionCondition() + ") AS is_subscriber, "
+	query += "EXISTS (SELECT 1 FROM profile_blocks WHERE profile_id = @viewer AND blocked_id = profiles.id) AS is_blocked, "
+	query += "EXISTS (SELECT 1 FROM profile_mutes WHERE profile_id = @viewer AND muted_id = profiles.id AND (expires_at IS NULL OR expires_at > NOW())) AS is_muted "
+	query += "FROM profiles WHERE profiles.id IN @ids;"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var relationships = []responses.Relationship{}
+	if err := configs.Database.WithContext(dbCtx).Raw(query, map[string]interface{}{"viewer": reqProfile.Id, "ids": ids}).Scan(&relationships).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": relationships}))
+}
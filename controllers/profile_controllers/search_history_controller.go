@@ -1,53 +1,116 @@
 package profilecontrollers
 
 import (
+	"log"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
 	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	maxSearchHistory = 20 // number of entries kept per profile, older ones are trimmed
 )
 
 func AddToSearchHistory(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 	reqBody := struct {
-		Query string `json:"query"`
+		Type      string `json:"type"`
+		Query     string `json:"query"`
+		ProfileId string `json:"profile_id"`
 	}{}
 
 	if err := c.BodyParser(&reqBody); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
 	}
 
+	if reqBody.Type == "" {
+		reqBody.Type = models.SearchTypeQuery
+	}
+
+	newHistoryObj := models.SearchHistory{
+		ProfileId: reqProfile.Id,
+		Type:      reqBody.Type,
+	}
+	switch reqBody.Type {
+	case models.SearchTypeQuery:
+		newHistoryObj.Query = strings.ToLower(strings.Join(strings.Fields(reqBody.Query), " ")) // remove leading, trailing and repeated whitespace, "Foo" and "foo" are the same search
+	case models.SearchTypeHashtag:
+		newHistoryObj.Query = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(reqBody.Query), "#"))
+		if strings.ContainsAny(newHistoryObj.Query, " \t\n#") {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid hashtag."}, nil))
+		}
+	case models.SearchTypeProfile:
+		newHistoryObj.SearchedProfileId = reqBody.ProfileId
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid search type."}, nil))
+	}
+
 	// Check if all fields are included
-	if reqBody.Query == "" {
+	if newHistoryObj.Query == "" && newHistoryObj.SearchedProfileId == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
 	}
+	if uniseg.GraphemeClusterCount(newHistoryObj.Query) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Search is too long."}, nil))
+	}
 
-	reqBody.Query = strings.TrimSpace(reqBody.Query) // remove leading and trailing whitespace
+	if newHistoryObj.Type == models.SearchTypeProfile {
+		isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, newHistoryObj.SearchedProfileId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if isBlocked {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+		}
 
-	// Get length of history
-	dbCtx, dbCancel := configs.NewQueryContext()
-	defer dbCancel()
-	historyLength := configs.Database.WithContext(dbCtx).Model(&reqProfile).Association("SearchHistory").Count()
-	if historyLength > 20 { // delete bottom 8
 		dbCtx, dbCancel := configs.NewQueryContext()
 		defer dbCancel()
-		if err := configs.Database.WithContext(dbCtx).Model(&models.SearchHistory{}).Limit(8).Order("created_at ASC").Delete(&models.SearchHistory{}, "profile_id = ?", reqProfile.Id).Error; err != nil {
+		var numProfiles int64
+		if err := configs.Database.WithContext(dbCtx).Model(&models.Profile{}).Where("id = ?", newHistoryObj.SearchedProfileId).Count(&numProfiles).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 		}
+		if numProfiles == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Account not found."}, nil))
+		}
 	}
 
-	newHistoryObj := models.SearchHistory{
-		ProfileId: reqProfile.Id,
-		Query:     reqBody.Query,
-	}
+	// A search that is already in the history is moved to the top. The history is trimmed in the same transaction so it never grows past maxSearchHistory.
+	newHistoryObj.SearchedAt = time.Now()
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
-	if err := configs.Database.WithContext(dbCtx2).Table("search_histories").Create(&newHistoryObj).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx2).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "profile_id"}, {Name: "type"}, {Name: "query"}, {Name: "searched_profile_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"searched_at", "updated_at"}),
+		}).Create(&newHistoryObj).Error; err != nil {
+			return err
+		}
+
+		query := "DELETE FROM search_histories WHERE profile_id = ? AND id NOT IN "
+		query += "(SELECT id FROM search_histories WHERE profile_id = ? ORDER BY searched_at DESC LIMIT ?);"
+		return tx.Exec(query, reqProfile.Id, reqProfile.Id, maxSearchHistory).Error
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
+	// Only what was typed counts towards trending searches, not which profiles were clicked
+	term := newHistoryObj.Query
+	if newHistoryObj.Type == models.SearchTypeHashtag {
+		term = "#" + term
+	}
+	if newHistoryObj.Type != models.SearchTypeProfile {
+		if err := utils.RecordTrendingSearch(reqProfile.Id, strings.ToLower(term)); err != nil {
+			log.Printf("search history: could not record trending search: %v", err)
+		}
+	}
+
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Search added to history"}))
 }
 
@@ -86,9 +149,44 @@ func GetSearchHistory(c *fiber.Ctx) error {
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
 	var history []models.SearchHistory
-	if err := configs.Database.WithContext(dbCtx).Model(&reqProfile).Order("search_histories.created_at DESC").Association("SearchHistory").Find(&history); err != nil {
+	if err := configs.Database.WithContext(dbCtx).Model(&reqProfile).Order("search_histories.searched_at DESC").Association("SearchHistory").Find(&history); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
-	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": history}))
+	// Find the profiles clicked in the history
+	profileIds := []string{}
+	for _, entry := range history {
+		if entry.Type == models.SearchTypeProfile {
+			profileIds = append(profileIds, entry.SearchedProfileId)
+		}
+	}
+	profiles := map[string]*responses.MiniProfile{}
+	if len(profileIds) > 0 {
+		dbCtx2, dbCancel2 := configs.NewQueryContext()
+		defer dbCancel2()
+		var miniProfiles []responses.MiniProfile
+		if err := configs.Database.WithContext(dbCtx2).Table("profiles").Where("id IN ? AND "+utils.NotBlockedCondition("profiles.id"), profileIds, reqProfile.Id, reqProfile.Id).Scan(&miniProfiles).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		for i := range miniProfiles {
+			profiles[miniProfiles[i].Id] = &miniProfiles[i]
+		}
+	}
+
+	entries := []responses.SearchHistoryEntry{}
+	for _, entry := range history {
+		profile := profiles[entry.SearchedProfileId]
+		if entry.Type == models.SearchTypeProfile && profile == nil { // the profile was deleted or there is a block between the profiles
+			continue
+		}
+		entries = append(entries, responses.SearchHistoryEntry{
+			Id:         entry.Id,
+			Type:       entry.Type,
+			Query:      entry.Query,
+			Profile:    profile,
+			SearchedAt: entry.SearchedAt,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": entries}))
 }
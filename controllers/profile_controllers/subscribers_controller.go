@@ -3,39 +3,255 @@ package profilecontrollers
 import (
 	"fmt"
 	"math"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
 	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
 )
 
+const (
+	defaultInviteExpiry = time.Hour * 24 * 7
+	maxInviteExpiry     = time.Hour * 24 * 90
+	requestExpiry       = time.Hour * 24 * 30
+	maxBulkInvites      = 1000 // number of invites a single bulk invite sends at most
+	maxAccessDuration   = time.Hour * 24 * 365
+)
+
+// SQL expressions for when an accepted subscription ends, when it is charged again and how many seconds it has left
+const (
+	subscriptionExpiresAtSelect = "CASE WHEN profile_subscribers.expires_at IS NOT NULL THEN profile_subscribers.expires_at WHEN profile_subscribers.cancel_at_period_end THEN profile_subscribers.current_period_end END"
+	subscriptionRenewsAtSelect  = "CASE WHEN profile_subscribers.cancel_at_period_end = false THEN profile_subscribers.current_period_end END"
+	subscriptionSelect          = "profiles.id, profiles.username, profiles.name, profiles.mini_avatar, profile_subscribers.tier_id, profile_subscribers.requested_tier_id, profile_subscribers.created_at, " +
+		subscriptionExpiresAtSelect + " AS expires_at, " + subscriptionRenewsAtSelect + " AS renews_at, " +
+		"GREATEST(EXTRACT(EPOCH FROM (" + subscriptionExpiresAtSelect + ") - NOW()), 0)::bigint AS remaining_seconds"
+)
+
+// Returns when an invite expires. expiresIn is in seconds and nil means the default expiry. The message is empty if expiresIn is valid.
+func inviteExpiry(expiresIn *int64) (time.Time, string) {
+	if expiresIn == nil {
+		return time.Now().Add(defaultInviteExpiry), ""
+	}
+	if *expiresIn <= 0 {
+		return time.Time{}, "Invite duration must be positive."
+	}
+	if time.Duration(*expiresIn)*time.Second > maxInviteExpiry {
+		return time.Time{}, fmt.Sprintf("Invites can last at most %d days.", int(maxInviteExpiry.Hours()/24))
+	}
+	return time.Now().Add(time.Duration(*expiresIn) * time.Second), ""
+}
+
+// Checks how long a time limited subscription lasts. duration is in seconds and nil means the subscription doesn't end. The message is empty if duration is valid.
+func validateAccessDuration(duration *int64) string {
+	if duration == nil {
+		return ""
+	}
+	if *duration <= 0 {
+		return "Subscription duration must be positive."
+	}
+	if time.Duration(*duration)*time.Second > maxAccessDuration {
+		return fmt.Sprintf("Subscriptions can last at most %d days.", int(maxAccessDuration.Hours()/24))
+	}
+	return ""
+}
+
+// Deletes the invite or request between the two profiles if it expired, or the subscription if it expired and hasn't been removed by the expiry job yet,
+// so a new one can take its place
+func deleteExpiredPending(profileId, subscriberId string) error {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var subscriberObj models.ProfileSubscriber
+	return configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ?", profileId, subscriberId).
+		Delete(&subscriberObj, "(is_accepted = ? AND pending_expires_at <= ?) OR (is_accepted = ? AND NOT "+utils.UnexpiredSubscriptionCondition()+")", false, time.Now(), true).Error
+}
+
+// Returns true if subscriberId has an accepted subscription to profileId that hasn't expired
+func hasSubscription(profileId, subscriberId string) (bool, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var numSubscriptions int64
+	if err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_accepted = ? AND "+utils.UnexpiredSubscriptionCondition(), profileId, subscriberId, true).Count(&numSubscriptions).Error; err != nil {
+		return false, err
+	}
+	return numSubscriptions > 0, nil
+}
+
 func InviteToSubscribersList(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		TierId         *string `json:"tier_id"` // this is allowed to be nil
+		Message        string  `json:"message"`
+		ExpiresIn      *int64  `json:"expires_in"`      // in seconds, this is allowed to be nil
+		AccessDuration *int64  `json:"access_duration"` // in seconds, this is allowed to be nil
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
 
 	if reqProfile.Id == c.Params("profileId") {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot invite yourself."}, nil))
 	}
 
+	reqBody.Message = strings.TrimSpace(reqBody.Message) // remove leading and trailing whitespace
+	if uniseg.GraphemeClusterCount(reqBody.Message) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Message is too long."}, nil))
+	}
+
+	expiresAt, errMessage := inviteExpiry(reqBody.ExpiresIn)
+	if errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	if errMessage := validateAccessDuration(reqBody.AccessDuration); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	// The invite can only target one of the tiers of the profile being subscribed to
+	if reqBody.TierId != nil {
+		tier, err := findOwnedTier(reqProfile.Id, *reqBody.TierId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if tier.Id == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier not found."}, nil))
+		}
+	}
+
+	// Subscribers that want another tier have to change their tier instead
+	isSubscribed, err := hasSubscription(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isSubscribed {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This user is already subscribed to you."}, nil))
+	}
+
+	if err := deleteExpiredPending(reqProfile.Id, c.Params("profileId")); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	// More info on this query: https://gorm.io/docs/advanced_query.html#FirstOrCreate
+	// An invite that already exists keeps its other fields but takes the tier of the new invite. A pending request of the user keeps the tier
+	// the user asked for, the request user accepts or declines it instead.
 
 	newSubscriberObj := models.ProfileSubscriber{
 		ProfileId:    reqProfile.Id,
 		SubscriberId: c.Params("profileId"),
 	}
 	newSubscriberObjAttributes := models.ProfileSubscriber{
-		IsInvite:  true,
-		IsRequest: false,
+		IsInvite:         true,
+		IsRequest:        false,
+		PendingExpiresAt: &expiresAt,
+		Message:          reqBody.Message,
+		AccessDuration:   reqBody.AccessDuration,
+		TierId:           reqBody.TierId,
 	}
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where(newSubscriberObj).Attrs(newSubscriberObjAttributes).FirstOrCreate(&newSubscriberObj).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("profile_subscribers").Where(newSubscriberObj).Attrs(newSubscriberObjAttributes).FirstOrCreate(&newSubscriberObj).Error; err != nil {
+			return err
+		}
+		return tx.Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_invite = ? AND is_accepted = ?", reqProfile.Id, c.Params("profileId"), true, false).Update("tier_id", reqBody.TierId).Error
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Invite has been sent."}))
 }
 
+/*
+Invites many followers of the request user at once. followed_for_days limits the invites to followers that have followed for at least that many days.
+
+Followers that are already subscribed or have a pending invite or request, and followers with a block between them and the request user, are skipped.
+At most maxBulkInvites invites are sent per call, starting with the oldest followers.
+*/
+func BulkInviteToSubscribersList(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		TierId          *string `json:"tier_id"` // this is allowed to be nil
+		Message         string  `json:"message"`
+		ExpiresIn       *int64  `json:"expires_in"`      // in seconds, this is allowed to be nil
+		AccessDuration  *int64  `json:"access_duration"` // in seconds, this is allowed to be nil
+		FollowedForDays int     `json:"followed_for_days"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	reqBody.Message = strings.TrimSpace(reqBody.Message) // remove leading and trailing whitespace
+	if uniseg.GraphemeClusterCount(reqBody.Message) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Message is too long."}, nil))
+	}
+
+	if reqBody.FollowedForDays < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Number of days cannot be negative."}, nil))
+	}
+
+	expiresAt, errMessage := inviteExpiry(reqBody.ExpiresIn)
+	if errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	if errMessage := validateAccessDuration(reqBody.AccessDuration); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	if reqBody.TierId != nil {
+		tier, err := findOwnedTier(reqProfile.Id, *reqBody.TierId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if tier.Id == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier not found."}, nil))
+		}
+	}
+
+	now := time.Now()
+	followedBefore := now.AddDate(0, 0, -reqBody.FollowedForDays)
+
+	query := "INSERT INTO profile_subscribers (profile_id, subscriber_id, is_invite, is_request, is_accepted, tier_id, cancel_at_period_end, pending_expires_at, message, access_duration, expiry_notified, created_at) "
+	query += "SELECT profile_followers.profile_id, profile_followers.follower_id, true, false, false, ?, false, ?, ?, ?, false, ? FROM profile_followers "
+	query += "WHERE profile_followers.profile_id = ? AND profile_followers.is_pending = false AND profile_followers.created_at <= ? AND " + utils.NotBlockedCondition("profile_followers.follower_id") + " "
+	query += "AND NOT EXISTS (SELECT 1 FROM profile_subscribers WHERE profile_subscribers.profile_id = profile_followers.profile_id AND profile_subscribers.subscriber_id = profile_followers.follower_id) "
+	query += "ORDER BY profile_followers.created_at LIMIT ? "
+	query += "ON CONFLICT DO NOTHING;"
+
+	// Expired invites and requests are removed first so those followers can be invited again
+	var numInvited int64
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		var subscriberObj models.ProfileSubscriber
+		if err := tx.Table("profile_subscribers").Delete(&subscriberObj, "profile_id = ? AND is_accepted = ? AND pending_expires_at <= ?", reqProfile.Id, false, now).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(query, reqBody.TierId, expiresAt, reqBody.Message, reqBody.AccessDuration, now, reqProfile.Id, followedBefore, reqProfile.Id, reqProfile.Id, maxBulkInvites)
+		numInvited = result.RowsAffected
+		return result.Error
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": &fiber.Map{"num_invited": numInvited}}))
+}
+
 func CancelInviteToSubscribersList(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
@@ -55,8 +271,16 @@ func AcceptInviteToSubscribersList(c *fiber.Ctx) error {
 
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_invite = ? AND is_accepted = ?", c.Params("senderId"), reqProfile.Id, true, false).Update("is_accepted", "1").Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	result := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_invite = ? AND is_accepted = ? AND (pending_expires_at IS NULL OR pending_expires_at > ?)", c.Params("senderId"), reqProfile.Id, true, false, time.Now()).Updates(map[string]interface{}{
+		"is_accepted":        true,
+		"pending_expires_at": nil,
+		"expires_at":         gorm.Expr("NOW() + access_duration * INTERVAL '1 second'"), // stays NULL when the invite has no access duration
+	})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This invite does not exist or has expired."}, nil))
 	}
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Invite has been accepted."}))
@@ -78,24 +302,76 @@ func DeclineInviteToSubscribersList(c *fiber.Ctx) error {
 
 func RequestToSubscribe(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		TierId *string `json:"tier_id"` // this is allowed to be nil
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
 
 	if reqProfile.Id == c.Params("profileId") {
 		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot request yourself."}, nil))
 	}
 
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	// The request can only target one of the tiers of the profile being subscribed to
+	if reqBody.TierId != nil {
+		tier, err := findOwnedTier(c.Params("profileId"), *reqBody.TierId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if tier.Id == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier not found."}, nil))
+		}
+		if tier.IsPaid() { // paid tiers are joined by paying, not by being accepted
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This tier requires payment."}, nil))
+		}
+	}
+
+	// Subscribers that want another tier have to change their tier instead
+	isSubscribed, err := hasSubscription(c.Params("profileId"), reqProfile.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isSubscribed {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You are already subscribed to this user."}, nil))
+	}
+
+	if err := deleteExpiredPending(c.Params("profileId"), reqProfile.Id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
 	// More info on this query: https://gorm.io/docs/advanced_query.html#FirstOrCreate
+	// A request that already exists keeps its other fields but takes the tier of the new request. A pending invite from the profile keeps the tier
+	// it offers, the request user accepts or declines it instead.
 
+	expiresAt := time.Now().Add(requestExpiry)
 	newSubscriberObj := models.ProfileSubscriber{
 		ProfileId:    c.Params("profileId"),
 		SubscriberId: reqProfile.Id,
 	}
 	newSubscriberObjAttributes := models.ProfileSubscriber{
-		IsInvite:  false,
-		IsRequest: true,
+		IsInvite:         false,
+		IsRequest:        true,
+		PendingExpiresAt: &expiresAt,
+		TierId:           reqBody.TierId,
 	}
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where(newSubscriberObj).Attrs(newSubscriberObjAttributes).FirstOrCreate(&newSubscriberObj).Error; err != nil {
+	if err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("profile_subscribers").Where(newSubscriberObj).Attrs(newSubscriberObjAttributes).FirstOrCreate(&newSubscriberObj).Error; err != nil {
+			return err
+		}
+		return tx.Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_request = ? AND is_accepted = ?", c.Params("profileId"), reqProfile.Id, true, false).Update("tier_id", reqBody.TierId).Error
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -118,11 +394,37 @@ func CancelRequestToSubscribe(c *fiber.Ctx) error {
 
 func AcceptRequestToSubscribe(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		AccessDuration *int64 `json:"access_duration"` // in seconds, this is allowed to be nil
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if errMessage := validateAccessDuration(reqBody.AccessDuration); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	var expiresAt *time.Time
+	if reqBody.AccessDuration != nil {
+		t := time.Now().Add(time.Duration(*reqBody.AccessDuration) * time.Second)
+		expiresAt = &t
+	}
 
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	if err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_request = ? AND is_accepted = ?", reqProfile.Id, c.Params("senderId"), true, false).Update("is_accepted", "1").Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	result := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_request = ? AND is_accepted = ? AND (pending_expires_at IS NULL OR pending_expires_at > ?)", reqProfile.Id, c.Params("senderId"), true, false, time.Now()).Updates(map[string]interface{}{
+		"is_accepted":        true,
+		"pending_expires_at": nil,
+		"access_duration":    reqBody.AccessDuration,
+		"expires_at":         expiresAt,
+	})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This request does not exist or has expired."}, nil))
 	}
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Request has been accepted."}))
@@ -160,6 +462,99 @@ func RemoveASubscriber(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Subscriber has been removed."}))
 }
 
+/*
+Restarts a time limited subscription of one of the request user's subscribers so it runs for duration seconds from now. Without a duration
+the subscription runs for as long as it was last given. A subscription that doesn't end can be made time limited by passing a duration.
+
+Paid subscriptions end when they stop being paid for and can't be renewed here.
+*/
+func RenewSubscription(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		Duration *int64 `json:"duration"` // in seconds, this is allowed to be nil
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if errMessage := validateAccessDuration(reqBody.Duration); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var subscriberObj models.ProfileSubscriber
+	if err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Find(&subscriberObj, "profile_id = ? AND subscriber_id = ? AND is_accepted = ? AND current_period_end IS NULL", reqProfile.Id, c.Params("profileId"), true).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if subscriberObj.ProfileId == "" || subscriberObj.SubscriberId == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This subscription does not exist or is paid for."}, nil))
+	}
+
+	duration := reqBody.Duration
+	if duration == nil {
+		duration = subscriberObj.AccessDuration
+	}
+	if duration == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include a duration."}, nil))
+	}
+	expiresAt := time.Now().Add(time.Duration(*duration) * time.Second)
+
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_accepted = ? AND current_period_end IS NULL", reqProfile.Id, c.Params("profileId"), true).Updates(map[string]interface{}{
+		"access_duration": duration,
+		"expires_at":      expiresAt,
+		"expiry_notified": false,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": &fiber.Map{"expires_at": expiresAt}}))
+}
+
+// Adds extend_by seconds to a time limited subscription of one of the request user's subscribers. A subscription that already expired is extended from now.
+func ExtendSubscription(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		ExtendBy *int64 `json:"extend_by"` // in seconds
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	if reqBody.ExtendBy == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+	if errMessage := validateAccessDuration(reqBody.ExtendBy); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	result := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_accepted = ? AND expires_at IS NOT NULL", reqProfile.Id, c.Params("profileId"), true).Updates(map[string]interface{}{
+		"expires_at":      gorm.Expr("GREATEST(expires_at, NOW()) + ? * INTERVAL '1 second'", *reqBody.ExtendBy),
+		"expiry_notified": false,
+	})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This subscription does not exist or does not expire."}, nil))
+	}
+
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var subscriberObj models.ProfileSubscriber
+	if err := configs.Database.WithContext(dbCtx2).Table("profile_subscribers").Find(&subscriberObj, "profile_id = ? AND subscriber_id = ?", reqProfile.Id, c.Params("profileId")).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": &fiber.Map{"expires_at": subscriberObj.ExpiresAt}}))
+}
+
 func UnsubscribeFromUser(c *fiber.Ctx) error {
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
@@ -184,19 +579,27 @@ func GetSubscribers(c *fiber.Ctx) error {
 	var offset int = c.Locals("offset").(int)
 	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
 
+	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
+	query := configs.Database.Table("profiles").
+		Joins("JOIN profile_subscribers ON profile_subscribers.profile_id = ? AND profile_subscribers.subscriber_id = profiles.id", reqProfile.Id).
+		Where("is_accepted = ? AND "+utils.UnexpiredSubscriptionCondition(), true).
+		Where("username LIKE ?", regexMatch)
+
 	// Get subscribers(paginated)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
-	var subscribers = []responses.MiniProfile{}
-	if err := configs.Database.WithContext(dbCtx).Model(&reqProfile).Offset(offset).Limit(limit).Order("profile_subscribers.created_at DESC").Where("is_accepted = ?", true).Where("username LIKE ?", regexMatch).Association("Subscribers").Find(&subscribers); err != nil {
+	var subscribers = []responses.Subscription{}
+	if err := query.WithContext(dbCtx).Select(subscriptionSelect).Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&subscribers).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
 	// Get total number of subscribers
 	dbCtx2, dbCancel2 := configs.NewQueryContext()
 	defer dbCancel2()
-	numSubscribers := configs.Database.WithContext(dbCtx2).Model(&reqProfile).Where("is_accepted = ?", true).Where("username LIKE ?", regexMatch).Association("Subscribers").Count()
+	var numSubscribers int64
+	if err := query.WithContext(dbCtx2).Count(&numSubscribers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
 
 	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
 		"data": &fiber.Map{
@@ -223,14 +626,14 @@ func GetSubscriptions(c *fiber.Ctx) error {
 	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
 	query := configs.Database.Table("profiles").
 		Joins("JOIN profile_subscribers ON profile_subscribers.subscriber_id = ? AND profile_subscribers.profile_id = profiles.id", reqProfile.Id).
-		Where("is_accepted = ?", true).
+		Where("is_accepted = ? AND "+utils.UnexpiredSubscriptionCondition(), true).
 		Where("username LIKE ?", regexMatch)
 
 	// Get subscriptions(paginated)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	var subscriptions = []responses.MiniProfile{}
-	if err := query.WithContext(dbCtx).Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&subscriptions).Error; err != nil {
+	var subscriptions = []responses.Subscription{}
+	if err := query.WithContext(dbCtx).Select(subscriptionSelect).Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&subscriptions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -266,13 +669,13 @@ func GetInvitesSent(c *fiber.Ctx) error {
 	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
 	query := configs.Database.Table("profiles").
 		Joins("JOIN profile_subscribers ON profile_subscribers.subscriber_id = profiles.id AND profile_subscribers.profile_id = ?", reqProfile.Id).
-		Where("is_accepted = ? AND is_invite = ? AND username LIKE ?", false, true, regexMatch)
+		Where("is_accepted = ? AND is_invite = ? AND username LIKE ? AND (pending_expires_at IS NULL OR pending_expires_at > ?)", false, true, regexMatch, time.Now())
 
 	// Get invites sent(paginated)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	var invitesSent = []responses.MiniProfile{}
-	if err := query.WithContext(dbCtx).Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&invitesSent).Error; err != nil {
+	var invitesSent = []responses.PendingSubscription{}
+	if err := query.WithContext(dbCtx).Select("profiles.id, profiles.username, profiles.name, profiles.mini_avatar, profile_subscribers.tier_id, profile_subscribers.message, profile_subscribers.pending_expires_at AS expires_at, profile_subscribers.created_at").Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&invitesSent).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -308,13 +711,13 @@ func GetInvitesReceived(c *fiber.Ctx) error {
 	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
 	query := configs.Database.Table("profiles").
 		Joins("JOIN profile_subscribers ON profile_subscribers.subscriber_id = ? AND profile_subscribers.profile_id = profiles.id", reqProfile.Id).
-		Where("is_accepted = ? AND is_invite = ? AND username LIKE ?", false, true, regexMatch)
+		Where("is_accepted = ? AND is_invite = ? AND username LIKE ? AND (pending_expires_at IS NULL OR pending_expires_at > ?)", false, true, regexMatch, time.Now())
 
 	// Get invites sent(paginated)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	var invitesReceived = []responses.MiniProfile{}
-	if err := query.WithContext(dbCtx).Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&invitesReceived).Error; err != nil {
+	var invitesReceived = []responses.PendingSubscription{}
+	if err := query.WithContext(dbCtx).Select("profiles.id, profiles.username, profiles.name, profiles.mini_avatar, profile_subscribers.tier_id, profile_subscribers.message, profile_subscribers.pending_expires_at AS expires_at, profile_subscribers.created_at").Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&invitesReceived).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -350,13 +753,13 @@ func GetRequestsSent(c *fiber.Ctx) error {
 	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
 	query := configs.Database.Table("profiles").
 		Joins("JOIN profile_subscribers ON profile_subscribers.subscriber_id = ? AND profile_subscribers.profile_id = profiles.id", reqProfile.Id).
-		Where("is_accepted = ? AND is_request = ? AND username LIKE ?", false, true, regexMatch)
+		Where("is_accepted = ? AND is_request = ? AND username LIKE ? AND (pending_expires_at IS NULL OR pending_expires_at > ?)", false, true, regexMatch, time.Now())
 
 	// Get requests sent(paginated)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	var requestsSent = []responses.MiniProfile{}
-	if err := query.WithContext(dbCtx).Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&requestsSent).Error; err != nil {
+	var requestsSent = []responses.PendingSubscription{}
+	if err := query.WithContext(dbCtx).Select("profiles.id, profiles.username, profiles.name, profiles.mini_avatar, profile_subscribers.tier_id, profile_subscribers.message, profile_subscribers.pending_expires_at AS expires_at, profile_subscribers.created_at").Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&requestsSent).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
@@ -392,13 +795,13 @@ func GetRequestsReceived(c *fiber.Ctx) error {
 	regexMatch := fmt.Sprintf("%s%%", c.Query("filter")) // for more information on regex matching in sql, visit https://www.freecodecamp.org/news/sql-contains-string-sql-regex-example-query/
 	query := configs.Database.Table("profiles").
 		Joins("JOIN profile_subscribers ON profile_subscribers.profile_id = ? AND profile_subscribers.subscriber_id = profiles.id", reqProfile.Id).
-		Where("is_accepted = ? AND is_request = ? AND username LIKE ?", false, true, regexMatch)
+		Where("is_accepted = ? AND is_request = ? AND username LIKE ? AND (pending_expires_at IS NULL OR pending_expires_at > ?)", false, true, regexMatch, time.Now())
 
 	// Get requests sent(paginated)
 	dbCtx, dbCancel := configs.NewQueryContext()
 	defer dbCancel()
-	var requestsReceived = []responses.MiniProfile{}
-	if err := query.WithContext(dbCtx).Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&requestsReceived).Error; err != nil {
+	var requestsReceived = []responses.PendingSubscription{}
+	if err := query.WithContext(dbCtx).Select("profiles.id, profiles.username, profiles.name, profiles.mini_avatar, profile_subscribers.tier_id, profile_subscribers.message, profile_subscribers.pending_expires_at AS expires_at, profile_subscribers.created_at").Order("profile_subscribers.created_at DESC").Limit(limit).Offset(offset).Scan(&requestsReceived).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
 	}
 
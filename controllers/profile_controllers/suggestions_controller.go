@@ -0,0 +1,93 @@
+package profilecontrollers
+
+import (
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+func GetSuggestions(c *fiber.Ctx) error {
+	var page int = c.Locals("page").(int)
+	var limit int = c.Locals("limit").(int)
+	var offset int = c.Locals("offset").(int)
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// Suggestions are precomputed by a background job, see utils/suggestions.go
+	suggestions, err := utils.GetSuggestions(reqProfile.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	suggestedIds := make([]string, len(suggestions))
+	for i, suggestion := range suggestions {
+		suggestedIds[i] = suggestion.ProfileId
+	}
+
+	// The cached suggestions may be stale so remove the profiles that have since been followed, blocked or dismissed
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var profiles = []responses.MiniProfile{}
+	if len(suggestedIds) > 0 {
+		if err := configs.Database.WithContext(dbCtx).Table("profiles").
+			Select("profiles.id, profiles.username, profiles.name, profiles.mini_avatar").
+			Where("profiles.id IN ?", suggestedIds).
+			Where("NOT EXISTS (SELECT 1 FROM profile_followers WHERE profile_followers.profile_id = profiles.id AND profile_followers.follower_id = ?)", reqProfile.Id).
+			Where("NOT EXISTS (SELECT 1 FROM profile_suggestion_dismissals WHERE profile_suggestion_dismissals.profile_id = ? AND profile_suggestion_dismissals.dismissed_id = profiles.id)", reqProfile.Id).
+			Where(utils.NotBlockedCondition("profiles.id"), reqProfile.Id, reqProfile.Id).
+			Scan(&profiles).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+	}
+
+	// Put the remaining profiles back in ranked order
+	profilesById := make(map[string]responses.MiniProfile, len(profiles))
+	for _, profile := range profiles {
+		profilesById[profile.Id] = profile
+	}
+	var ranked = []responses.SuggestedProfile{}
+	for _, suggestion := range suggestions {
+		if profile, ok := profilesById[suggestion.ProfileId]; ok {
+			ranked = append(ranked, responses.SuggestedProfile{MiniProfile: profile, NumMutuals: suggestion.NumMutuals})
+		}
+	}
+
+	numSuggestions := len(ranked)
+	start, end := offset, offset+limit
+	if start > numSuggestions {
+		start = numSuggestions
+	}
+	if end > numSuggestions {
+		end = numSuggestions
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{
+		"data": &fiber.Map{
+			"current_page": page,
+			"per_page":     limit,
+			"last_page":    int(math.Ceil(float64(numSuggestions) / float64(limit))),
+			"data":         ranked[start:end],
+		},
+	}))
+}
+
+func DismissSuggestion(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	newDismissalObj := models.ProfileSuggestionDismissal{
+		ProfileId:   reqProfile.Id,
+		DismissedId: c.Params("profileId"),
+		CreatedAt:   time.Now(),
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Table("profile_suggestion_dismissals").Where("profile_id = ? AND dismissed_id = ?", newDismissalObj.ProfileId, newDismissalObj.DismissedId).FirstOrCreate(&newDismissalObj).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Suggestion has been dismissed."}))
+}
@@ -0,0 +1,378 @@
+package profilecontrollers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rivo/uniseg"
+	"gorm.io/gorm"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/payments"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	maxTiers = 10
+)
+
+// Returns the tier if it is owned by profileId. The Id of the returned tier is empty if it isn't found.
+func findOwnedTier(profileId, tierId string) (models.SubscriptionTier, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var tier models.SubscriptionTier
+	err := configs.Database.WithContext(dbCtx).Model(&models.SubscriptionTier{}).Find(&tier, "id = ? AND profile_id = ?", tierId, profileId).Error
+	return tier, err
+}
+
+// Returns true if another tier of profileId already has the rank
+func isTierRankTaken(profileId, tierId string, rank int) (bool, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var numTiers int64
+	if err := configs.Database.WithContext(dbCtx).Model(&models.SubscriptionTier{}).Where("profile_id = ? AND rank = ? AND id <> ?", profileId, rank, tierId).Count(&numTiers).Error; err != nil {
+		return false, err
+	}
+	return numTiers > 0, nil
+}
+
+// Returns why the pricing of a tier is invalid or an empty string if it is valid. Paid tiers need a currency and a billing period, free tiers have neither.
+func validateTierPricing(price int64, currency, billingPeriod string) string {
+	if price < 0 {
+		return "Price cannot be negative."
+	}
+	if price == 0 {
+		if currency != "" || billingPeriod != "" {
+			return "Free tiers cannot have a currency or billing period."
+		}
+		return ""
+	}
+	if len(currency) != 3 {
+		return "Currency must be a 3 letter currency code."
+	}
+	if billingPeriod != models.BillingPeriodMonth && billingPeriod != models.BillingPeriodYear {
+		return fmt.Sprintf("Billing period must be either \"%s\" or \"%s\".", models.BillingPeriodMonth, models.BillingPeriodYear)
+	}
+	return ""
+}
+
+func CreateTier(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		Name          string `json:"name"`
+		Rank          *int   `json:"rank"`
+		Price         int64  `json:"price"` // in the smallest unit of currency, 0 means the tier is free
+		Currency      string `json:"currency"`
+		BillingPeriod string `json:"billing_period"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	reqBody.Name = strings.TrimSpace(reqBody.Name) // remove leading and trailing whitespace
+
+	if reqBody.Name == "" || reqBody.Rank == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	if uniseg.GraphemeClusterCount(reqBody.Name) > 50 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Name is too long."}, nil))
+	}
+
+	if *reqBody.Rank < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Rank must be at least 1."}, nil))
+	}
+
+	reqBody.Currency = strings.ToLower(strings.TrimSpace(reqBody.Currency))
+	if errMessage := validateTierPricing(reqBody.Price, reqBody.Currency, reqBody.BillingPeriod); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	// Get number of tiers
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	numTiers := configs.Database.WithContext(dbCtx).Model(&reqProfile).Association("SubscriptionTiers").Count()
+	if numTiers >= maxTiers {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": fmt.Sprintf("Max number of tiers is %d.", maxTiers)}, nil))
+	}
+
+	isTaken, err := isTierRankTaken(reqProfile.Id, "", *reqBody.Rank)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isTaken {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "A tier with this rank already exists."}, nil))
+	}
+
+	newTier := models.SubscriptionTier{
+		ProfileId:     reqProfile.Id,
+		Name:          reqBody.Name,
+		Rank:          *reqBody.Rank,
+		Price:         reqBody.Price,
+		Currency:      reqBody.Currency,
+		BillingPeriod: reqBody.BillingPeriod,
+	}
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.SubscriptionTier{}).Create(&newTier).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": newTier}))
+}
+
+// Changing the rank of a tier moves its subscribers and posts with it. A new price is charged from the next renewal of each subscription.
+func EditTier(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		Name          string `json:"name"`
+		Rank          *int   `json:"rank"`
+		Price         int64  `json:"price"` // in the smallest unit of currency, 0 means the tier is free
+		Currency      string `json:"currency"`
+		BillingPeriod string `json:"billing_period"`
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	reqBody.Name = strings.TrimSpace(reqBody.Name) // remove leading and trailing whitespace
+
+	if reqBody.Name == "" || reqBody.Rank == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Please include all fields."}, nil))
+	}
+
+	if uniseg.GraphemeClusterCount(reqBody.Name) > 50 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Name is too long."}, nil))
+	}
+
+	if *reqBody.Rank < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Rank must be at least 1."}, nil))
+	}
+
+	reqBody.Currency = strings.ToLower(strings.TrimSpace(reqBody.Currency))
+	if errMessage := validateTierPricing(reqBody.Price, reqBody.Currency, reqBody.BillingPeriod); errMessage != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": errMessage}, nil))
+	}
+
+	isTaken, err := isTierRankTaken(reqProfile.Id, c.Params("tierId"), *reqBody.Rank)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isTaken {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "A tier with this rank already exists."}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	result := configs.Database.WithContext(dbCtx).Model(&models.SubscriptionTier{}).Where("id = ? AND profile_id = ?", c.Params("tierId"), reqProfile.Id).Updates(map[string]interface{}{"name": reqBody.Name, "rank": *reqBody.Rank, "price": reqBody.Price, "currency": reqBody.Currency, "billing_period": reqBody.BillingPeriod})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier not found."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Tier has been edited."}))
+}
+
+// Subscribers of a deleted tier keep their subscription without a tier, paid subscriptions then end with their current period. Tiers required by posts can't be deleted since the minimum tier of a post can't be changed.
+func DeleteTier(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	tier, err := findOwnedTier(reqProfile.Id, c.Params("tierId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if tier.Id == "" { // Id field is empty => tier does not exist or isn't owned by the request user
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier not found."}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var numPosts int64
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Post{}).Where("min_tier_id = ?", tier.Id).Count(&numPosts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if numPosts > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "This tier is required by posts and cannot be deleted."}, nil))
+	}
+
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("profile_subscribers").Where("profile_id = ? AND tier_id = ?", reqProfile.Id, tier.Id).Update("tier_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("profile_subscribers").Where("profile_id = ? AND requested_tier_id = ?", reqProfile.Id, tier.Id).Update("requested_tier_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tier).Error
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Tier has been deleted."}))
+}
+
+// Returns the tiers of profileId ordered from the lowest to the highest rank
+func GetTiers(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, c.Params("profileId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if isBlocked {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You cannot interact with this user."}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var tiers = []models.SubscriptionTier{}
+	if err := configs.Database.WithContext(dbCtx).Model(&models.SubscriptionTier{}).Where("profile_id = ?", c.Params("profileId")).Order("rank ASC").Find(&tiers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": tiers}))
+}
+
+// Returns the rank of the tier, 0 for a nil tier since a subscription without a tier ranks below every tier
+func tierRank(tierId *string) (int, error) {
+	if tierId == nil {
+		return 0, nil
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var ranks []int
+	if err := configs.Database.WithContext(dbCtx).Model(&models.SubscriptionTier{}).Where("id = ?", *tierId).Pluck("rank", &ranks).Error; err != nil {
+		return 0, err
+	}
+	if len(ranks) == 0 {
+		return 0, nil
+	}
+	return ranks[0], nil
+}
+
+/*
+Moves the request user's subscription to profileId to another of its tiers. A nil tier_id leaves the subscription without a tier.
+
+Moving to a paid tier charges its full price and starts a new billing period. Moving to a free tier ends the paid period without a refund.
+A free tier ranked above the current one isn't given away, the move is sent as a request the profile has to accept.
+*/
+func ChangeSubscriptionTier(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	reqBody := struct {
+		TierId *string `json:"tier_id"` // this is allowed to be nil
+	}{}
+
+	if err := c.BodyParser(&reqBody); err != nil && len(c.Body()) > 0 { // the body is optional
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Bad request..."}, err))
+	}
+
+	var tier models.SubscriptionTier
+	if reqBody.TierId != nil {
+		var err error
+		tier, err = findOwnedTier(c.Params("profileId"), *reqBody.TierId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if tier.Id == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier not found."}, nil))
+		}
+	}
+
+	// Check if request user is an accepted subscriber of profileId whose subscription hasn't expired
+	isSubscribed, err := hasSubscription(c.Params("profileId"), reqProfile.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !isSubscribed {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You are not subscribed to this user."}, nil))
+	}
+
+	if tier.IsPaid() {
+		if err := utils.ChargeSubscription(reqProfile.Id, tier, time.Now(), uuid.NewString()); err != nil {
+			if errors.Is(err, payments.ErrDeclined) {
+				return c.Status(fiber.StatusPaymentRequired).JSON(responses.NewErrorResponse(fiber.StatusPaymentRequired, &fiber.Map{"data": "Your payment was declined."}, err))
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Subscription tier has been changed."}))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var subscription models.ProfileSubscriber
+	if err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Find(&subscription, "profile_id = ? AND subscriber_id = ? AND is_accepted = ?", c.Params("profileId"), reqProfile.Id, true).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	currentRank, err := tierRank(subscription.TierId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	// the tier is checked in the same statement so a move made by another request in the meantime isn't overwritten with a stale rank
+	updates := map[string]interface{}{"tier_id": reqBody.TierId, "requested_tier_id": nil, "current_period_end": nil, "cancel_at_period_end": false}
+	message := "Subscription tier has been changed."
+	if reqBody.TierId != nil && tier.Rank > currentRank {
+		updates = map[string]interface{}{"requested_tier_id": reqBody.TierId}
+		message = "Your request to change tier has been sent."
+	}
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	result := configs.Database.WithContext(dbCtx2).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_accepted = ? AND tier_id IS NOT DISTINCT FROM ? AND "+utils.UnexpiredSubscriptionCondition(), c.Params("profileId"), reqProfile.Id, true, subscription.TierId).Updates(updates)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(responses.NewErrorResponse(fiber.StatusConflict, &fiber.Map{"data": "Your subscription changed in the meantime. Please try again."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": message}))
+}
+
+// Moves a subscriber of the request user to the higher free tier they asked for
+func AcceptTierChangeRequest(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	result := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND is_accepted = ? AND requested_tier_id IS NOT NULL AND "+utils.UnexpiredSubscriptionCondition(), reqProfile.Id, c.Params("senderId"), true).Updates(map[string]interface{}{
+		"tier_id":              gorm.Expr("requested_tier_id"),
+		"requested_tier_id":    nil,
+		"current_period_end":   nil,
+		"cancel_at_period_end": false,
+	})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier change request not found."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Tier change request has been accepted."}))
+}
+
+// Turns down a subscriber's request to move to a higher free tier, the subscription keeps its tier
+func DeclineTierChangeRequest(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	result := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND requested_tier_id IS NOT NULL", reqProfile.Id, c.Params("senderId")).Update("requested_tier_id", nil)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Tier change request not found."}, nil))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": "Tier change request has been declined."}))
+}
@@ -0,0 +1,27 @@
+package searchcontrollers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+// Returns the terms searched by the most profiles over the window in the "window" query parameter, which defaults to 24 hours
+func GetTrendingSearches(c *fiber.Ctx) error {
+	window := c.Query("window", "24h")
+	if _, ok := utils.TrendingWindows[window]; !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid window."}, nil))
+	}
+
+	trending, err := utils.TrendingSearches(window)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	searches := make([]responses.TrendingSearch, len(trending))
+	for i, member := range trending {
+		searches[i] = responses.TrendingSearch{Term: member.Member, NumSearchers: int64(member.Score)}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(responses.NewSuccessResponse(fiber.StatusOK, &fiber.Map{"data": searches}))
+}
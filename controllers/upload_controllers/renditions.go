@@ -0,0 +1,93 @@
+package uploadcontrollers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/storage"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/utils"
+)
+
+// Limits how many images are decoded at once, a big image can take a few hundred MB of memory
+var renditionSlots = make(chan struct{}, 2)
+
+/*
+Makes the renditions of a completed image upload in the background and stores them next to its file, then adds their urls to the upload
+and to the media of the post it was attached to in the meantime. Until then clients use the original file.
+
+Failing to make them is logged and otherwise ignored, an image without renditions is still usable.
+*/
+func renderUploadInBackground(upload models.Upload) {
+	go func() {
+		renditions := renderUpload(upload, upload.Key, upload.MediaMetadata)
+		if renditions == (models.MediaRenditions{}) {
+			return
+		}
+
+		// the upload row is updated first, attaching it to a post waits for that and then copies the renditions itself
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		fields := map[string]interface{}{
+			"thumbnail_url": renditions.ThumbnailUrl,
+			"medium_url":    renditions.MediumUrl,
+			"full_url":      renditions.FullUrl,
+			"blurhash":      renditions.Blurhash,
+		}
+		err := configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.Upload{}).Where("id = ?", upload.Id).Updates(fields).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.PostMedia{}).Where("media_url = ?", storage.URL(upload.Key)).Updates(fields).Error
+		})
+		if err != nil {
+			log.Printf("could not save the renditions of upload %s: %v", upload.Id, err)
+		}
+	}()
+}
+
+// Makes the renditions of a completed image upload and stores them next to its file. The renditions are empty for uploads that aren't
+// a JPEG or PNG image, or if making them failed.
+func renderUpload(upload models.Upload, key string, metadata models.MediaMetadata) models.MediaRenditions {
+	if metadata.Kind() != models.MediaKindImage || int64(metadata.Width)*int64(metadata.Height) > utils.MaxRenditionPixels {
+		return models.MediaRenditions{}
+	}
+	renditionSlots <- struct{}{}
+	defer func() { <-renditionSlots }()
+
+	ctx, cancel := storage.NewStorageContext()
+	defer cancel()
+	body, err := storage.Get(ctx, key)
+	if err != nil {
+		log.Printf("could not read upload %s to make its renditions: %v", upload.Id, err)
+		return models.MediaRenditions{}
+	}
+	rendered, blurhash, err := utils.RenderImage(body, metadata.Format)
+	body.Close()
+	if err != nil {
+		if !errors.Is(err, utils.ErrUnsupportedMedia) {
+			log.Printf("could not make the renditions of upload %s: %v", upload.Id, err)
+		}
+		return models.MediaRenditions{}
+	}
+
+	// renditions stored before a failure are deleted along with the upload
+	urls := make(map[string]string, len(rendered))
+	for _, rendition := range rendered {
+		renditionKey := storage.UploadRenditionKey(upload.ProfileId, upload.Id, rendition.Name+rendition.Extension)
+		if err := storage.Put(ctx, renditionKey, bytes.NewReader(rendition.Data), int64(len(rendition.Data)), rendition.ContentType); err != nil {
+			log.Printf("could not store the renditions of upload %s: %v", upload.Id, err)
+			return models.MediaRenditions{}
+		}
+		urls[rendition.Name] = storage.URL(renditionKey)
+	}
+	return models.MediaRenditions{
+		ThumbnailUrl: urls[models.RenditionThumbnail],
+		MediumUrl:    urls[models.RenditionMedium],
+		FullUrl:      urls[models.RenditionFull],
+		Blurhash:     blurhash,
+	}
+}
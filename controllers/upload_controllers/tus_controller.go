@@ -0,0 +1,416 @@
+package uploadcontrollers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/cache"
+	"nerajima.com/NeraJima/configs/storage"
+	"nerajima.com/NeraJima/middleware"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+/*
+   The upload routes implement the core tus protocol with the creation, expiration and termination extensions: https://tus.io/protocols/resumable-upload
+
+   Every PATCH request is stored as its own chunk, so chunks are limited by the body limit of the server (4MB by default) and clients
+   should be configured with a smaller chunk size. Once every byte is received the chunks are joined into one file.
+
+   Status codes follow the protocol instead of the rest of the API, e.g. a missing upload is a 404, so tus clients can resume or restart on their own.
+*/
+
+const (
+	maxUploadSize     = 1 << 30          // 1GB
+	maxPendingUploads = 10               // uploads a profile can have that aren't attached to a post yet
+	uploadExpiry      = time.Hour * 24   // how long an upload lasts after its last chunk, or after it completes if it is never attached to a post
+	uploadLockExpiry  = time.Minute * 15 // longer than writing a chunk or joining a whole upload can take
+	uploadJoinTimeout = time.Minute * 10
+)
+
+var (
+	errMissingChunks     = errors.New("the chunks of the upload don't cover the whole file")
+	errUnsupportedUpload = errors.New("the upload is not a supported media file")
+)
+
+// Returns the request user's upload. The Id of the upload is empty if it doesn't exist.
+func getUpload(profileId, uploadId string) (models.Upload, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var upload models.Upload
+	err := configs.Database.WithContext(dbCtx).Model(&models.Upload{}).Find(&upload, "id = ? AND profile_id = ?", uploadId, profileId).Error
+	return upload, err
+}
+
+// Only one request can write to an upload at a time, otherwise two could store chunks at the same offset
+func lockUpload(uploadId string) (bool, error) {
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	defer cacheCancel()
+	return cache.SetIfNotExists(cacheCtx, cache.UploadLockKey(uploadId), time.Now(), uploadLockExpiry)
+}
+
+func unlockUpload(uploadId string) {
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	defer cacheCancel()
+	cache.Delete(cacheCtx, cache.UploadLockKey(uploadId))
+}
+
+// Uploads attached to a post never expire
+func isExpired(upload models.Upload) bool {
+	return upload.PostId == nil && time.Now().After(upload.ExpiresAt)
+}
+
+func setUploadHeaders(c *fiber.Ctx, upload models.Upload) {
+	c.Set("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+	if upload.PostId == nil {
+		c.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Parses the Upload-Metadata header, a comma separated list of keys each followed by a space and its base64 encoded value
+func parseMetadata(header string) (map[string]string, bool) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, true
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, false
+		}
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, false
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata, true
+}
+
+// Only media types are kept so a stored file can never be served as a page or script
+func mediaContentType(fileType string) string {
+	mediaType, _, err := mime.ParseMediaType(fileType)
+	if err != nil || !(strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "video/") || strings.HasPrefix(mediaType, "audio/")) {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// Returns a reader of the chunks in order, read from storage as it is read. Closing the reader stops the reads.
+func readChunks(ctx context.Context, chunks []storage.Object) *io.PipeReader {
+	reader, writer := io.Pipe()
+	go func() {
+		for _, chunk := range chunks {
+			body, err := storage.Get(ctx, chunk.Key)
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+			_, err = io.Copy(writer, body)
+			body.Close()
+			if err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+		writer.Close()
+	}()
+	return reader
+}
+
+/*
+Joins the chunks of a fully received upload into one file and deletes them. Returns the key of the file and its metadata.
+
+The chunks are inspected before they are joined, the file is named after the format that was found and stored with its media type,
+so what the client claimed the file is never decides how it is served. Returns errUnsupportedUpload if the file isn't supported media.
+*/
+func joinUploadChunks(upload models.Upload) (string, models.MediaMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), uploadJoinTimeout)
+	defer cancel()
+
+	chunks, err := storage.List(ctx, storage.UploadChunksPrefix(upload.ProfileId, upload.Id))
+	if err != nil {
+		return "", models.MediaMetadata{}, err
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Key < chunks[j].Key })
+	var covered int64
+	for _, chunk := range chunks {
+		offset, ok := storage.ParseChunkKey(chunk.Key)
+		if !ok || offset != covered {
+			return "", models.MediaMetadata{}, errMissingChunks
+		}
+		covered += chunk.Size
+	}
+	if covered != upload.Length {
+		return "", models.MediaMetadata{}, errMissingChunks
+	}
+
+	inspectReader := readChunks(ctx, chunks)
+	metadata, err := utils.InspectMedia(inspectReader, upload.Length)
+	inspectReader.Close() // the inspector doesn't always read to the end
+	if err != nil {
+		if !errors.Is(err, utils.ErrUnsupportedMedia) {
+			log.Printf("could not inspect upload %s: %v", upload.Id, err)
+		}
+		return "", models.MediaMetadata{}, errUnsupportedUpload
+	}
+
+	reader := readChunks(ctx, chunks)
+	defer reader.Close() // stops the reads if Put gave up before reading everything
+	key := storage.UploadKey(upload.ProfileId, upload.Id, "media"+metadata.Extension())
+	if err := storage.Put(ctx, key, reader, upload.Length, metadata.ContentType()); err != nil {
+		return "", models.MediaMetadata{}, err
+	}
+
+	if err := storage.DeletePrefix(ctx, storage.UploadChunksPrefix(upload.ProfileId, upload.Id)); err != nil {
+		log.Printf("could not delete the chunks of upload %s: %v", upload.Id, err) // the file is complete, the chunks are removed with the upload later
+	}
+	return key, metadata, nil
+}
+
+// Deletes the files of a deleted upload in the background. Files left behind if this fails are removed by the media cleanup job since the upload no longer exists.
+func deleteUploadFiles(upload models.Upload) {
+	go func() {
+		storageCtx, storageCancel := storage.NewStorageContext()
+		defer storageCancel()
+		if err := storage.DeletePrefix(storageCtx, storage.UploadPrefix(upload.ProfileId, upload.Id)); err != nil {
+			log.Printf("could not delete the files of upload %s: %v", upload.Id, err)
+		}
+	}()
+}
+
+// Tells tus clients which version and extensions of the protocol are supported
+func GetTusOptions(c *fiber.Ctx) error {
+	c.Set("Tus-Version", middleware.TusVersion)
+	c.Set("Tus-Extension", "creation,expiration,termination")
+	c.Set("Tus-Max-Size", strconv.FormatInt(maxUploadSize, 10))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func CreateUpload(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid Upload-Length header."}, nil))
+	}
+	if length > maxUploadSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(responses.NewErrorResponse(fiber.StatusRequestEntityTooLarge, &fiber.Map{"data": "Upload is too large."}, nil))
+	}
+	metadata, ok := parseMetadata(c.Get("Upload-Metadata"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid Upload-Metadata header."}, nil))
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var numPendingUploads int64
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Upload{}).Where("profile_id = ? AND post_id IS NULL AND expires_at > ?", reqProfile.Id, time.Now()).Count(&numPendingUploads).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if numPendingUploads >= maxPendingUploads {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "You have too many unfinished uploads."}, nil))
+	}
+
+	newUpload := models.Upload{
+		ProfileId:   reqProfile.Id,
+		Length:      length,
+		FileName:    metadata["filename"],
+		ContentType: mediaContentType(metadata["filetype"]),
+		ExpiresAt:   time.Now().Add(uploadExpiry),
+	}
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.Upload{}).Create(&newUpload).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+
+	c.Set(fiber.HeaderLocation, c.BaseURL()+strings.TrimSuffix(c.Path(), "/")+"/"+newUpload.Id)
+	setUploadHeaders(c, newUpload)
+	return c.Status(fiber.StatusCreated).JSON(responses.NewSuccessResponse(fiber.StatusCreated, &fiber.Map{"data": newUpload}))
+}
+
+// Reports how many bytes of the upload were received, so a client can resume where it left off
+func GetUploadOffset(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	upload, err := getUpload(reqProfile.Id, c.Params("uploadId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if upload.Id == "" {
+		return c.Status(fiber.StatusNotFound).JSON(responses.NewErrorResponse(fiber.StatusNotFound, &fiber.Map{"data": "Upload not found."}, nil))
+	}
+	if isExpired(upload) {
+		return c.Status(fiber.StatusGone).JSON(responses.NewErrorResponse(fiber.StatusGone, &fiber.Map{"data": "Upload has expired."}, nil))
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	setUploadHeaders(c, upload)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// Stores the body as the chunk starting at Upload-Offset. The upload is completed by the request that sends its last byte,
+// which also starts making the renditions of image uploads in the background.
+func PatchUpload(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	if c.Get(fiber.HeaderContentType) != "application/offset+octet-stream" {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(responses.NewErrorResponse(fiber.StatusUnsupportedMediaType, &fiber.Map{"data": "Content-Type must be application/offset+octet-stream."}, nil))
+	}
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(responses.NewErrorResponse(fiber.StatusBadRequest, &fiber.Map{"data": "Invalid Upload-Offset header."}, nil))
+	}
+
+	// the owner is checked before locking so nobody can hold the lock of someone else's upload
+	upload, err := getUpload(reqProfile.Id, c.Params("uploadId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if upload.Id == "" {
+		return c.Status(fiber.StatusNotFound).JSON(responses.NewErrorResponse(fiber.StatusNotFound, &fiber.Map{"data": "Upload not found."}, nil))
+	}
+
+	acquired, err := lockUpload(upload.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !acquired {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "The upload is being written to by another request."}, nil))
+	}
+	defer unlockUpload(upload.Id)
+
+	// read again since another request could have written to it before the lock was taken
+	upload, err = getUpload(reqProfile.Id, upload.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if upload.Id == "" {
+		return c.Status(fiber.StatusNotFound).JSON(responses.NewErrorResponse(fiber.StatusNotFound, &fiber.Map{"data": "Upload not found."}, nil))
+	}
+	if isExpired(upload) {
+		return c.Status(fiber.StatusGone).JSON(responses.NewErrorResponse(fiber.StatusGone, &fiber.Map{"data": "Upload has expired."}, nil))
+	}
+	if offset != upload.Received {
+		return c.Status(fiber.StatusConflict).JSON(responses.NewErrorResponse(fiber.StatusConflict, &fiber.Map{"data": "Upload-Offset does not match the offset of the upload."}, nil))
+	}
+	chunk := c.Body()
+	if offset+int64(len(chunk)) > upload.Length {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(responses.NewErrorResponse(fiber.StatusRequestEntityTooLarge, &fiber.Map{"data": "The chunk goes past the end of the upload."}, nil))
+	}
+
+	if len(chunk) > 0 {
+		storageCtx, storageCancel := storage.NewStorageContext()
+		defer storageCancel()
+		if err := storage.Put(storageCtx, storage.UploadChunkKey(upload.ProfileId, upload.Id, offset), bytes.NewReader(chunk), int64(len(chunk)), "application/octet-stream"); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+
+		upload.Received += int64(len(chunk))
+		upload.ExpiresAt = time.Now().Add(uploadExpiry)
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		if err := configs.Database.WithContext(dbCtx).Model(&models.Upload{}).Where("id = ?", upload.Id).Updates(map[string]interface{}{"received": upload.Received, "expires_at": upload.ExpiresAt}).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+	}
+
+	// Joining is retried by any later request if it fails, e.g. a PATCH without a body at the final offset
+	if upload.Received == upload.Length && upload.CompletedAt == nil {
+		key, metadata, err := joinUploadChunks(upload)
+		if errors.Is(err, errUnsupportedUpload) {
+			// the upload can never be used, so it is deleted instead of waiting for it to expire
+			dbCtx, dbCancel := configs.NewQueryContext()
+			defer dbCancel()
+			if err := configs.Database.WithContext(dbCtx).Delete(&models.Upload{}, "id = ? AND post_id IS NULL", upload.Id).Error; err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+			}
+			deleteUploadFiles(upload)
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(responses.NewErrorResponse(fiber.StatusUnsupportedMediaType, &fiber.Map{"data": "The upload is not a supported image, video or audio file."}, nil))
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+
+		now := time.Now()
+		upload.Key, upload.ContentType, upload.MediaMetadata, upload.CompletedAt, upload.ExpiresAt = key, metadata.ContentType(), metadata, &now, now.Add(uploadExpiry)
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		if err := configs.Database.WithContext(dbCtx).Model(&models.Upload{}).Where("id = ?", upload.Id).Updates(map[string]interface{}{
+			"key":          upload.Key,
+			"content_type": upload.ContentType,
+			"format":       metadata.Format,
+			"size":         metadata.Size,
+			"width":        metadata.Width,
+			"height":       metadata.Height,
+			"duration":     metadata.Duration,
+			"video_codec":  metadata.VideoCodec,
+			"audio_codec":  metadata.AudioCodec,
+			"completed_at": upload.CompletedAt,
+			"expires_at":   upload.ExpiresAt,
+		}).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+
+		renderUploadInBackground(upload)
+	}
+
+	setUploadHeaders(c, upload)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Deletes an upload the client gave up on. Uploads attached to a post are removed with the post's media instead.
+func TerminateUpload(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+
+	// the owner is checked before locking so nobody can hold the lock of someone else's upload
+	upload, err := getUpload(reqProfile.Id, c.Params("uploadId"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if upload.Id == "" || upload.PostId != nil {
+		return c.Status(fiber.StatusNotFound).JSON(responses.NewErrorResponse(fiber.StatusNotFound, &fiber.Map{"data": "Upload not found."}, nil))
+	}
+
+	// a request still writing to the upload could store a chunk after the files are deleted
+	acquired, err := lockUpload(upload.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+	}
+	if !acquired {
+		return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "The upload is being written to by another request."}, nil))
+	}
+	defer unlockUpload(upload.Id)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	result := configs.Database.WithContext(dbCtx).Delete(&models.Upload{}, "id = ? AND post_id IS NULL", upload.Id)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, result.Error))
+	}
+	if result.RowsAffected == 0 { // attached to a post in the meantime
+		return c.Status(fiber.StatusNotFound).JSON(responses.NewErrorResponse(fiber.StatusNotFound, &fiber.Map{"data": "Upload not found."}, nil))
+	}
+
+	deleteUploadFiles(upload)
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+)
+
+const (
+	analyticsRollupInterval = time.Hour
+	analyticsBackfillDays   = 90 // days rolled up the first time the job runs
+)
+
+// Recomputes the daily analytics rollups of yesterday and today. Yesterday is redone so activity from the end of the day isn't missed.
+func rollupAnalytics() {
+	today := time.Now().UTC().Truncate(time.Hour * 24)
+	from := today.AddDate(0, 0, -1)
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	var numRollups int64
+	err := configs.Database.WithContext(dbCtx).Model(&models.ProfileDailyStat{}).Count(&numRollups).Error
+	dbCancel()
+	if err != nil {
+		log.Printf("analytics rollup: could not count rollups: %v", err)
+		return
+	}
+	if numRollups == 0 {
+		from = today.AddDate(0, 0, -analyticsBackfillDays)
+	}
+
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if err := rollupDay(day); err != nil {
+			log.Printf("analytics rollup: could not roll up %s: %v", day.Format("2006-01-02"), err)
+			return
+		}
+	}
+}
+
+// Replaces the rollups of the day. Post rollups are written first since profile rollups are summed from them.
+func rollupDay(day time.Time) error {
+	params := map[string]interface{}{"day": day, "start": day, "end": day.AddDate(0, 0, 1)}
+
+	postQuery := "INSERT INTO post_daily_stats (post_id, day, profile_id, likes, dislikes, bookmarks, comments, updated_at) "
+	postQuery += "SELECT activity.post_id, @day, posts.profile_id, SUM(activity.likes), SUM(activity.dislikes), SUM(activity.bookmarks), SUM(activity.comments), NOW() FROM ("
+	postQuery += "SELECT post_id::text AS post_id, 1 AS likes, 0 AS dislikes, 0 AS bookmarks, 0 AS comments FROM post_likes WHERE created_at >= @start AND created_at < @end "
+	postQuery += "UNION ALL SELECT post_id::text, 0, 1, 0, 0 FROM post_dislikes WHERE created_at >= @start AND created_at < @end "
+	postQuery += "UNION ALL SELECT post_id::text, 0, 0, 1, 0 FROM post_bookmarks WHERE created_at >= @start AND created_at < @end "
+	postQuery += "UNION ALL SELECT post_id::text, 0, 0, 0, 1 FROM comments WHERE created_at >= @start AND created_at < @end"
+	postQuery += ") AS activity JOIN posts ON posts.id = activity.post_id GROUP BY activity.post_id, posts.profile_id;"
+
+	// Follows only count once accepted, but are dated by when they were requested since the time of acceptance isn't stored. The same goes for subscriptions.
+	profileQuery := "INSERT INTO profile_daily_stats (profile_id, day, new_followers, unfollows, new_subscribers, likes, dislikes, bookmarks, comments, updated_at) "
+	profileQuery += "SELECT activity.profile_id, @day, SUM(activity.new_followers), SUM(activity.unfollows), SUM(activity.new_subscribers), SUM(activity.likes), SUM(activity.dislikes), SUM(activity.bookmarks), SUM(activity.comments), NOW() FROM ("
+	profileQuery += "SELECT profile_id::text AS profile_id, 1 AS new_followers, 0 AS unfollows, 0 AS new_subscribers, 0 AS likes, 0 AS dislikes, 0 AS bookmarks, 0 AS comments FROM profile_followers WHERE is_pending = false AND created_at >= @start AND created_at < @end "
+	profileQuery += "UNION ALL SELECT profile_id, 0, 1, 0, 0, 0, 0, 0 FROM profile_unfollows WHERE created_at >= @start AND created_at < @end "
+	profileQuery += "UNION ALL SELECT profile_id::text, 0, 0, 1, 0, 0, 0, 0 FROM profile_subscribers WHERE is_accepted = true AND created_at >= @start AND created_at < @end "
+	profileQuery += "UNION ALL SELECT profile_id, 0, 0, 0, likes, dislikes, bookmarks, comments FROM post_daily_stats WHERE day = @day"
+	profileQuery += ") AS activity JOIN profiles ON profiles.id = activity.profile_id GROUP BY activity.profile_id;"
+
+	// The rows of the day are deleted first so activity that was undone (a removed like, a deleted comment...) doesn't leave stale rows
+	dbCtx, dbCancel := configs.NewJobQueryContext()
+	defer dbCancel()
+	return configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day = ?", day).Delete(&models.PostDailyStat{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("day = ?", day).Delete(&models.ProfileDailyStat{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(postQuery, params).Error; err != nil {
+			return err
+		}
+		return tx.Exec(profileQuery, params).Error
+	})
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+)
+
+const (
+	inviteCleanupInterval = time.Hour * 6
+)
+
+// Deletes subscriber invites and requests that expired before being accepted. Expired rows are already hidden from the invite and request lists.
+func cleanupExpiredInvites() {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var subscriberObj models.ProfileSubscriber
+	result := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Delete(&subscriberObj, "is_accepted = ? AND pending_expires_at <= ?", false, time.Now())
+	if result.Error != nil {
+		log.Printf("invite cleanup: could not delete expired invites and requests: %v", result.Error)
+		return
+	}
+
+	log.Printf("invite cleanup: deleted %d expired invites and requests", result.RowsAffected)
+}
@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"nerajima.com/NeraJima/configs/cache"
+)
+
+/*
+   Background jobs run on a fixed interval in their own goroutine.
+
+   Since more than one instance of the server can be running, every run first takes a lock in Redis.
+   The lock expires a little before the next tick so exactly one instance runs the job per interval.
+*/
+
+func Start() {
+	go runEvery("media-cleanup", mediaCleanupInterval, cleanupOrphanedMedia)
+	go runEvery("mute-cleanup", muteCleanupInterval, cleanupExpiredMutes)
+	go runEvery("suggestions", suggestionsInterval, refreshSuggestions)
+	go runEvery("renewals", renewalsInterval, renewSubscriptions)
+	go runEvery("invite-cleanup", inviteCleanupInterval, cleanupExpiredInvites)
+	go runEvery("subscription-expiry", subscriptionExpiryInterval, expireSubscriptions)
+	go runEvery("analytics-rollup", analyticsRollupInterval, rollupAnalytics)
+	go runEvery("scheduled-posts", scheduledPostsInterval, publishScheduledPosts)
+	go runEvery("upload-expiry", uploadExpiryInterval, expireUploads)
+
+	log.Println("Background jobs started...")
+}
+
+func runEvery(name string, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cacheCtx, cacheCancel := cache.NewCacheContext()
+		acquired, err := cache.SetIfNotExists(cacheCtx, cache.JobLockKey(name), time.Now(), interval*9/10)
+		cacheCancel()
+		if err != nil {
+			log.Printf("job %s: could not acquire lock: %v", name, err)
+			continue
+		}
+		if !acquired { // another instance is running this job
+			continue
+		}
+
+		job()
+	}
+}
@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/storage"
+)
+
+const (
+	mediaCleanupInterval = time.Hour * 6
+	mediaCleanupGrace    = time.Hour // objects younger than this are skipped in case the post/profile they belong to is still being created
+	mediaCleanupBatch    = 500
+)
+
+// Deletes stored objects whose profile, post or upload no longer exists. Deleting a profile or post cascades in the database but the files have to be removed here.
+func cleanupOrphanedMedia() {
+	ctx, cancel := storage.NewStorageContext()
+	defer cancel()
+	objects, err := storage.List(ctx, storage.ProfilesPrefix)
+	if err != nil {
+		log.Printf("media cleanup: could not list objects: %v", err)
+		return
+	}
+
+	profileIds, postIds, uploadIds := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, obj := range objects {
+		if profileId, postId, ok := storage.ParseKey(obj.Key); ok {
+			profileIds[profileId] = false
+			if postId != "" {
+				postIds[postId] = false
+			}
+		}
+		if uploadId, ok := storage.ParseUploadKey(obj.Key); ok {
+			uploadIds[uploadId] = false
+		}
+	}
+
+	// map values are set to true for the ids that still exist
+	if err := markExisting("profiles", profileIds); err != nil {
+		log.Printf("media cleanup: could not query profiles: %v", err)
+		return
+	}
+	if err := markExisting("posts", postIds); err != nil {
+		log.Printf("media cleanup: could not query posts: %v", err)
+		return
+	}
+	if err := markExisting("uploads", uploadIds); err != nil {
+		log.Printf("media cleanup: could not query uploads: %v", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-mediaCleanupGrace)
+	numDeleted := 0
+	for _, obj := range objects {
+		profileId, postId, ok := storage.ParseKey(obj.Key)
+		if !ok || obj.LastModified.After(cutoff) {
+			continue
+		}
+		uploadId, isUpload := storage.ParseUploadKey(obj.Key)
+		if profileIds[profileId] && (postId == "" || postIds[postId]) && (!isUpload || uploadIds[uploadId]) {
+			continue
+		}
+
+		deleteCtx, deleteCancel := storage.NewStorageContext()
+		err := storage.Delete(deleteCtx, obj.Key)
+		deleteCancel()
+		if err != nil {
+			log.Printf("media cleanup: could not delete %s: %v", obj.Key, err)
+			continue
+		}
+		numDeleted++
+	}
+
+	if numDeleted > 0 {
+		log.Printf("media cleanup: deleted %d orphaned objects", numDeleted)
+	}
+}
+
+// Sets ids[id] to true for every id that has a row in table
+func markExisting(table string, ids map[string]bool) error {
+	batch := make([]string, 0, mediaCleanupBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		dbCtx, dbCancel := configs.NewQueryContext()
+		defer dbCancel()
+		var existing []string
+		if err := configs.Database.WithContext(dbCtx).Table(table).Where("id IN ?", batch).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+		for _, id := range existing {
+			ids[id] = true
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for id := range ids {
+		batch = append(batch, id)
+		if len(batch) == mediaCleanupBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
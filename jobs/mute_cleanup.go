@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+)
+
+const (
+	muteCleanupInterval = time.Hour * 24
+)
+
+// Deletes mutes and muted keywords that have expired. Expired rows are already ignored by the feeds, this just keeps the tables small.
+func cleanupExpiredMutes() {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Table("profile_mutes").Delete(&models.ProfileMute{}, "expires_at <= ?", time.Now()).Error; err != nil {
+		log.Printf("mute cleanup: could not delete expired mutes: %v", err)
+	}
+
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	if err := configs.Database.WithContext(dbCtx2).Delete(&models.MutedKeyword{}, "expires_at <= ?", time.Now()).Error; err != nil {
+		log.Printf("mute cleanup: could not delete expired keywords: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/payments"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	renewalsInterval   = time.Hour
+	renewalGracePeriod = time.Hour * 24 // how long a renewal that fails for reasons other than a decline is retried before access is removed
+	renewalsBatchSize  = 100
+)
+
+// Renews paid subscriptions whose billing period has ended and removes the ones that lapsed
+func renewSubscriptions() {
+	now := time.Now()
+
+	// Rows that fail to renew stay due, so the batches are walked by primary key instead of being re-read from the start
+	lastProfileId, lastSubscriberId := "", ""
+	for {
+		dbCtx, dbCancel := configs.NewQueryContext()
+		var subscriptions []models.ProfileSubscriber
+		err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").
+			Where("is_accepted = ? AND current_period_end <= ?", true, now).
+			Where("(profile_id::text, subscriber_id::text) > (?, ?)", lastProfileId, lastSubscriberId).
+			Order("profile_id, subscriber_id").
+			Limit(renewalsBatchSize).
+			Find(&subscriptions).Error
+		dbCancel()
+		if err != nil {
+			log.Printf("renewals: could not get due subscriptions: %v", err)
+			return
+		}
+
+		for _, subscription := range subscriptions {
+			renewSubscription(subscription, now)
+		}
+
+		if len(subscriptions) < renewalsBatchSize {
+			return
+		}
+		lastProfileId = subscriptions[len(subscriptions)-1].ProfileId
+		lastSubscriberId = subscriptions[len(subscriptions)-1].SubscriberId
+	}
+}
+
+func renewSubscription(subscription models.ProfileSubscriber, now time.Time) {
+	if subscription.CancelAtPeriodEnd || subscription.TierId == nil {
+		endSubscription(subscription)
+		return
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var tier models.SubscriptionTier
+	if err := configs.Database.WithContext(dbCtx).Model(&models.SubscriptionTier{}).Find(&tier, "id = ?", *subscription.TierId).Error; err != nil {
+		log.Printf("renewals: could not get tier %s: %v", *subscription.TierId, err)
+		return
+	}
+	if tier.Id == "" {
+		endSubscription(subscription)
+		return
+	}
+
+	// the tier was made free, the subscription no longer has to be paid for
+	if !tier.IsPaid() {
+		dbCtx2, dbCancel2 := configs.NewQueryContext()
+		defer dbCancel2()
+		if err := configs.Database.WithContext(dbCtx2).Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ?", subscription.ProfileId, subscription.SubscriberId).Update("current_period_end", nil).Error; err != nil {
+			log.Printf("renewals: could not make subscription of %s to %s free: %v", subscription.SubscriberId, subscription.ProfileId, err)
+		}
+		return
+	}
+
+	// the key is the same for every attempt at renewing the same period so a retry can't charge twice
+	idempotencyKey := "renewal:" + subscription.ProfileId + ":" + subscription.SubscriberId + ":" + strconv.FormatInt(subscription.CurrentPeriodEnd.Unix(), 10)
+	err := utils.ChargeSubscription(subscription.SubscriberId, tier, *subscription.CurrentPeriodEnd, idempotencyKey)
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, payments.ErrDeclined) || errors.Is(err, utils.ErrAlreadyRefunded) || now.After(subscription.CurrentPeriodEnd.Add(renewalGracePeriod)) {
+		endSubscription(subscription)
+		return
+	}
+	log.Printf("renewals: could not renew subscription of %s to %s, will retry: %v", subscription.SubscriberId, subscription.ProfileId, err)
+}
+
+// Removes the subscription unless it was renewed since it was read
+func endSubscription(subscription models.ProfileSubscriber) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var subscriberObj models.ProfileSubscriber
+	if err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Delete(&subscriberObj, "profile_id = ? AND subscriber_id = ? AND current_period_end = ?", subscription.ProfileId, subscription.SubscriberId, subscription.CurrentPeriodEnd).Error; err != nil {
+		log.Printf("renewals: could not end subscription of %s to %s: %v", subscription.SubscriberId, subscription.ProfileId, err)
+	}
+}
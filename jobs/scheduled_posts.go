@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	scheduledPostsInterval  = time.Minute
+	scheduledPostsBatchSize = 100
+)
+
+/*
+Publishes the scheduled posts that are due. A published post takes its scheduled time as its creation time so it is ordered in feeds
+as if it was posted then. Feeds are read straight from the posts table, so publishing a post is all it takes for it to show up in the
+feeds of the owner's followers and subscribers.
+
+Once a post is live its owner is told and the profiles mentioned in it are notified, which couldn't happen while it was invisible.
+*/
+func publishScheduledPosts() {
+	for {
+		var posts []struct {
+			Id        string
+			ProfileId string
+			Title     string
+		}
+		// rows are claimed with SKIP LOCKED so an overlapping run can't publish, and notify about, the same post twice
+		query := "UPDATE posts SET created_at = publish_at, publish_at = NULL "
+		query += "WHERE id IN (SELECT id FROM posts WHERE publish_at <= NOW() ORDER BY publish_at LIMIT ? FOR UPDATE SKIP LOCKED) "
+		query += "RETURNING id, profile_id, title;"
+		dbCtx, dbCancel := configs.NewQueryContext()
+		err := configs.Database.WithContext(dbCtx).Raw(query, scheduledPostsBatchSize).Scan(&posts).Error
+		dbCancel()
+		if err != nil {
+			log.Printf("scheduled posts: could not publish due posts: %v", err)
+			return
+		}
+
+		for _, post := range posts {
+			notifyPublished(post.Id, post.ProfileId, post.Title)
+		}
+
+		if len(posts) < scheduledPostsBatchSize {
+			return
+		}
+	}
+}
+
+func notifyPublished(postId, profileId, title string) {
+	notification := models.Notification{
+		ProfileId: profileId,
+		Title:     "Scheduled post published",
+		Body:      "\"" + title + "\" is now live.",
+		Link:      "/posts/" + postId,
+	}
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	if err := configs.Database.WithContext(dbCtx).Create(&notification).Error; err != nil {
+		log.Printf("scheduled posts: could not notify %s of post %s: %v", profileId, postId, err)
+	}
+
+	dbCtx2, dbCancel2 := configs.NewQueryContext()
+	defer dbCancel2()
+	var owner models.Profile
+	if err := configs.Database.WithContext(dbCtx2).Model(&models.Profile{}).Find(&owner, "id = ?", profileId).Error; err != nil {
+		log.Printf("scheduled posts: could not get owner of post %s: %v", postId, err)
+		return
+	}
+
+	dbCtx3, dbCancel3 := configs.NewQueryContext()
+	defer dbCancel3()
+	var mentionedIds []string
+	if err := configs.Database.WithContext(dbCtx3).Model(&models.Mention{}).Where("post_id = ? AND comment_id IS NULL AND profile_id <> ?", postId, profileId).Distinct().Pluck("profile_id", &mentionedIds).Error; err != nil {
+		log.Printf("scheduled posts: could not get mentions of post %s: %v", postId, err)
+		return
+	}
+	utils.NotifyMentions(owner, mentionedIds, postId, false)
+}
@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	subscriptionExpiryInterval = time.Minute * 15
+	expiryNoticePeriod         = time.Hour * 24 // how long before a time limited subscription ends the subscriber is notified
+	expiryNoticeBatchSize      = 100
+)
+
+// Notifies subscribers whose time limited subscriptions end soon and removes the subscriptions that ended
+func expireSubscriptions() {
+	notifyExpiringSubscriptions()
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var subscriberObj models.ProfileSubscriber
+	result := configs.Database.WithContext(dbCtx).Table("profile_subscribers").Delete(&subscriberObj, "is_accepted = ? AND expires_at <= ?", true, time.Now())
+	if result.Error != nil {
+		log.Printf("subscription expiry: could not delete expired subscriptions: %v", result.Error)
+		return
+	}
+
+	log.Printf("subscription expiry: deleted %d expired subscriptions", result.RowsAffected)
+}
+
+func notifyExpiringSubscriptions() {
+	type expiringSubscription struct {
+		ProfileId    string
+		SubscriberId string
+		Username     string // username of the profile subscribed to
+		ExpiresAt    time.Time
+	}
+
+	// Notified rows drop out of the query, so every batch is read from the start
+	for {
+		now := time.Now()
+		dbCtx, dbCancel := configs.NewQueryContext()
+		var subscriptions []expiringSubscription
+		err := configs.Database.WithContext(dbCtx).Table("profile_subscribers").
+			Select("profile_subscribers.profile_id, profile_subscribers.subscriber_id, profiles.username, profile_subscribers.expires_at").
+			Joins("JOIN profiles ON profiles.id = profile_subscribers.profile_id").
+			Where("profile_subscribers.is_accepted = ? AND profile_subscribers.expiry_notified = ?", true, false).
+			Where("profile_subscribers.expires_at > ? AND profile_subscribers.expires_at <= ?", now, now.Add(expiryNoticePeriod)).
+			Limit(expiryNoticeBatchSize).
+			Scan(&subscriptions).Error
+		dbCancel()
+		if err != nil {
+			log.Printf("subscription expiry: could not get expiring subscriptions: %v", err)
+			return
+		}
+		if len(subscriptions) == 0 {
+			return
+		}
+
+		dbCtx2, dbCancel2 := configs.NewQueryContext()
+		err = configs.Database.WithContext(dbCtx2).Transaction(func(tx *gorm.DB) error {
+			for _, subscription := range subscriptions {
+				remaining := int64(subscription.ExpiresAt.Sub(now).Seconds())
+				notification := models.Notification{
+					ProfileId: subscription.SubscriberId,
+					Title:     "Subscription ending soon",
+					Body:      fmt.Sprintf("Your subscription to %s ends in %s.", subscription.Username, utils.SecondsToString(remaining)),
+					Link:      "/profile/" + subscription.Username,
+				}
+				if err := tx.Create(&notification).Error; err != nil {
+					return err
+				}
+
+				// the subscription could have been renewed since it was read, in which case it is notified again when the new expiry nears
+				if err := tx.Table("profile_subscribers").Where("profile_id = ? AND subscriber_id = ? AND expires_at = ?", subscription.ProfileId, subscription.SubscriberId, subscription.ExpiresAt).Update("expiry_notified", true).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		dbCancel2()
+		if err != nil {
+			log.Printf("subscription expiry: could not notify expiring subscriptions: %v", err)
+			return
+		}
+
+		if len(subscriptions) < expiryNoticeBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	suggestionsInterval = time.Hour * 6 // must be shorter than utils.SuggestionsExpiry so cached suggestions don't lapse between runs
+	suggestionsBatch    = 50            // kept small so each batch fits in the query timeout
+)
+
+// Precomputes the follow suggestions of every profile that follows someone. Profiles that follow no one have no friends of friends to suggest.
+func refreshSuggestions() {
+	numRefreshed := 0
+	lastId := ""
+	for {
+		dbCtx, dbCancel := configs.NewQueryContext()
+		var profileIds []string
+		query := configs.Database.WithContext(dbCtx).Table("profile_followers").Distinct("follower_id").Where("is_pending = ?", false)
+		if lastId != "" { // an empty string is not a valid uuid, so the first batch has no cursor
+			query = query.Where("follower_id > ?", lastId)
+		}
+		err := query.Order("follower_id").Limit(suggestionsBatch).Pluck("follower_id", &profileIds).Error
+		dbCancel()
+		if err != nil {
+			log.Printf("suggestions: could not query profiles: %v", err)
+			return
+		}
+		if len(profileIds) == 0 {
+			break
+		}
+
+		if _, err := utils.RefreshSuggestions(profileIds...); err != nil {
+			log.Printf("suggestions: could not refresh suggestions: %v", err)
+			return
+		}
+		numRefreshed += len(profileIds)
+		lastId = profileIds[len(profileIds)-1]
+	}
+
+	log.Printf("suggestions: refreshed suggestions of %d profiles", numRefreshed)
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/storage"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/utils"
+)
+
+const (
+	uploadExpiryInterval = time.Minute * 15
+	uploadExpiryBatch    = 100
+)
+
+// Deletes uploads that weren't attached to a post before they expired, whether they were abandoned half way or completed and never used, along with their files.
+// Uploads a draft refers to are kept until the draft is published or deleted.
+func expireUploads() {
+	dbCtx, dbCancel := configs.NewJobQueryContext()
+	defer dbCancel()
+	var uploads []models.Upload
+	if err := configs.Database.WithContext(dbCtx).Model(&models.Upload{}).Where("post_id IS NULL AND NOT " + utils.UnexpiredUploadCondition()).Limit(uploadExpiryBatch).Find(&uploads).Error; err != nil {
+		log.Printf("upload expiry: could not query expired uploads: %v", err)
+		return
+	}
+
+	for _, upload := range uploads {
+		// the row goes first so the upload can't be attached while its files are deleted. files left behind are removed by the media cleanup job
+		dbCtx, dbCancel := configs.NewQueryContext()
+		result := configs.Database.WithContext(dbCtx).Delete(&models.Upload{}, "id = ? AND post_id IS NULL AND NOT "+utils.UnexpiredUploadCondition(), upload.Id)
+		dbCancel()
+		if result.Error != nil {
+			log.Printf("upload expiry: could not delete upload %s: %v", upload.Id, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		storageCtx, storageCancel := storage.NewStorageContext()
+		err := storage.DeletePrefix(storageCtx, storage.UploadPrefix(upload.ProfileId, upload.Id))
+		storageCancel()
+		if err != nil {
+			log.Printf("upload expiry: could not delete the files of upload %s: %v", upload.Id, err)
+		}
+	}
+
+	if len(uploads) > 0 {
+		log.Printf("upload expiry: deleted %d expired uploads", len(uploads))
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/storage"
+	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/responses"
+	"nerajima.com/NeraJima/utils"
+)
+
+/*
+Lets the request profile fetch a stored object only if it can see what the object belongs to. Must run after UserAuthHandler.
+
+Owners can fetch all of their objects. Media of a post, uploaded files included once they are attached, follows the access of the post.
+Avatars can be fetched by every profile without a block with the owner. Everything else, like uploads that aren't attached yet, is only for the owner.
+*/
+func StorageAccessHandler(c *fiber.Ctx) error {
+	var reqProfile models.Profile = c.Locals("profile").(models.Profile)
+	key := c.Params("*")
+
+	profileId, postId, ok := storage.ParseKey(key)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(responses.NewErrorResponse(fiber.StatusNotFound, &fiber.Map{"data": "File not found."}, nil))
+	}
+	if profileId == reqProfile.Id {
+		return c.Next()
+	}
+
+	if uploadId, isUpload := storage.ParseUploadKey(key); isUpload {
+		if _, isChunk := storage.ParseChunkKey(key); !isChunk {
+			dbCtx, dbCancel := configs.NewQueryContext()
+			defer dbCancel()
+			var upload models.Upload
+			if err := configs.Database.WithContext(dbCtx).Model(&models.Upload{}).Select("id", "post_id").Find(&upload, "id = ? AND profile_id = ?", uploadId, profileId).Error; err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+			}
+			if upload.PostId != nil {
+				postId = *upload.PostId
+			}
+		}
+	}
+
+	if postId != "" {
+		canAccess, err := utils.CanAccessPost(reqProfile.Id, postId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if !canAccess {
+			return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this post."}, nil))
+		}
+		return c.Next()
+	}
+
+	if strings.HasPrefix(key, storage.ProfilePrefix(profileId)+"avatar/") {
+		isBlocked, err := utils.IsBlockedBetween(reqProfile.Id, profileId)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(responses.NewErrorResponse(fiber.StatusInternalServerError, &fiber.Map{"data": "Unexpected Error. Please try again."}, err))
+		}
+		if isBlocked {
+			return c.Status(fiber.StatusLocked).JSON(responses.NewErrorResponse(fiber.StatusLocked, &fiber.Map{"data": "You do not have access to this file."}, nil))
+		}
+		return c.Next()
+	}
+
+	return c.Status(fiber.StatusNotFound).JSON(responses.NewErrorResponse(fiber.StatusNotFound, &fiber.Map{"data": "File not found."}, nil))
+}
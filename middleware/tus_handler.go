@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"nerajima.com/NeraJima/responses"
+)
+
+const TusVersion = "1.0.0" // the only version of the tus protocol the upload routes speak
+
+// Sets the Tus-Resumable header on every response of the upload routes and rejects requests made with another version of the protocol.
+// OPTIONS requests are let through since clients send them to find out which versions are supported.
+func TusHandler(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", TusVersion)
+
+	if c.Method() != fiber.MethodOptions && c.Get("Tus-Resumable") != TusVersion {
+		c.Set("Tus-Version", TusVersion)
+		return c.Status(fiber.StatusPreconditionFailed).JSON(responses.NewErrorResponse(fiber.StatusPreconditionFailed, &fiber.Map{"data": "Unsupported version of the tus protocol."}, nil))
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+/*
+   Creator analytics are read from daily rollups instead of from the join tables directly, so dashboards don't have to count every like
+   and follow on every request. A background job recomputes the rollups of the last couple of days from the created_at columns of the
+   join tables. Days with no activity have no row.
+
+   Unfollows can't be counted from created_at columns since the follow row is deleted, so they are recorded in ProfileUnfollow when they happen.
+*/
+
+// One day of activity on a profile. The likes, dislikes, bookmarks and comments are the totals over all of the profile's posts.
+type ProfileDailyStat struct {
+	ProfileId      string    `json:"profile_id" gorm:"primary_key;size:191"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	Day            time.Time `json:"day" gorm:"primary_key;type:date"`
+	NewFollowers   int64     `json:"new_followers"`
+	Unfollows      int64     `json:"unfollows"`
+	NewSubscribers int64     `json:"new_subscribers"`
+	Likes          int64     `json:"likes"`
+	Dislikes       int64     `json:"dislikes"`
+	Bookmarks      int64     `json:"bookmarks"`
+	Comments       int64     `json:"comments"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// One day of activity on a post
+type PostDailyStat struct {
+	PostId    string    `json:"post_id" gorm:"primary_key;size:191"`
+	Day       time.Time `json:"day" gorm:"primary_key;type:date"`
+	ProfileId string    `json:"profile_id" gorm:"size:191;index"` // owner of the post
+	Likes     int64     `json:"likes"`
+	Dislikes  int64     `json:"dislikes"`
+	Bookmarks int64     `json:"bookmarks"`
+	Comments  int64     `json:"comments"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// A follow of ProfileId by FollowerId that ended, whether the follower unfollowed, was removed or a block ended it
+type ProfileUnfollow struct {
+	Base
+	ProfileId  string `json:"profile_id" gorm:"size:191;index"`
+	FollowerId string `json:"follower_id" gorm:"size:191"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+/*
+   The Audience - Profile relation is a "Has Many" relation where a Profile has many Audiences
+   ProfileId is the foreignKey to the profile and the syntax has to match: <OwnerModelName><OwnerModelPrimaryKeyName>
+
+   The "Members" field is for the many to many relation between the Audience and the Profiles in it => https://gorm.io/docs/many_to_many.html
+
+   An Audience is a named list of profiles (close friends, beta testers...) a post can be limited to. Only the owner and the members can see those posts.
+*/
+
+type Audience struct {
+	Base
+	ProfileId string    `json:"profile_id" gorm:"size:191"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	Name      string    `json:"name"`
+	Members   []Profile `json:"members" gorm:"many2many:audience_members;constraint:OnDelete:CASCADE;"`
+}
+
+// This is a custom junction table for the many-to-many relationship between an Audience and a Member(profile)
+type AudienceMember struct {
+	AudienceId string    `json:"audience_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	ProfileId  string    `json:"member_id" gorm:"primary_key;type:uuid;<-:create"`   // allow read and create (not update)
+	CreatedAt  time.Time `json:"created_at" gorm:"index;<-:create"`                  // allow read and create (not update)
+}
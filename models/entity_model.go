@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+/*
+   Hashtags and mentions are parsed from the title and caption of posts and the body of comments whenever they are created or edited.
+
+   A Hashtag is stored once by name and linked to the posts and comments that use it through the post_hashtags and comment_hashtags join tables.
+
+   A Mention is stored for every @username in the text that resolved to a profile. StartIndex and EndIndex are its offsets in the field in unicode
+   code points, EndIndex is exclusive, so clients can link the text even after the mentioned profile changes its username.
+*/
+
+type Hashtag struct {
+	Base
+	Name string `json:"name" gorm:"uniqueIndex"` // lower case, without the leading #
+}
+
+// This is a custom junction table for the many-to-many relationship between a Post and a Hashtag
+type PostHashtag struct {
+	PostId    string    `json:"post_id" gorm:"primary_key;type:uuid;<-:create"`    // allow read and create (not update)
+	HashtagId string    `json:"hashtag_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	CreatedAt time.Time `json:"created_at" gorm:"index;<-:create"`                 // allow read and create (not update)
+}
+
+// This is a custom junction table for the many-to-many relationship between a Comment and a Hashtag
+type CommentHashtag struct {
+	CommentId string    `json:"comment_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	HashtagId string    `json:"hashtag_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	CreatedAt time.Time `json:"created_at" gorm:"index;<-:create"`                 // allow read and create (not update)
+}
+
+const (
+	MentionFieldTitle   = "title"
+	MentionFieldCaption = "caption"
+	MentionFieldBody    = "body"
+)
+
+// A mention of ProfileId by MentionerId in a post, or in one of its comments when CommentId is set
+type Mention struct {
+	Base
+	ProfileId   string  `json:"profile_id" gorm:"size:191;index"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	MentionerId string  `json:"mentioner_id" gorm:"size:191"`
+	PostId      string  `json:"post_id" gorm:"size:191;index"`
+	CommentId   *string `json:"comment_id" gorm:"size:191;index"` // nil for mentions in the post itself
+	Field       string  `json:"field"`                            // one of the MentionField constants
+	StartIndex  int     `json:"start"`
+	EndIndex    int     `json:"end"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+/*
+   Money is tracked with a double entry ledger. A LedgerTransaction is one movement of money (a charge, a refund or a payout) and
+   its Entries are the accounts it touches. The amounts of the entries of a transaction always add up to zero.
+
+   A positive amount is a debit and a negative amount is a credit. The accounts are
+      1. provider: money held for us by the payment provider
+      2. creator: money owed to the creator in ProfileId
+      3. platform: fees kept by the platform
+
+   Ledger rows are never updated or deleted, a refund is a new transaction that reverses all or part of a charge.
+   They are not tied to profiles with foreign keys so the books stay intact when a profile is deleted.
+*/
+
+const (
+	LedgerKindCharge = "charge"
+	LedgerKindRefund = "refund"
+	LedgerKindPayout = "payout"
+
+	LedgerAccountProvider = "provider"
+	LedgerAccountCreator  = "creator"
+	LedgerAccountPlatform = "platform"
+)
+
+type LedgerTransaction struct {
+	Base
+	Kind        string        `json:"kind" gorm:"index;<-:create"`
+	ProviderRef string        `json:"provider_ref" gorm:"uniqueIndex;<-:create"` // reference of the charge, refund or payout at the payment provider
+	PayerId     *string       `json:"payer_id" gorm:"size:191;index;<-:create"`  // subscriber that paid, nil for payouts
+	CreatorId   string        `json:"creator_id" gorm:"size:191;index;<-:create"`
+	TierId      *string       `json:"tier_id" gorm:"size:191;<-:create"`
+	RefundOfId  *string       `json:"refund_of_id" gorm:"size:191;index;<-:create"` // for refunds, the charge that was refunded. a charge can be refunded in parts
+	PeriodEnd   *time.Time    `json:"period_end" gorm:"<-:create"`                  // for charges, the end of the billing period that was paid for
+	Amount      int64         `json:"amount" gorm:"<-:create"`
+	Currency    string        `json:"currency" gorm:"<-:create"`
+	Entries     []LedgerEntry `json:"entries" gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+type LedgerEntry struct {
+	Base
+	LedgerTransactionId string  `json:"transaction_id" gorm:"size:191;index;<-:create"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	Account             string  `json:"account" gorm:"index;<-:create"`
+	ProfileId           *string `json:"profile_id" gorm:"size:191;index;<-:create"` // set for creator entries
+	Amount              int64   `json:"amount" gorm:"<-:create"`
+	Currency            string  `json:"currency" gorm:"<-:create"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+/*
+   The MutedKeyword - Profile relation is a "Has Many" relation where a Profile has many MutedKeyword
+   ProfileId is the foreignKey to the profile and the syntax has to match: <OwnerModelName><OwnerModelPrimaryKeyName>
+
+   A keyword can also be a phrase. Posts whose title or caption contain it (case insensitive) and comments whose body contain it are hidden from the profile.
+*/
+
+type MutedKeyword struct {
+	Base
+	ProfileId string     `json:"profile_id" gorm:"size:191"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	Keyword   string     `json:"keyword"`
+	ExpiresAt *time.Time `json:"expires_at" gorm:"index"` // nil means the keyword is muted until it is removed
+}
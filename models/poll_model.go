@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+/*
+   The Poll - Post relation is a "Has One" relation where a Post can have one Poll. The poll can't be changed once the post is created.
+
+   The "Options" field is for the "has many" relation between the Poll and PollOption models
+
+   The "Votes" field is for the "has many" relation between the Poll and PollVote models
+
+   A vote is stored as a PollVote for every option the voter chose, so a vote in a multi-select poll can be more than one row.
+   Voting again replaces the whole vote.
+*/
+
+type Poll struct {
+	Base
+	PostId         string       `json:"post_id" gorm:"size:191;uniqueIndex"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	AllowsMultiple bool         `json:"allows_multiple" gorm:"<-:create"`    // allow read and create (not update)
+	ClosesAt       time.Time    `json:"closes_at" gorm:"<-:create"`          // allow read and create (not update)
+	Options        []PollOption `json:"options" gorm:"constraint:OnDelete:CASCADE;"`
+	Votes          []PollVote   `json:"votes" gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+type PollOption struct {
+	Base
+	PollId   string     `json:"poll_id" gorm:"size:191;index"`
+	Position int        `json:"position"`
+	Text     string     `json:"text"`
+	Votes    []PollVote `json:"votes" gorm:"foreignKey:OptionId;constraint:OnDelete:CASCADE;"`
+}
+
+// One option chosen by a voter
+type PollVote struct {
+	Base
+	PollId    string `json:"poll_id" gorm:"size:191;index"`
+	OptionId  string `json:"option_id" gorm:"size:191;uniqueIndex:idx_poll_vote_option_profile"`
+	ProfileId string `json:"profile_id" gorm:"size:191;uniqueIndex:idx_poll_vote_option_profile;index"`
+}
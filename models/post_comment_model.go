@@ -4,15 +4,18 @@ import "time"
 
 type Comment struct {
 	Base
-	PostId             string    `json:"post_id" gorm:"size:191"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
-	CommenterId        string    `json:"commenter_id" gorm:"size:191"`
-	CommentRepliedToId *string   `json:"comment_replied_to_id" gorm:"size:191"` // pointer type allows it to be null
-	Body               string    `json:"body"`
-	Commenter          Profile   `json:"commenter" gorm:"foreignKey:CommenterId;constraint:OnDelete:CASCADE;"`
-	CommentRepliedTo   *Comment  `json:"comment_replied_to" gorm:"foreignKey:CommentRepliedToId;constraint:OnDelete:CASCADE;"`
-	IsEdited           bool      `json:"is_edited"`
-	Likes              []Profile `json:"likes" gorm:"many2many:comment_likes;constraint:OnDelete:CASCADE;"`
-	Dislikes           []Profile `json:"dislikes" gorm:"many2many:comment_likes;constraint:OnDelete:CASCADE;"`
+	PostId             string     `json:"post_id" gorm:"size:191"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	CommenterId        string     `json:"commenter_id" gorm:"size:191"`
+	CommentRepliedToId *string    `json:"comment_replied_to_id" gorm:"size:191"` // pointer type allows it to be null
+	Body               string     `json:"body"`
+	Commenter          Profile    `json:"commenter" gorm:"foreignKey:CommenterId;constraint:OnDelete:CASCADE;"`
+	CommentRepliedTo   *Comment   `json:"comment_replied_to" gorm:"foreignKey:CommentRepliedToId;constraint:OnDelete:CASCADE;"`
+	IsEdited           bool       `json:"is_edited"`
+	Likes              []Profile  `json:"likes" gorm:"many2many:comment_likes;constraint:OnDelete:CASCADE;"`
+	Dislikes           []Profile  `json:"dislikes" gorm:"many2many:comment_likes;constraint:OnDelete:CASCADE;"`
+	Hashtags           []Hashtag  `json:"hashtags" gorm:"many2many:comment_hashtags;constraint:OnDelete:CASCADE;"`
+	Mentions           []Mention  `json:"mentions" gorm:"foreignKey:CommentId;constraint:OnDelete:CASCADE;"`
+	Revisions          []Revision `json:"revisions" gorm:"foreignKey:CommentId;constraint:OnDelete:CASCADE;"`
 }
 
 // This is a custom junction table for the many-to-many relationship between a Comment and a Liker(profile)
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/*
+   The PostDraft - Profile relation is a "Has Many" relation where a Profile has many PostDrafts
+   ProfileId is the foreignKey to the profile and the syntax has to match: <OwnerModelName><OwnerModelPrimaryKeyName>
+
+   The "Media" field is for the "has many" relation between the PostDraft and DraftMedia models
+
+   Drafts are kept out of the posts table so no read of posts, feeds and counts included, can ever return one. Any field can be left
+   empty while the draft is being written. Publishing a draft creates a post from it with the same checks as creating one directly.
+*/
+
+type PostDraft struct {
+	Base
+	ProfileId          string       `json:"profile_id" gorm:"size:191;index"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	Title              string       `json:"title"`
+	Caption            string       `json:"caption"`
+	ForSubscribersOnly *bool        `json:"for_subscribers_only"`
+	IsArchived         *bool        `json:"is_archived"`
+	AudienceId         *string      `json:"audience_id" gorm:"size:191"`
+	MinTierId          *string      `json:"min_tier_id" gorm:"size:191"`
+	PublishAt          *time.Time   `json:"publish_at"`
+	Media              []DraftMedia `json:"media" gorm:"constraint:OnDelete:CASCADE;"`
+	Poll               *DraftPoll   `json:"poll" gorm:"type:jsonb"` // nil for drafts without a poll
+}
+
+// The poll of a draft, kept as json since it only becomes rows in the polls tables when the draft is published
+type DraftPoll struct {
+	Options        []string   `json:"options"`
+	AllowsMultiple *bool      `json:"allows_multiple"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+// Value and Scan store the poll as json. They are used instead of the json serializer since that isn't applied to updates made with a map.
+func (p DraftPoll) Value() (driver.Value, error) {
+	data, err := json.Marshal(p)
+	return string(data), err
+}
+
+func (p *DraftPoll) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case []byte:
+		return json.Unmarshal(data, p)
+	case string:
+		return json.Unmarshal([]byte(data), p)
+	}
+	return fmt.Errorf("cannot scan %T into a draft poll", value)
+}
+
+// An attachment of a draft. Unlike PostMedia its type may not be chosen yet.
+type DraftMedia struct {
+	Base
+	PostDraftId string  `json:"post_draft_id" gorm:"size:191"`
+	Position    int     `json:"position"`
+	MediaUrl    string  `json:"media_url"`
+	UploadId    *string `json:"upload_id" gorm:"size:191"` // a completed upload used in place of MediaUrl, it doesn't expire while a draft refers to it
+	IsImage     *bool   `json:"is_image"`
+	IsVideo     *bool   `json:"is_video"`
+	IsAudio     *bool   `json:"is_audio"`
+}
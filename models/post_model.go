@@ -14,6 +14,19 @@ import (
    The "Media" field is for the "has many" relation between the Post and PostMedia models
 
    The "Comments" field is for the "has many" relation between the Post and PostComment models
+
+   The "Mentions" field is for the "has many" relation between the Post and Mention models
+
+   The "Revisions" field is for the "has many" relation between the Post and Revision models
+
+   The "Uploads" field is for the "has many" relation between the Post and the Upload models attached to it
+
+   The "Poll" field is for the "has one" relation between the Post and Poll models, nil for posts without a poll
+
+   The "Reposts" field is for the "has many" relation between a Post and the posts that repost it. A repost has no title and, unless it
+   quotes the original, no caption. Plain reposts are deleted with the original, quotes stay with RepostOfId set to nil
+
+   The "SearchVector" field is a generated column. The 'simple' text search configuration is used instead of a language one since posts can be in any language
 */
 
 // When in doubt relationships between to models, ask ChatGPT something like this "what is the relationship between a users and a posts table in a mysql server?"
@@ -23,13 +36,24 @@ type Post struct {
 	ProfileId          string      `json:"profile_id" gorm:"size:191"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
 	Title              string      `json:"title"`
 	Caption            string      `json:"caption"`
-	ForSubscribersOnly bool        `json:"for_subscribers_only" gorm:"<-:create"` // allow read and create (not update)
+	ForSubscribersOnly bool        `json:"for_subscribers_only" gorm:"<-:create"`       // allow read and create (not update)
+	AudienceId         *string     `json:"audience_id" gorm:"size:191;index;<-:create"` // nil means the post isn't limited to an audience. allow read and create (not update)
+	MinTierId          *string     `json:"min_tier_id" gorm:"size:191;index;<-:create"` // lowest subscription tier that can see a subscriber only post, nil means every subscriber can. allow read and create (not update)
 	IsArchived         bool        `json:"is_archived"`
+	PublishAt          *time.Time  `json:"publish_at" gorm:"index"`                      // when a scheduled post goes live, nil once it is published. only the owner can see a post before then
+	RepostOfId         *string     `json:"repost_of_id" gorm:"size:191;index;<-:create"` // the original post, nil for posts that aren't reposts. allow read and create (not update)
 	Media              []PostMedia `json:"media" gorm:"constraint:OnDelete:CASCADE;"`
 	Likes              []Profile   `json:"likes" gorm:"many2many:post_likes;constraint:OnDelete:CASCADE;"`
 	Dislikes           []Profile   `json:"dislikes" gorm:"many2many:post_dislikes;constraint:OnDelete:CASCADE;"`
 	Bookmarks          []Profile   `json:"bookmarks" gorm:"many2many:post_bookmarks;constraint:OnDelete:CASCADE;"`
 	Comments           []Comment   `json:"comments" gorm:"constraint:OnDelete:CASCADE;"`
+	Hashtags           []Hashtag   `json:"hashtags" gorm:"many2many:post_hashtags;constraint:OnDelete:CASCADE;"`
+	Mentions           []Mention   `json:"mentions" gorm:"constraint:OnDelete:CASCADE;"`
+	Revisions          []Revision  `json:"revisions" gorm:"constraint:OnDelete:CASCADE;"`
+	Uploads            []Upload    `json:"uploads" gorm:"constraint:OnDelete:CASCADE;"`
+	Poll               *Poll       `json:"poll" gorm:"constraint:OnDelete:CASCADE;"`
+	Reposts            []Post      `json:"reposts" gorm:"foreignKey:RepostOfId;constraint:OnDelete:SET NULL;"`
+	SearchVector       string      `json:"-" gorm:"type:tsvector GENERATED ALWAYS AS (setweight(to_tsvector('simple', coalesce(title, '')), 'A') || setweight(to_tsvector('simple', coalesce(caption, '')), 'B')) STORED;index:,type:gin;->:false;<-:false"` // kept up to date by postgres for full-text search, titles rank above captions. neither read nor written by gorm
 }
 
 type PostMedia struct {
@@ -40,6 +64,89 @@ type PostMedia struct {
 	IsImage  bool   `json:"is_image"`
 	IsVideo  bool   `json:"is_video"`
 	IsAudio  bool   `json:"is_audio"`
+	MediaMetadata
+	MediaRenditions
+}
+
+const (
+	MediaFormatJPEG = "jpeg"
+	MediaFormatPNG  = "png"
+	MediaFormatWebP = "webp"
+	MediaFormatMP4  = "mp4"
+	MediaFormatMP3  = "mp3"
+
+	MediaKindImage = "image"
+	MediaKindVideo = "video"
+	MediaKindAudio = "audio"
+
+	RenditionThumbnail = "thumbnail"
+	RenditionMedium    = "medium"
+	RenditionFull      = "full"
+)
+
+// What the server read from a media file. Files are inspected when they are uploaded, the fields are empty for media that was linked by url before media had to be uploaded.
+type MediaMetadata struct {
+	Format     string  `json:"format"`      // one of the MediaFormat constants
+	Size       int64   `json:"size"`        // in bytes
+	Width      int     `json:"width"`       // in pixels, images and videos only
+	Height     int     `json:"height"`      // in pixels, images and videos only
+	Duration   float64 `json:"duration"`    // in seconds, videos and audio only
+	VideoCodec string  `json:"video_codec"` // e.g. h264, hevc, av1
+	AudioCodec string  `json:"audio_codec"` // e.g. aac, mp3, opus
+}
+
+// Smaller copies of an uploaded image, see utils/image_renditions.go. The urls are empty for other media, WebP images and images made before
+// renditions existed, clients fall back to the media url then. The blurhash is a placeholder to show while an image loads.
+type MediaRenditions struct {
+	ThumbnailUrl string `json:"thumbnail_url"`
+	MediumUrl    string `json:"medium_url"`
+	FullUrl      string `json:"full_url"`
+	Blurhash     string `json:"blurhash"`
+}
+
+// Returns one of the MediaKind constants. MP4 files without a video track are audio. Empty if the file wasn't recognized.
+func (m MediaMetadata) Kind() string {
+	switch m.Format {
+	case MediaFormatJPEG, MediaFormatPNG, MediaFormatWebP:
+		return MediaKindImage
+	case MediaFormatMP3:
+		return MediaKindAudio
+	case MediaFormatMP4:
+		if m.VideoCodec != "" {
+			return MediaKindVideo
+		}
+		if m.AudioCodec != "" {
+			return MediaKindAudio
+		}
+	}
+	return ""
+}
+
+// Returns the extension stored files of the format are named with, including the dot. Empty if the file wasn't recognized.
+func (m MediaMetadata) Extension() string {
+	switch m.Format {
+	case MediaFormatJPEG:
+		return ".jpg"
+	case MediaFormatPNG, MediaFormatWebP, MediaFormatMP4, MediaFormatMP3:
+		return "." + m.Format
+	}
+	return ""
+}
+
+// Returns the media type files of the format are stored with, application/octet-stream if the file wasn't recognized
+func (m MediaMetadata) ContentType() string {
+	switch m.Kind() {
+	case MediaKindImage:
+		return "image/" + m.Format
+	case MediaKindVideo:
+		return "video/mp4"
+	case MediaKindAudio:
+		if m.Format == MediaFormatMP3 {
+			return "audio/mpeg"
+		}
+		return "audio/mp4"
+	}
+	return "application/octet-stream"
 }
 
 func (pm *PostMedia) BeforeCreate(tx *gorm.DB) error {
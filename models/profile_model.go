@@ -11,46 +11,93 @@ import (
    The Profile - User relation is a "Has One" relation where the User has one Profile.
    UserId is the foreignKey to the user and the syntax has to match: <OwnerModelName><OwnerModelPrimaryKeyName>
 
-   The Profile has a many to many relation with itself for 2 fields: followers & subscribers => https://gorm.io/docs/many_to_many.html#Self-Referential-Many2Many
+   The Profile has a many to many relation with itself for 5 fields: followers, subscribers, blocked, muted & dismissed => https://gorm.io/docs/many_to_many.html#Self-Referential-Many2Many
 
    The "SearchHistory" field is for the "has many" relation between the Profile and SearchHistory models
 
+   The "MutedKeywords" field is for the "has many" relation between the Profile and MutedKeyword models
+
    The "Posts" field is for the "has many" relation between the Profile and Post models
 
+   The "Audiences" field is for the "has many" relation between the Profile and Audience models
+
+   The "SubscriptionTiers" field is for the "has many" relation between the Profile and SubscriptionTier models
+
    The "Notifications" field is for the "has many" relation between the Profile and Notification models
+
+   The "Mentions" field is for the "has many" relation between the mentioned Profile and Mention models
+
+   The "PostDrafts" field is for the "has many" relation between the Profile and PostDraft models
+
+   The "Uploads" field is for the "has many" relation between the Profile and Upload models
+
+   The "PollVotes" field is for the "has many" relation between the voting Profile and PollVote models
 */
 
 type Profile struct {
 	Base
-	UserId        string          `json:"user_id" gorm:"size:191"`
-	Username      string          `json:"username" gorm:"unique"`
-	Name          string          `json:"name"`
-	Bio           string          `json:"bio" gorm:"default:🚀🚀🚀🚀🚀🚀🚀🚀"`
-	Avatar        string          `json:"avatar"`
-	MiniAvatar    string          `json:"mini_avatar"`
-	Birthday      time.Time       `json:"birthday"`
-	Followers     []*Profile      `json:"followers" gorm:"many2many:profile_followers;constraint:OnDelete:CASCADE;"`
-	Subscribers   []*Profile      `json:"subscribers" gorm:"many2many:profile_subscribers;constraint:OnDelete:CASCADE;"`
-	SearchHistory []SearchHistory `json:"search_history" gorm:"constraint:OnDelete:CASCADE;"`
-	Posts         []Post          `json:"posts" gorm:"constraint:OnDelete:CASCADE;"`
-	Notifications []Notification  `json:"notifications" gorm:"constraint:OnDelete:CASCADE;"`
+	UserId            string             `json:"user_id" gorm:"size:191"`
+	Username          string             `json:"username" gorm:"unique"`
+	Name              string             `json:"name"`
+	Bio               string             `json:"bio" gorm:"default:🚀🚀🚀🚀🚀🚀🚀🚀"`
+	Avatar            string             `json:"avatar"`
+	MiniAvatar        string             `json:"mini_avatar"`
+	Birthday          time.Time          `json:"birthday"`
+	IsPrivate         bool               `json:"is_private" gorm:"default:false"` // if true, new followers have to be approved and public posts are limited to followers
+	Followers         []*Profile         `json:"followers" gorm:"many2many:profile_followers;constraint:OnDelete:CASCADE;"`
+	Subscribers       []*Profile         `json:"subscribers" gorm:"many2many:profile_subscribers;constraint:OnDelete:CASCADE;"`
+	Blocked           []*Profile         `json:"blocked" gorm:"many2many:profile_blocks;constraint:OnDelete:CASCADE;"`
+	Muted             []*Profile         `json:"muted" gorm:"many2many:profile_mutes;constraint:OnDelete:CASCADE;"`
+	Dismissed         []*Profile         `json:"dismissed" gorm:"many2many:profile_suggestion_dismissals;constraint:OnDelete:CASCADE;"`
+	SearchHistory     []SearchHistory    `json:"search_history" gorm:"constraint:OnDelete:CASCADE;"`
+	MutedKeywords     []MutedKeyword     `json:"muted_keywords" gorm:"constraint:OnDelete:CASCADE;"`
+	Posts             []Post             `json:"posts" gorm:"constraint:OnDelete:CASCADE;"`
+	Audiences         []Audience         `json:"audiences" gorm:"constraint:OnDelete:CASCADE;"`
+	SubscriptionTiers []SubscriptionTier `json:"subscription_tiers" gorm:"constraint:OnDelete:CASCADE;"`
+	Notifications     []Notification     `json:"notifications" gorm:"constraint:OnDelete:CASCADE;"`
+	Mentions          []Mention          `json:"mentions" gorm:"constraint:OnDelete:CASCADE;"`
+	PostDrafts        []PostDraft        `json:"post_drafts" gorm:"constraint:OnDelete:CASCADE;"`
+	Uploads           []Upload           `json:"uploads" gorm:"constraint:OnDelete:CASCADE;"`
+	PollVotes         []PollVote         `json:"poll_votes" gorm:"constraint:OnDelete:CASCADE;"`
 }
 
 // This is a custom junction table for the self-referencing many-to-many relationship between a Profile and a Follower
+//
+// A pending row is a follow request to a private profile that hasn't been accepted yet. Only non pending rows count as followers.
 type ProfileFollower struct {
 	ProfileId  string    `json:"followed_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
 	FollowerId string    `json:"follower_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
-	CreatedAt  time.Time `json:"created_at" gorm:"index;<-:create"`                  // allow read and create (not update)
+	IsPending  bool      `json:"is_pending" gorm:"default:false"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index;<-:create"` // allow read and create (not update)
 }
 
 // This is a custom junction table for the self-referencing many-to-many relationship between a Profile and a Subscriber
+//
+// TierId is the tier the invite or request targets and, once accepted, the tier of the subscription. A nil TierId ranks below every tier.
+// RequestedTierId is a free tier ranked above TierId that the subscriber asked to move to, the subscription only moves once the profile accepts.
+//
+// Paid subscriptions last until CurrentPeriodEnd and are then renewed, unless CancelAtPeriodEnd is set. Free subscriptions have a nil CurrentPeriodEnd.
+//
+// Invites and requests that haven't been accepted by PendingExpiresAt expire and are cleaned up by a background job. Message is the note sent with an invite.
+//
+// Free subscriptions can be limited in time (trials, passes). AccessDuration is how long an invite or request lasts once accepted, in seconds, and ExpiresAt
+// is when an accepted subscription ends. A nil ExpiresAt means the subscription doesn't end. ExpiryNotified is set once the subscriber was told it ends soon.
 type ProfileSubscriber struct {
-	ProfileId    string    `json:"profile_id" gorm:"primary_key;type:uuid;<-:create"`    // allow read and create (not update)
-	SubscriberId string    `json:"subscriber_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
-	IsInvite     bool      `json:"is_invite" gorm:"<-:create"`                           // allow read and create (not update)
-	IsRequest    bool      `json:"is_request" gorm:"<-:create"`                          // allow read and create (not update)
-	IsAccepted   bool      `json:"is_accepted" gorm:"default:false"`
-	CreatedAt    time.Time `json:"created_at" gorm:"index;<-:create"` // allow read and create (not update)
+	ProfileId         string     `json:"profile_id" gorm:"primary_key;type:uuid;<-:create"`    // allow read and create (not update)
+	SubscriberId      string     `json:"subscriber_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	IsInvite          bool       `json:"is_invite" gorm:"<-:create"`                           // allow read and create (not update)
+	IsRequest         bool       `json:"is_request" gorm:"<-:create"`                          // allow read and create (not update)
+	IsAccepted        bool       `json:"is_accepted" gorm:"default:false"`
+	TierId            *string    `json:"tier_id" gorm:"size:191;index"`
+	RequestedTierId   *string    `json:"requested_tier_id" gorm:"size:191"`
+	CurrentPeriodEnd  *time.Time `json:"current_period_end" gorm:"index"`
+	CancelAtPeriodEnd bool       `json:"cancel_at_period_end" gorm:"default:false"`
+	PendingExpiresAt  *time.Time `json:"pending_expires_at" gorm:"index"`
+	Message           string     `json:"message"`
+	AccessDuration    *int64     `json:"access_duration"`
+	ExpiresAt         *time.Time `json:"expires_at" gorm:"index"`
+	ExpiryNotified    bool       `json:"expiry_notified" gorm:"default:false"`
+	CreatedAt         time.Time  `json:"created_at" gorm:"index;<-:create"` // allow read and create (not update)
 }
 
 func (ps *ProfileSubscriber) BeforeCreate(tx *gorm.DB) error {
@@ -59,3 +106,27 @@ func (ps *ProfileSubscriber) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// This is a custom junction table for the self-referencing many-to-many relationship between a Profile and a Profile it blocked
+type ProfileBlock struct {
+	ProfileId string    `json:"blocker_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	BlockedId string    `json:"blocked_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	CreatedAt time.Time `json:"created_at" gorm:"index;<-:create"`                 // allow read and create (not update)
+}
+
+// This is a custom junction table for the self-referencing many-to-many relationship between a Profile and a Profile it muted
+//
+// Muting is one sided, the muted profile is not told and can still interact. A nil ExpiresAt means the mute lasts until it is removed.
+type ProfileMute struct {
+	ProfileId string     `json:"muter_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	MutedId   string     `json:"muted_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	ExpiresAt *time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index;<-:create"` // allow read and create (not update)
+}
+
+// This is a custom junction table for the self-referencing many-to-many relationship between a Profile and a suggested Profile it dismissed
+type ProfileSuggestionDismissal struct {
+	ProfileId   string    `json:"profile_id" gorm:"primary_key;type:uuid;<-:create"`   // allow read and create (not update)
+	DismissedId string    `json:"dismissed_id" gorm:"primary_key;type:uuid;<-:create"` // allow read and create (not update)
+	CreatedAt   time.Time `json:"created_at" gorm:"index;<-:create"`                   // allow read and create (not update)
+}
@@ -0,0 +1,32 @@
+package models
+
+/*
+   A Revision is saved every time the text of a post or comment is edited, including when an older revision is restored.
+
+   It holds the version the edit replaced, so together with the current post or comment the revisions make up the whole edit history.
+   Title and Caption are set for revisions of posts and Body for revisions of comments. CreatedAt is the time of the edit.
+
+   Diff holds, for every field the edit changed, the changes that turn the text of the revision into the text that replaced it.
+*/
+
+const (
+	DiffOpEqual  = "equal"
+	DiffOpInsert = "insert"
+	DiffOpDelete = "delete"
+)
+
+// A run of text that is kept, inserted or deleted by an edit
+type DiffChange struct {
+	Op   string `json:"op"` // one of the DiffOp constants
+	Text string `json:"text"`
+}
+
+type Revision struct {
+	Base
+	PostId    string                  `json:"post_id" gorm:"size:191;index"`    // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	CommentId *string                 `json:"comment_id" gorm:"size:191;index"` // nil for revisions of the post itself
+	Title     string                  `json:"title,omitempty"`
+	Caption   string                  `json:"caption,omitempty"`
+	Body      string                  `json:"body,omitempty"`
+	Diff      map[string][]DiffChange `json:"diff" gorm:"type:jsonb;serializer:json"` // keyed by field name
+}
@@ -1,12 +1,29 @@
 package models
 
+import "time"
+
 /*
    The SearchHistory - Profile relation is a "Has Many" relation where a Profile has many SearchHistory
    ProfileId is the foreignKey to the profile and the syntax has to match: <OwnerModelName><OwnerModelPrimaryKeyName>
 */
 
+const (
+	SearchTypeQuery   = "query"   // text typed into the search bar
+	SearchTypeProfile = "profile" // profile clicked in the search results
+	SearchTypeHashtag = "hashtag"
+)
+
+/*
+   Query is the lower cased searched text for query and hashtag entries (hashtags are stored without the "#") and SearchedProfileId is the clicked
+   profile for profile entries. The field that doesn't apply is left empty so the same search always maps to the same row, which is
+   bumped to the top by updating SearchedAt instead of being added again.
+*/
+
 type SearchHistory struct {
 	Base
-	ProfileId string `json:"profile_id" gorm:"size:191"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
-	Query     string `json:"query"`
+	ProfileId         string    `json:"profile_id" gorm:"size:191;uniqueIndex:idx_search_histories_entry"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	Type              string    `json:"type" gorm:"uniqueIndex:idx_search_histories_entry;default:query"`
+	Query             string    `json:"query" gorm:"uniqueIndex:idx_search_histories_entry;default:''"`
+	SearchedProfileId string    `json:"searched_profile_id" gorm:"size:191;uniqueIndex:idx_search_histories_entry;default:''"`
+	SearchedAt        time.Time `json:"searched_at" gorm:"index;default:CURRENT_TIMESTAMP"`
 }
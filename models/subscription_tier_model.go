@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+/*
+   The SubscriptionTier - Profile relation is a "Has Many" relation where a Profile has many SubscriptionTiers
+   ProfileId is the foreignKey to the profile and the syntax has to match: <OwnerModelName><OwnerModelPrimaryKeyName>
+
+   Tiers are ordered by Rank, a higher rank includes everything the lower ranks get. A subscription without a tier ranks below every tier,
+   so it can only see subscriber only posts that don't require a minimum tier.
+
+   A tier with a Price is paid for every BillingPeriod. Free tiers have a Price of 0 and no BillingPeriod.
+*/
+
+const (
+	BillingPeriodMonth = "month"
+	BillingPeriodYear  = "year"
+)
+
+type SubscriptionTier struct {
+	Base
+	ProfileId     string `json:"profile_id" gorm:"size:191;uniqueIndex:idx_subscription_tiers_profile_rank"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	Name          string `json:"name"`
+	Rank          int    `json:"rank" gorm:"uniqueIndex:idx_subscription_tiers_profile_rank"`
+	Price         int64  `json:"price" gorm:"default:0"` // in the smallest unit of Currency
+	Currency      string `json:"currency"`
+	BillingPeriod string `json:"billing_period"`
+}
+
+func (st SubscriptionTier) IsPaid() bool {
+	return st.Price > 0
+}
+
+// Returns when a billing period that starts at from ends
+func (st SubscriptionTier) PeriodEnd(from time.Time) time.Time {
+	if st.BillingPeriod == BillingPeriodYear {
+		return from.AddDate(1, 0, 0)
+	}
+	return from.AddDate(0, 1, 0)
+}
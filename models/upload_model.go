@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+/*
+   The Upload - Profile relation is a "Has Many" relation where a Profile has many Uploads
+   ProfileId is the foreignKey to the profile and the syntax has to match: <OwnerModelName><OwnerModelPrimaryKeyName>
+
+   An Upload is a media file sent in chunks with the tus resumable upload protocol (https://tus.io/protocols/resumable-upload).
+   Received is the number of bytes stored so far. Once it reaches Length the chunks are joined into one file at Key and CompletedAt is set.
+
+   The chunks are inspected before they are joined, see utils/media_inspect.go, and what was found is kept in MediaMetadata. The joined file
+   is named and typed after the format that was found, uploads that aren't a supported media file are deleted once their last byte is received.
+
+   A completed upload is a media handle: its id is given as the upload_id of a media entry, which attaches it to that post.
+   Uploads that aren't attached to a post by ExpiresAt are deleted by a background job unless a draft refers to them, attached uploads are deleted along with their post.
+*/
+
+type Upload struct {
+	Base
+	ProfileId       string     `json:"profile_id" gorm:"size:191;index"` // for info on the size parameter: https://github.com/go-gorm/gorm/issues/3369
+	PostId          *string    `json:"post_id" gorm:"size:191;index"`    // nil until the upload is attached to a post
+	Length          int64      `json:"length"`                           // size of the whole file in bytes
+	Received        int64      `json:"offset"`
+	FileName        string     `json:"file_name"`    // from the Upload-Metadata header, only kept for the client
+	ContentType     string     `json:"content_type"` // from the Upload-Metadata header until the upload completes, then the media type of the inspected format
+	Key             string     `json:"-"`            // storage key of the joined file, empty until the upload is complete
+	ExpiresAt       time.Time  `json:"expires_at" gorm:"index"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	MediaMetadata              // read from the file when the upload completes, copied to the media of the post it is attached to
+	MediaRenditions            // made in the background once an image upload completes, copied along with MediaMetadata
+}
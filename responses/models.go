@@ -13,6 +13,72 @@ type MiniProfile struct {
 	MiniAvatar string `json:"mini_avatar"`
 }
 
+// Miniture representation of a suggested profile and the number of profiles followed by the request user that follow it.
+type SuggestedProfile struct {
+	MiniProfile
+	NumMutuals int `json:"num_mutuals"`
+}
+
+// Miniture representation of a muted profile and when the mute ends. A nil ExpiresAt means the mute doesn't end.
+type MutedProfile struct {
+	MiniProfile
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Miniture representation of a profile with a subscriber invite or request that hasn't been accepted yet.
+type PendingSubscription struct {
+	MiniProfile
+	TierId    *string    `json:"tier_id"`
+	Message   string     `json:"message"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Miniture representation of a profile with an accepted subscription. ExpiresAt is when access ends and is nil for subscriptions that don't end,
+// RenewsAt is when a paid subscription is charged again. RemainingSeconds is the time left until ExpiresAt.
+type Subscription struct {
+	MiniProfile
+	TierId           *string    `json:"tier_id"`
+	RequestedTierId  *string    `json:"requested_tier_id"` // a higher free tier the subscriber asked to move to, nil if there is no such request
+	ExpiresAt        *time.Time `json:"expires_at"`
+	RenewsAt         *time.Time `json:"renews_at"`
+	RemainingSeconds *int64     `json:"remaining_seconds"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// How the request user is related to a profile.
+type Relationship struct {
+	ProfileId         string `json:"profile_id"`
+	IsFollowing       bool   `json:"is_following"`        // request user follows the profile
+	IsFollowRequested bool   `json:"is_follow_requested"` // request user sent a follow request that hasn't been accepted
+	IsFollowedBy      bool   `json:"is_followed_by"`      // profile follows the request user
+	IsSubscribed      bool   `json:"is_subscribed"`       // request user is subscribed to the profile
+	IsSubscriber      bool   `json:"is_subscriber"`       // profile is subscribed to the request user
+	IsBlocked         bool   `json:"is_blocked"`          // request user blocked the profile
+	IsMuted           bool   `json:"is_muted"`            // request user muted the profile
+}
+
+// Representation of an audience owned by the request user.
+type Audience struct {
+	Id         string    `json:"id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	NumMembers int       `json:"num_members"`
+}
+
+// Representation of a charge, refund or payout without its ledger entries.
+type Transaction struct {
+	Id         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	PayerId    *string   `json:"payer_id"`
+	CreatorId  string    `json:"creator_id"`
+	TierId     *string   `json:"tier_id"`
+	RefundOfId *string   `json:"refund_of_id"` // for refunds, the charge that was refunded
+	Amount     int64     `json:"amount"`
+	Currency   string    `json:"currency"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
 // Collective representation of a post, it's owner, it's media, and other metadata.
 type Post struct {
 	PostId    string    `json:"post_id"`
@@ -35,6 +101,41 @@ type Post struct {
 	IsLiked      bool `json:"is_liked"`
 	IsDisliked   bool `json:"is_disliked"`
 	IsBookmarked bool `json:"is_bookmarked"`
+
+	Entities []Entity `json:"entities" gorm:"-"`
+
+	Poll *Poll `json:"poll" gorm:"-"` // nil for posts without a poll
+
+	// A repost without a caption of its own is shown as the original with attribution to the reposter. RepostOf is nil if the post
+	// isn't a repost or the request user can't see the original.
+	RepostOfId *string `json:"repost_of_id" gorm:"-"`
+	RepostOf   *Post   `json:"repost_of" gorm:"-"`
+	NumReposts int     `json:"num_reposts" gorm:"-"` // reposts and quotes
+	IsReposted bool    `json:"is_reposted" gorm:"-"` // the request user has reposted the post without a caption
+
+	PublishAt *time.Time `json:"publish_at,omitempty"` // only set for scheduled posts
+
+	// Only set in search results, the title and a snippet of the caption with the matched words wrapped in <mark> tags
+	TitleHighlight   string `json:"title_highlight,omitempty"`
+	CaptionHighlight string `json:"caption_highlight,omitempty"`
+}
+
+// Representation of a poll as seen by the request user. The vote counts are nil until the request user votes or the poll closes,
+// owners of the poll always see them. VotedOptionIds is the request user's own vote, empty if they haven't voted.
+type Poll struct {
+	PollId         string       `json:"poll_id"`
+	AllowsMultiple bool         `json:"allows_multiple"`
+	ClosesAt       time.Time    `json:"closes_at"`
+	IsClosed       bool         `json:"is_closed"`
+	NumVoters      *int         `json:"num_voters"`
+	Options        []PollOption `json:"options"`
+	VotedOptionIds []string     `json:"voted_option_ids"`
+}
+
+type PollOption struct {
+	OptionId string `json:"option_id"`
+	Text     string `json:"text"`
+	NumVotes *int   `json:"num_votes"`
 }
 
 // Collective representation of a comment, it's owner, and other metadata.
@@ -54,4 +155,71 @@ type Comment struct {
 
 	IsLiked    bool `json:"is_liked"`
 	IsDisliked bool `json:"is_disliked"`
+
+	Entities []Entity `json:"entities" gorm:"-"`
+}
+
+// A hashtag or mention in the text field of a post or comment. Start and End are offsets in unicode code points and include the leading # or @,
+// End is exclusive. Tag is set for hashtags and Profile for mentions.
+type Entity struct {
+	Type    string       `json:"type"`
+	Field   string       `json:"field"`
+	Start   int          `json:"start"`
+	End     int          `json:"end"`
+	Tag     string       `json:"tag,omitempty"`
+	Profile *MiniProfile `json:"profile,omitempty"`
+}
+
+// An entry of the request user's search history. Profile is set for entries of clicked profiles.
+type SearchHistoryEntry struct {
+	Id         string       `json:"id"`
+	Type       string       `json:"type"`
+	Query      string       `json:"query"`
+	Profile    *MiniProfile `json:"profile"`
+	SearchedAt time.Time    `json:"searched_at"`
+}
+
+// A term searched by many profiles recently. NumSearchers is the number of profiles that searched it, counted once per hour.
+type TrendingSearch struct {
+	Term         string `json:"term"`
+	NumSearchers int64  `json:"num_searchers"`
+}
+
+// Activity on a profile over the period starting at PeriodStart. Likes, dislikes, bookmarks and comments are the totals over all of the profile's posts.
+type ProfileStats struct {
+	PeriodStart    time.Time `json:"period_start"`
+	NewFollowers   int64     `json:"new_followers"`
+	Unfollows      int64     `json:"unfollows"`
+	NewSubscribers int64     `json:"new_subscribers"`
+	Likes          int64     `json:"likes"`
+	Dislikes       int64     `json:"dislikes"`
+	Bookmarks      int64     `json:"bookmarks"`
+	Comments       int64     `json:"comments"`
+}
+
+// Activity on a post over the period starting at PeriodStart
+type PostStats struct {
+	PeriodStart time.Time `json:"period_start"`
+	Likes       int64     `json:"likes"`
+	Dislikes    int64     `json:"dislikes"`
+	Bookmarks   int64     `json:"bookmarks"`
+	Comments    int64     `json:"comments"`
+}
+
+// A follow or subscription of the request user in an export of their follow graph. IsPending is set for follow requests that haven't been accepted.
+type GraphEntry struct {
+	Type      string    `json:"type"`
+	ProfileId string    `json:"profile_id"`
+	Username  string    `json:"username"`
+	TierId    *string   `json:"tier_id"`
+	IsPending bool      `json:"is_pending"`
+	Since     time.Time `json:"since"`
+}
+
+// What happened to one entry of an imported follow graph. Index is the position of the entry in the import.
+type GraphImportResult struct {
+	Index    int    `json:"index"`
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
 }
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	paymentcontrollers "nerajima.com/NeraJima/controllers/payment_controllers"
+)
+
+func PaymentsRouter(group fiber.Router) {
+	router := group.Group("/payments") // domain/api/payments
+
+	router.Post("/webhook", paymentcontrollers.HandleWebhook) // requests are authenticated by their signature, not by a user
+}
@@ -14,6 +14,12 @@ func PostsRouter(group fiber.Router) {
 	reactionsRouter(router)
 	bookmarksRouter(router)
 	commentsRouter(router)
+	scheduledRouter(router)
+	draftsRouter(router)
+	revisionsRouter(router)
+	mediaRouter(router)
+	pollsRouter(router)
+	repostsRouter(router)
 }
 
 func crudRouter(group fiber.Router) {
@@ -23,6 +29,7 @@ func crudRouter(group fiber.Router) {
 	router.Get("/get/:postId", middleware.UserAuthHandler, postcontrollers.GetPost)
 	router.Put("/edit/:postId", middleware.UserAuthHandler, postcontrollers.EditPost)
 	router.Delete("/delete/:postId", middleware.UserAuthHandler, postcontrollers.DeletePost)
+	router.Get("/analytics/:postId", middleware.UserAuthHandler, postcontrollers.GetPostAnalytics)
 }
 
 func specializedReadsRouter(group fiber.Router) {
@@ -33,6 +40,8 @@ func specializedReadsRouter(group fiber.Router) {
 	router.Get("/user/archives", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.GetArchivedPosts)
 	router.Get("/get/public/:profileId", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.GetPublicPosts)
 	router.Get("/get/exclusive/:profileId", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.GetExclusivePosts)
+	router.Get("/hashtag/:tag", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.GetHashtagPosts)
+	router.Get("/search", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.SearchPosts)
 }
 
 func reactionsRouter(group fiber.Router) {
@@ -74,3 +83,52 @@ func commentsRouter(group fiber.Router) {
 	router.Delete("/:commentId/like/remove", middleware.UserAuthHandler, postcontrollers.RemoveLikeFromComment)
 	router.Delete("/:commentId/dislike/remove", middleware.UserAuthHandler, postcontrollers.RemoveDislikeFromComment)
 }
+
+func scheduledRouter(group fiber.Router) {
+	router := group.Group("/scheduled") // domain/api/posts/scheduled
+
+	router.Get("/get", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.GetScheduledPosts)
+	router.Put("/:postId/reschedule", middleware.UserAuthHandler, postcontrollers.ReschedulePost)
+	router.Delete("/:postId/cancel", middleware.UserAuthHandler, postcontrollers.CancelScheduledPost)
+}
+
+func draftsRouter(group fiber.Router) {
+	router := group.Group("/drafts") // domain/api/posts/drafts
+
+	router.Post("/create", middleware.UserAuthHandler, postcontrollers.CreateDraft)
+	router.Get("/get", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.GetDrafts)
+	router.Get("/get/:draftId", middleware.UserAuthHandler, postcontrollers.GetDraft)
+	router.Put("/:draftId/save", middleware.UserAuthHandler, postcontrollers.SaveDraft)
+	router.Post("/:draftId/publish", middleware.UserAuthHandler, postcontrollers.PublishDraft)
+	router.Delete("/:draftId/delete", middleware.UserAuthHandler, postcontrollers.DeleteDraft)
+}
+
+func revisionsRouter(group fiber.Router) {
+	router := group.Group("/revisions") // domain/api/posts/revisions
+
+	router.Get("/post/:postId", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.GetPostRevisions)
+	router.Get("/comment/:commentId", middleware.UserAuthHandler, middleware.PaginationHandler, postcontrollers.GetCommentRevisions)
+	router.Post("/:revisionId/restore", middleware.UserAuthHandler, postcontrollers.RestoreRevision)
+}
+
+func mediaRouter(group fiber.Router) {
+	router := group.Group("/media") // domain/api/posts/media
+
+	router.Post("/:postId/add", middleware.UserAuthHandler, postcontrollers.AddPostMedia)
+	router.Delete("/:postId/remove/:mediaId", middleware.UserAuthHandler, postcontrollers.RemovePostMedia)
+	router.Put("/:postId/reorder", middleware.UserAuthHandler, postcontrollers.ReorderPostMedia)
+}
+
+func pollsRouter(group fiber.Router) {
+	router := group.Group("/polls") // domain/api/posts/polls
+
+	router.Post("/:postId/vote", middleware.UserAuthHandler, postcontrollers.VotePoll)
+	router.Delete("/:postId/retract", middleware.UserAuthHandler, postcontrollers.RetractPollVote)
+}
+
+func repostsRouter(group fiber.Router) {
+	router := group.Group("/reposts") // domain/api/posts/reposts
+
+	router.Post("/:postId/create", middleware.UserAuthHandler, postcontrollers.RepostPost)
+	router.Delete("/:postId/remove", middleware.UserAuthHandler, postcontrollers.RemoveRepost)
+}
@@ -9,10 +9,54 @@ import (
 func ProfileRouter(group fiber.Router) {
 	router := group.Group("/profile") // domain/api/profile
 
+	analyticsRouter(router)
+	audiencesRouter(router)
+	billingRouter(router)
+	blocksRouter(router)
 	editRouter(router)
 	followersRouter(router)
+	graphRouter(router)
+	mutesRouter(router)
+	relationshipsRouter(router)
 	searchHistoryRouter(router)
 	subscribersRouter(router)
+	suggestionsRouter(router)
+}
+
+func analyticsRouter(group fiber.Router) {
+	router := group.Group("/analytics") // domain/api/profile/analytics
+
+	router.Get("/get", middleware.UserAuthHandler, profilecontrollers.GetProfileAnalytics)
+}
+
+func audiencesRouter(group fiber.Router) {
+	router := group.Group("/audiences") // domain/api/profile/audiences
+
+	router.Post("/create", middleware.UserAuthHandler, profilecontrollers.CreateAudience)
+	router.Get("/get", middleware.UserAuthHandler, profilecontrollers.GetAudiences)
+	router.Put("/:audienceId/rename", middleware.UserAuthHandler, profilecontrollers.RenameAudience)
+	router.Delete("/:audienceId/delete", middleware.UserAuthHandler, profilecontrollers.DeleteAudience)
+
+	router.Post("/:audienceId/members/add/:profileId", middleware.UserAuthHandler, profilecontrollers.AddToAudience)
+	router.Delete("/:audienceId/members/remove/:profileId", middleware.UserAuthHandler, profilecontrollers.RemoveFromAudience)
+	router.Get("/:audienceId/members/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetAudienceMembers)
+}
+
+func billingRouter(group fiber.Router) {
+	router := group.Group("/billing") // domain/api/profile/billing
+
+	router.Get("/balance", middleware.UserAuthHandler, profilecontrollers.GetBalance)
+	router.Post("/payout", middleware.UserAuthHandler, profilecontrollers.RequestPayout)
+	router.Post("/refund/:transactionId", middleware.UserAuthHandler, profilecontrollers.RefundACharge)
+	router.Get("/transactions", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetTransactions)
+}
+
+func blocksRouter(group fiber.Router) {
+	router := group.Group("/blocks") // domain/api/profile/blocks
+
+	router.Post("/block/:profileId", middleware.UserAuthHandler, profilecontrollers.BlockAUser)
+	router.Delete("/unblock/:profileId", middleware.UserAuthHandler, profilecontrollers.UnblockAUser)
+	router.Get("/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetBlocked)
 }
 
 func editRouter(group fiber.Router) {
@@ -22,6 +66,7 @@ func editRouter(group fiber.Router) {
 	router.Put("/name", middleware.UserAuthHandler, profilecontrollers.EditName)
 	router.Put("/bio", middleware.UserAuthHandler, profilecontrollers.EditBio)
 	router.Put("/avatar", middleware.UserAuthHandler, profilecontrollers.EditAvatar)
+	router.Put("/private", middleware.UserAuthHandler, profilecontrollers.EditPrivacy)
 }
 
 func followersRouter(group fiber.Router) {
@@ -32,6 +77,38 @@ func followersRouter(group fiber.Router) {
 	router.Delete("/remove/:profileId", middleware.UserAuthHandler, profilecontrollers.RemoveAFollower)
 	router.Get("/get-followers/:profileId", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetFollowers)
 	router.Get("/get-following/:profileId", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetFollowing)
+
+	router.Delete("/request/cancel/:profileId", middleware.UserAuthHandler, profilecontrollers.CancelFollowRequest)
+	router.Put("/request/accept/:senderId", middleware.UserAuthHandler, profilecontrollers.AcceptFollowRequest)
+	router.Delete("/request/decline/:senderId", middleware.UserAuthHandler, profilecontrollers.DeclineFollowRequest)
+	router.Get("/requests/sent/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetFollowRequestsSent)
+	router.Get("/requests/received/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetFollowRequestsReceived)
+}
+
+func graphRouter(group fiber.Router) {
+	router := group.Group("/graph") // domain/api/profile/graph
+
+	router.Get("/export", middleware.UserAuthHandler, profilecontrollers.ExportGraph)
+	router.Post("/import", middleware.UserAuthHandler, profilecontrollers.ImportGraph)
+}
+
+func mutesRouter(group fiber.Router) {
+	router := group.Group("/mutes") // domain/api/profile/mutes
+
+	router.Post("/mute/:profileId", middleware.UserAuthHandler, profilecontrollers.MuteAUser)
+	router.Delete("/unmute/:profileId", middleware.UserAuthHandler, profilecontrollers.UnmuteAUser)
+	router.Get("/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetMuted)
+
+	router.Post("/keywords/add", middleware.UserAuthHandler, profilecontrollers.AddMutedKeyword)
+	router.Delete("/keywords/remove/:keywordId", middleware.UserAuthHandler, profilecontrollers.RemoveMutedKeyword)
+	router.Get("/keywords/get", middleware.UserAuthHandler, profilecontrollers.GetMutedKeywords)
+}
+
+func relationshipsRouter(group fiber.Router) {
+	router := group.Group("/relationships") // domain/api/profile/relationships
+
+	router.Get("/get", middleware.UserAuthHandler, profilecontrollers.GetRelationships)
+	router.Get("/mutual-followers/:profileId", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetMutualFollowers)
 }
 
 func searchHistoryRouter(group fiber.Router) {
@@ -46,6 +123,7 @@ func searchHistoryRouter(group fiber.Router) {
 func subscribersRouter(group fiber.Router) {
 	router := group.Group("/subscribers") // domain/api/profile/subscribers
 
+	router.Post("/invite/bulk", middleware.UserAuthHandler, profilecontrollers.BulkInviteToSubscribersList)
 	router.Post("/invite/:profileId", middleware.UserAuthHandler, profilecontrollers.InviteToSubscribersList)
 	router.Delete("/invite/cancel/:profileId", middleware.UserAuthHandler, profilecontrollers.CancelInviteToSubscribersList)
 	router.Put("/invite/accept/:senderId", middleware.UserAuthHandler, profilecontrollers.AcceptInviteToSubscribersList)
@@ -57,13 +135,32 @@ func subscribersRouter(group fiber.Router) {
 	router.Delete("/request/decline/:senderId", middleware.UserAuthHandler, profilecontrollers.DeclineRequestToSubscribe)
 
 	router.Delete("/remove/:profileId", middleware.UserAuthHandler, profilecontrollers.RemoveASubscriber)
+	router.Put("/renew/:profileId", middleware.UserAuthHandler, profilecontrollers.RenewSubscription)
+	router.Put("/extend/:profileId", middleware.UserAuthHandler, profilecontrollers.ExtendSubscription)
 	router.Delete("/unsubscribe/:profileId", middleware.UserAuthHandler, profilecontrollers.UnsubscribeFromUser)
 
 	router.Get("/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetSubscribers)
 	router.Get("/subscriptions/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetSubscriptions)
+	router.Put("/subscriptions/tier/:profileId", middleware.UserAuthHandler, profilecontrollers.ChangeSubscriptionTier)
+	router.Put("/subscriptions/tier/accept/:senderId", middleware.UserAuthHandler, profilecontrollers.AcceptTierChangeRequest)
+	router.Delete("/subscriptions/tier/decline/:senderId", middleware.UserAuthHandler, profilecontrollers.DeclineTierChangeRequest)
+	router.Post("/subscriptions/subscribe/:profileId", middleware.UserAuthHandler, profilecontrollers.SubscribeToTier)
+	router.Put("/subscriptions/renewal/:profileId", middleware.UserAuthHandler, profilecontrollers.SetSubscriptionRenewal)
+
+	router.Post("/tiers/create", middleware.UserAuthHandler, profilecontrollers.CreateTier)
+	router.Get("/tiers/get/:profileId", middleware.UserAuthHandler, profilecontrollers.GetTiers)
+	router.Put("/tiers/:tierId/edit", middleware.UserAuthHandler, profilecontrollers.EditTier)
+	router.Delete("/tiers/:tierId/delete", middleware.UserAuthHandler, profilecontrollers.DeleteTier)
 
 	router.Get("/invites/sent/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetInvitesSent)
 	router.Get("/invites/received/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetInvitesReceived)
 	router.Get("/requests/sent/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetRequestsSent)
 	router.Get("/requests/received/get", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetRequestsReceived)
 }
+
+func suggestionsRouter(group fiber.Router) {
+	router := group.Group("/suggestions") // domain/api/profile/suggestions
+
+	router.Get("", middleware.UserAuthHandler, middleware.PaginationHandler, profilecontrollers.GetSuggestions)
+	router.Post("/dismiss/:profileId", middleware.UserAuthHandler, profilecontrollers.DismissSuggestion)
+}
@@ -19,6 +19,9 @@ func InitRouter(app *fiber.App, hub *ws.Hub) {
 	AuthRouter(api)
 	ProfileRouter(api)
 	PostsRouter(api)
+	PaymentsRouter(api)
+	SearchRouter(api)
+	UploadsRouter(api)
 
 	ws.Use(func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) { // Returns true if the client requested upgrade to the WebSocket protocol
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	searchcontrollers "nerajima.com/NeraJima/controllers/search_controllers"
+	"nerajima.com/NeraJima/middleware"
+)
+
+func SearchRouter(group fiber.Router) {
+	router := group.Group("/search") // domain/api/search
+
+	router.Get("/trending", middleware.UserAuthHandler, searchcontrollers.GetTrendingSearches)
+}
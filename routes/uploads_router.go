@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	uploadcontrollers "nerajima.com/NeraJima/controllers/upload_controllers"
+	"nerajima.com/NeraJima/middleware"
+)
+
+func UploadsRouter(group fiber.Router) {
+	router := group.Group("/uploads", middleware.TusHandler) // domain/api/uploads
+
+	// the paths and methods are fixed by the tus protocol
+	router.Options("/", uploadcontrollers.GetTusOptions)
+	router.Post("/", middleware.UserAuthHandler, uploadcontrollers.CreateUpload)
+	router.Head("/:uploadId", middleware.UserAuthHandler, uploadcontrollers.GetUploadOffset)
+	router.Patch("/:uploadId", middleware.UserAuthHandler, uploadcontrollers.PatchUpload)
+	router.Delete("/:uploadId", middleware.UserAuthHandler, uploadcontrollers.TerminateUpload)
+}
@@ -13,6 +13,10 @@ import (
 	"github.com/gofiber/helmet/v2"
 	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/configs/cache"
+	"nerajima.com/NeraJima/configs/payments"
+	"nerajima.com/NeraJima/configs/storage"
+	"nerajima.com/NeraJima/jobs"
+	"nerajima.com/NeraJima/middleware"
 	"nerajima.com/NeraJima/routes"
 	"nerajima.com/NeraJima/ws"
 )
@@ -24,7 +28,9 @@ func main() {
 
 	// Middleware
 	app.Use(helmet.New())
-	app.Use(cors.New())
+	app.Use(cors.New(cors.Config{
+		ExposeHeaders: "Location,Tus-Resumable,Tus-Version,Tus-Extension,Tus-Max-Size,Upload-Offset,Upload-Length,Upload-Expires", // read by browser tus clients
+	}))
 	app.Use(recover.New())
 	app.Use(requestid.New())
 	if !configs.EnvProdActive() {
@@ -34,6 +40,11 @@ func main() {
 
 	configs.InitDatabase()
 	cache.Initialize()
+	storage.Initialize()
+	storage.Serve(app, middleware.UserAuthHandler, middleware.StorageAccessHandler)
+	payments.Initialize()
+
+	jobs.Start()
 
 	hub := ws.NewHub()
 	go hub.Run()
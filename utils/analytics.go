@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 731
+	analyticsDateLayout  = "2006-01-02"
+)
+
+// Range of days an analytics time series covers, grouped by Interval ("day", "week" or "month"). From and To are inclusive and in UTC.
+type AnalyticsRange struct {
+	Interval string
+	From     time.Time
+	To       time.Time
+}
+
+/*
+Reads an analytics range from the values of the "interval", "from" and "to" query parameters. Dates are formatted as 2006-01-02.
+The interval defaults to "day" and the range to the last 30 days. The message is empty if the range is valid.
+*/
+func ParseAnalyticsRange(interval, from, to string) (AnalyticsRange, string) {
+	r := AnalyticsRange{Interval: interval}
+	if r.Interval == "" {
+		r.Interval = "day"
+	}
+	if r.Interval != "day" && r.Interval != "week" && r.Interval != "month" {
+		return r, "Interval must be day, week or month."
+	}
+
+	r.To = time.Now().UTC().Truncate(time.Hour * 24)
+	if to != "" {
+		t, err := time.Parse(analyticsDateLayout, to)
+		if err != nil {
+			return r, "Invalid end date."
+		}
+		r.To = t
+	}
+
+	r.From = r.To.AddDate(0, 0, -(defaultAnalyticsDays - 1))
+	if from != "" {
+		t, err := time.Parse(analyticsDateLayout, from)
+		if err != nil {
+			return r, "Invalid start date."
+		}
+		r.From = t
+	}
+
+	if r.From.After(r.To) {
+		return r, "Start date must be before end date."
+	}
+	if r.To.Sub(r.From) >= time.Hour*24*maxAnalyticsDays {
+		return r, fmt.Sprintf("Ranges can cover at most %d days.", maxAnalyticsDays)
+	}
+	return r, ""
+}
+
+// Returns the start of the period day falls in. Weeks start on Monday, the same as date_trunc in postgres.
+func (r AnalyticsRange) PeriodStart(day time.Time) time.Time {
+	switch r.Interval {
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// Returns the start of every period in the range, oldest first
+func (r AnalyticsRange) Periods() []time.Time {
+	periods := []time.Time{}
+	for period := r.PeriodStart(r.From); !period.After(r.To); {
+		periods = append(periods, period)
+		switch r.Interval {
+		case "week":
+			period = period.AddDate(0, 0, 7)
+		case "month":
+			period = period.AddDate(0, 1, 0)
+		default:
+			period = period.AddDate(0, 0, 1)
+		}
+	}
+	return periods
+}
@@ -0,0 +1,8 @@
+package utils
+
+// SQL condition that is true when the viewer can see a row of the posts table with respect to its audience: the post isn't limited to an audience, the viewer owns it, or the viewer is a member of its audience.
+//
+// The condition has two placeholders which both take the viewer's profile id.
+func InAudienceCondition() string {
+	return "(posts.audience_id IS NULL OR posts.profile_id = ? OR EXISTS (SELECT 1 FROM audience_members WHERE audience_members.audience_id = posts.audience_id AND audience_members.profile_id = ?))"
+}
@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/cache"
+	"nerajima.com/NeraJima/configs/payments"
+	"nerajima.com/NeraJima/models"
+)
+
+const (
+	platformFeePercent = 10
+	payoutLockExpiry   = time.Minute * 5
+)
+
+var (
+	ErrAlreadyRefunded  = errors.New("charge has already been refunded")
+	ErrPayoutInProgress = errors.New("a payout is already in progress")
+)
+
+// Returns the part of amount the platform keeps
+func platformFee(amount int64) int64 {
+	return amount * platformFeePercent / 100
+}
+
+/*
+Charges the subscriber for one billing period of tier starting at from. On success the charge is written to the ledger and the subscription
+to the owner of the tier is accepted, moved to tier and runs until the end of the period. Returns payments.ErrDeclined if the payment was refused.
+
+Charges with the same idempotencyKey are only made once, so a renewal that is retried can't charge twice for the same period. A retry of a charge
+that has been refunded in full since returns ErrAlreadyRefunded and doesn't give the subscription back.
+*/
+func ChargeSubscription(subscriberId string, tier models.SubscriptionTier, from time.Time, idempotencyKey string) error {
+	payCtx, payCancel := payments.NewPaymentContext()
+	defer payCancel()
+	chargeRef, err := payments.Charge(payCtx, subscriberId, tier.Price, tier.Currency, idempotencyKey)
+	if err != nil {
+		return err
+	}
+
+	fee := platformFee(tier.Price)
+	periodEnd := tier.PeriodEnd(from)
+	charge := models.LedgerTransaction{
+		Kind:        models.LedgerKindCharge,
+		ProviderRef: chargeRef,
+		PayerId:     &subscriberId,
+		CreatorId:   tier.ProfileId,
+		TierId:      &tier.Id,
+		PeriodEnd:   &periodEnd,
+		Amount:      tier.Price,
+		Currency:    tier.Currency,
+		Entries: []models.LedgerEntry{
+			{Account: models.LedgerAccountProvider, Amount: tier.Price, Currency: tier.Currency},
+			{Account: models.LedgerAccountCreator, ProfileId: &tier.ProfileId, Amount: -(tier.Price - fee), Currency: tier.Currency},
+			{Account: models.LedgerAccountPlatform, Amount: -fee, Currency: tier.Currency},
+		},
+	}
+
+	wasRecorded := false
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	err = configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		// the same charge can come back for a retried idempotency key, it only needs to be recorded once
+		var recorded models.LedgerTransaction
+		if err := tx.Model(&models.LedgerTransaction{}).Find(&recorded, "provider_ref = ?", chargeRef).Error; err != nil {
+			return err
+		}
+		if recorded.Id == "" {
+			if err := tx.Create(&charge).Error; err != nil {
+				return err
+			}
+		} else {
+			wasRecorded = true
+			refunded, err := refundedAmount(tx, recorded.Id)
+			if err != nil {
+				return err
+			}
+			if refunded >= recorded.Amount {
+				return ErrAlreadyRefunded
+			}
+		}
+
+		subscriberObj := models.ProfileSubscriber{
+			ProfileId:    tier.ProfileId,
+			SubscriberId: subscriberId,
+		}
+		subscriberObjAttributes := models.ProfileSubscriber{
+			IsInvite:  false,
+			IsRequest: true,
+		}
+		return tx.Table("profile_subscribers").Where(subscriberObj).Attrs(subscriberObjAttributes).Assign(map[string]interface{}{
+			"is_accepted":          true,
+			"tier_id":              &tier.Id,
+			"current_period_end":   &periodEnd,
+			"cancel_at_period_end": false,
+			"access_duration":      nil, // a paid subscription lasts for as long as it is paid for
+			"expires_at":           nil,
+		}).FirstOrCreate(&subscriberObj).Error
+	})
+	if err != nil {
+		// the customer paid for something they didn't get, give the money back. a charge recorded by an earlier attempt is left to that attempt
+		if !wasRecorded {
+			payCtx2, payCancel2 := payments.NewPaymentContext()
+			defer payCancel2()
+			if _, refundErr := payments.Refund(payCtx2, chargeRef, tier.Price); refundErr != nil {
+				log.Printf("billing: could not refund charge %s after failing to record it: %v", chargeRef, refundErr)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Refunds what is left of a charge through the payment provider and records it
+func RefundCharge(charge models.LedgerTransaction) error {
+	if charge.Kind != models.LedgerKindCharge {
+		return errors.New("only charges can be refunded")
+	}
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	refunded, err := refundedAmount(configs.Database.WithContext(dbCtx), charge.Id)
+	if err != nil {
+		return err
+	}
+	if refunded >= charge.Amount {
+		return ErrAlreadyRefunded
+	}
+
+	payCtx, payCancel := payments.NewPaymentContext()
+	defer payCancel()
+	refundRef, err := payments.Refund(payCtx, charge.ProviderRef, charge.Amount-refunded)
+	if err != nil {
+		return err
+	}
+
+	return RecordRefund(charge, refundRef, charge.Amount-refunded)
+}
+
+/*
+Writes the reversal of amount of a charge to the ledger. This is used both for refunds we make and for refunds and disputes the provider tells us about.
+Recording the same refund twice does nothing, and a refund is capped at what is left of the charge.
+
+Once a charge is refunded in full the subscription ends if the charge paid for its current period. Partial refunds and refunds of earlier periods
+leave the subscription as it is. Charges recorded before the period was kept on them never end a subscription.
+*/
+func RecordRefund(charge models.LedgerTransaction, refundRef string, amount int64) error {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	return configs.Database.WithContext(dbCtx).Transaction(func(tx *gorm.DB) error {
+		// lock the charge so two refunds of it are recorded one after the other
+		var locked models.LedgerTransaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.LedgerTransaction{}).Select("id").Find(&locked, "id = ?", charge.Id).Error; err != nil {
+			return err
+		}
+		if locked.Id == "" {
+			return errors.New("charge not found")
+		}
+
+		var numRefunds int64
+		if err := tx.Model(&models.LedgerTransaction{}).Where("provider_ref = ?", refundRef).Count(&numRefunds).Error; err != nil {
+			return err
+		}
+		if numRefunds > 0 {
+			return nil
+		}
+
+		refunded, err := refundedAmount(tx, charge.Id)
+		if err != nil {
+			return err
+		}
+		if amount > charge.Amount-refunded {
+			amount = charge.Amount - refunded
+		}
+		if amount <= 0 {
+			return nil
+		}
+
+		fee := platformFee(refunded+amount) - platformFee(refunded) // the fees given back add up to the fee of the charge once it is refunded in full
+		refund := models.LedgerTransaction{
+			Kind:        models.LedgerKindRefund,
+			ProviderRef: refundRef,
+			PayerId:     charge.PayerId,
+			CreatorId:   charge.CreatorId,
+			TierId:      charge.TierId,
+			RefundOfId:  &charge.Id,
+			Amount:      amount,
+			Currency:    charge.Currency,
+			Entries: []models.LedgerEntry{
+				{Account: models.LedgerAccountProvider, Amount: -amount, Currency: charge.Currency},
+				{Account: models.LedgerAccountCreator, ProfileId: &charge.CreatorId, Amount: amount - fee, Currency: charge.Currency},
+				{Account: models.LedgerAccountPlatform, Amount: fee, Currency: charge.Currency},
+			},
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+
+		if refunded+amount < charge.Amount || charge.PayerId == nil || charge.PeriodEnd == nil {
+			return nil
+		}
+		var subscriberObj models.ProfileSubscriber
+		return tx.Table("profile_subscribers").Delete(&subscriberObj, "profile_id = ? AND subscriber_id = ? AND is_accepted = ? AND current_period_end <= ?", charge.CreatorId, *charge.PayerId, true, *charge.PeriodEnd).Error
+	})
+}
+
+// Returns how much of the charge has been refunded so far
+func refundedAmount(tx *gorm.DB, chargeId string) (int64, error) {
+	var refunded int64
+	if err := tx.Model(&models.LedgerTransaction{}).Select("COALESCE(SUM(amount), 0)").Where("refund_of_id = ?", chargeId).Scan(&refunded).Error; err != nil {
+		return 0, err
+	}
+	return refunded, nil
+}
+
+// Returns what the platform owes the creator in every currency it has been paid in. Credits are negative so the balance is the negated sum.
+func CreatorBalances(creatorId string) (map[string]int64, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var rows []struct {
+		Currency string
+		Balance  int64
+	}
+	if err := configs.Database.WithContext(dbCtx).Model(&models.LedgerEntry{}).
+		Select("currency, -SUM(amount) AS balance").
+		Where("account = ? AND profile_id = ?", models.LedgerAccountCreator, creatorId).
+		Group("currency").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		balances[row.Currency] = row.Balance
+	}
+	return balances, nil
+}
+
+// Pays out the creator's whole positive balance in every currency. Returns the payouts that were made.
+//
+// Only one payout per creator runs at a time, otherwise two requests could both read the same balance and pay it twice.
+func PayoutCreator(creatorId string) ([]models.LedgerTransaction, error) {
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	acquired, err := cache.SetIfNotExists(cacheCtx, cache.PayoutLockKey(creatorId), time.Now(), payoutLockExpiry)
+	cacheCancel()
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrPayoutInProgress
+	}
+	defer func() {
+		cacheCtx, cacheCancel := cache.NewCacheContext()
+		defer cacheCancel()
+		cache.Delete(cacheCtx, cache.PayoutLockKey(creatorId))
+	}()
+
+	balances, err := CreatorBalances(creatorId)
+	if err != nil {
+		return nil, err
+	}
+
+	payouts := []models.LedgerTransaction{}
+	for currency, balance := range balances {
+		if balance <= 0 {
+			continue
+		}
+
+		payCtx, payCancel := payments.NewPaymentContext()
+		payoutRef, err := payments.Payout(payCtx, creatorId, balance, currency)
+		payCancel()
+		if err != nil {
+			return payouts, err
+		}
+
+		payout := models.LedgerTransaction{
+			Kind:        models.LedgerKindPayout,
+			ProviderRef: payoutRef,
+			CreatorId:   creatorId,
+			Amount:      balance,
+			Currency:    currency,
+			Entries: []models.LedgerEntry{
+				{Account: models.LedgerAccountCreator, ProfileId: &creatorId, Amount: balance, Currency: currency},
+				{Account: models.LedgerAccountProvider, Amount: -balance, Currency: currency},
+			},
+		}
+		dbCtx, dbCancel := configs.NewQueryContext()
+		err = configs.Database.WithContext(dbCtx).Create(&payout).Error
+		dbCancel()
+		if err != nil {
+			log.Printf("billing: payout %s to %s was sent but could not be recorded: %v", payoutRef, creatorId, err)
+			return payouts, err
+		}
+		payouts = append(payouts, payout)
+	}
+
+	return payouts, nil
+}
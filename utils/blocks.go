@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"nerajima.com/NeraJima/configs"
+)
+
+// Returns true if either profile has blocked the other
+func IsBlockedBetween(profileId, otherProfileId string) (bool, error) {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var numBlocks int64
+	if err := configs.Database.WithContext(dbCtx).Table("profile_blocks").Where("(profile_id = ? AND blocked_id = ?) OR (profile_id = ? AND blocked_id = ?)", profileId, otherProfileId, otherProfileId, profileId).Count(&numBlocks).Error; err != nil {
+		return false, err
+	}
+	return numBlocks > 0, nil
+}
+
+// SQL condition that is true when neither the viewer nor the profile referenced by column has blocked the other.
+//
+// The condition has two placeholders which both take the viewer's profile id.
+func NotBlockedCondition(column string) string {
+	return "NOT EXISTS (SELECT 1 FROM profile_blocks WHERE (profile_blocks.profile_id = ? AND profile_blocks.blocked_id = " + column + ") OR (profile_blocks.profile_id = " + column + " AND profile_blocks.blocked_id = ?))"
+}
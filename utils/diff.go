@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"unicode"
+
+	"nerajima.com/NeraJima/models"
+)
+
+// Splits text into runs of whitespace and runs of everything else, so a diff never cuts a word in half
+func diffTokens(text string) []string {
+	tokens := []string{}
+	runes := []rune(text)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || unicode.IsSpace(runes[i]) != unicode.IsSpace(runes[start]) {
+			tokens = append(tokens, string(runes[start:i]))
+			start = i
+		}
+	}
+	return tokens
+}
+
+/*
+Returns the word level changes that turn oldText into newText, found from the longest common subsequence of their words.
+Neighbouring changes of the same kind are merged and deletions come before insertions where the two meet.
+
+Texts are at most a few hundred words long, so the quadratic table is small.
+*/
+func DiffWords(oldText, newText string) []models.DiffChange {
+	oldTokens, newTokens := diffTokens(oldText), diffTokens(newText)
+
+	// common[i][j] is the length of the longest common subsequence of oldTokens[i:] and newTokens[j:]
+	common := make([][]int, len(oldTokens)+1)
+	for i := range common {
+		common[i] = make([]int, len(newTokens)+1)
+	}
+	for i := len(oldTokens) - 1; i >= 0; i-- {
+		for j := len(newTokens) - 1; j >= 0; j-- {
+			if oldTokens[i] == newTokens[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	changes := []models.DiffChange{}
+	add := func(op, text string) {
+		if len(changes) > 0 && changes[len(changes)-1].Op == op {
+			changes[len(changes)-1].Text += text
+			return
+		}
+		changes = append(changes, models.DiffChange{Op: op, Text: text})
+	}
+	i, j := 0, 0
+	for i < len(oldTokens) || j < len(newTokens) {
+		switch {
+		case i < len(oldTokens) && j < len(newTokens) && oldTokens[i] == newTokens[j]:
+			add(models.DiffOpEqual, oldTokens[i])
+			i++
+			j++
+		case i < len(oldTokens) && (j == len(newTokens) || common[i+1][j] >= common[i][j+1]):
+			add(models.DiffOpDelete, oldTokens[i])
+			i++
+		default:
+			add(models.DiffOpInsert, newTokens[j])
+			j++
+		}
+	}
+	return changes
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	EntityTypeHashtag = "hashtag"
+	EntityTypeMention = "mention"
+
+	maxHashtagLength  = 50
+	maxUsernameLength = 30
+)
+
+// A hashtag or mention found in a piece of text. Start and End are offsets in unicode code points and include the leading # or @, End is exclusive.
+//
+// Value is the hashtag in lower case without the # or the mentioned username without the @.
+type TextEntity struct {
+	Type  string
+	Start int
+	End   int
+	Value string
+}
+
+/*
+Finds the hashtags and mentions in text.
+
+A # or @ only starts an entity at the start of the text or after a character that isn't a letter, number or underscore, so e-mail addresses
+and things like "C#" aren't picked up. A hashtag runs for as long as there are letters, numbers and underscores and needs at least one letter.
+Usernames can contain any character but whitespace, so a mention runs until whitespace or the next # or @, without trailing punctuation.
+*/
+func ParseEntities(text string) []TextEntity {
+	runes := []rune(text)
+	entities := []TextEntity{}
+	for i := 0; i < len(runes); i++ {
+		if (runes[i] != '#' && runes[i] != '@') || (i > 0 && isWordRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		entity := TextEntity{Start: i}
+		if runes[i] == '#' {
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+			tag, ok := NormalizeHashtag(string(runes[i+1 : end]))
+			if !ok {
+				continue
+			}
+			entity.Type, entity.Value = EntityTypeHashtag, tag
+		} else {
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '#' && runes[end] != '@' {
+				end++
+			}
+			for end > i+1 && strings.ContainsRune(".,!?:;'\")]}", runes[end-1]) {
+				end--
+			}
+			if end == i+1 || end-i-1 > maxUsernameLength {
+				continue
+			}
+			entity.Type, entity.Value = EntityTypeMention, strings.ToLower(string(runes[i+1:end]))
+		}
+
+		entity.End = end
+		entities = append(entities, entity)
+		i = end - 1
+	}
+	return entities
+}
+
+// Returns the hashtag in the form it is stored in, lower case and without the leading #. Returns false if it isn't a valid hashtag.
+func NormalizeHashtag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	runes := []rune(tag)
+	if len(runes) == 0 || len(runes) > maxHashtagLength {
+		return "", false
+	}
+
+	hasLetter := false
+	for _, r := range runes {
+		if !isWordRune(r) {
+			return "", false
+		}
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		}
+	}
+	return tag, hasLetter
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+}
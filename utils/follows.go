@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+	"nerajima.com/NeraJima/models"
+)
+
+// Deletes the follow of profileId by followerId, pending or not. If the follow had been accepted, an unfollow is recorded for analytics.
+func DeleteFollow(tx *gorm.DB, profileId, followerId string) error {
+	var followerObj models.ProfileFollower
+	result := tx.Table("profile_followers").Delete(&followerObj, "profile_id = ? AND follower_id = ? AND is_pending = ?", profileId, followerId, false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		unfollowObj := models.ProfileUnfollow{
+			ProfileId:  profileId,
+			FollowerId: followerId,
+		}
+		if err := tx.Create(&unfollowObj).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Table("profile_followers").Delete(&followerObj, "profile_id = ? AND follower_id = ?", profileId, followerId).Error
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"nerajima.com/NeraJima/models"
+)
+
+/*
+   Renditions are smaller copies of an uploaded image that clients load in place of the original, e.g. the thumbnail in a feed.
+   Each is scaled down to fit a square of its size, images that are already smaller keep their size. Only JPEG and PNG images
+   can be decoded with the standard library, so WebP images don't get renditions and clients use the original.
+   JPEG images are turned upright by their EXIF orientation before they are scaled, since the renditions don't keep the EXIF data.
+
+   Opaque images are encoded as JPEG, images with transparency as PNG so the transparency is kept.
+*/
+
+const (
+	MaxRenditionPixels = 50_000_000 // images with more pixels than this aren't decoded, a 50 megapixel image takes 200MB of memory
+	renditionQuality   = 82
+	blurhashSize       = 32 // the blurhash is computed from a copy this small, it only describes the rough colors anyway
+)
+
+var RenditionSizes = []struct {
+	Name    string
+	MaxSide int
+}{
+	{models.RenditionThumbnail, 320},
+	{models.RenditionMedium, 1024},
+	{models.RenditionFull, 2048},
+}
+
+type Rendition struct {
+	Name        string // one of the Rendition constants in models
+	Data        []byte
+	Extension   string
+	ContentType string
+}
+
+// Decodes the image in r, a JPEG or PNG file, and returns its renditions in the order of RenditionSizes along with its blurhash
+func RenderImage(r io.Reader, format string) ([]Rendition, string, error) {
+	var decoded image.Image
+	var err error
+	head := &headBuffer{max: maxExifHeader}
+	orientation := orientationNormal
+	switch format {
+	case models.MediaFormatJPEG:
+		decoded, err = jpeg.Decode(io.TeeReader(r, head))
+		orientation = jpegOrientation(head.data)
+	case models.MediaFormatPNG:
+		decoded, err = png.Decode(r)
+	default:
+		return nil, "", ErrUnsupportedMedia
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// everything is scaled on premultiplied RGBA so transparent pixels don't bleed their color into their neighbours
+	bounds := decoded.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Rect, decoded, bounds.Min, draw.Src)
+	decoded = nil
+	img = orient(img, orientation)
+	isOpaque := img.Opaque()
+
+	// every rendition is scaled from the next bigger one, which is much faster than scaling each from the original
+	renditions := make([]Rendition, len(RenditionSizes))
+	scaled := img
+	for i := len(RenditionSizes) - 1; i >= 0; i-- {
+		scaled = shrinkToFit(scaled, RenditionSizes[i].MaxSide)
+		rendition, err := encodeRendition(scaled, isOpaque)
+		if err != nil {
+			return nil, "", err
+		}
+		rendition.Name = RenditionSizes[i].Name
+		renditions[i] = rendition
+	}
+	return renditions, Blurhash(shrinkToFit(scaled, blurhashSize), 4, 3), nil
+}
+
+func encodeRendition(img *image.RGBA, isOpaque bool) (Rendition, error) {
+	var buf bytes.Buffer
+	if isOpaque {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: renditionQuality}); err != nil {
+			return Rendition{}, err
+		}
+		return Rendition{Data: buf.Bytes(), Extension: ".jpg", ContentType: "image/jpeg"}, nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return Rendition{}, err
+	}
+	return Rendition{Data: buf.Bytes(), Extension: ".png", ContentType: "image/png"}, nil
+}
+
+// Scales img down so its longer side is at most maxSide, keeping its aspect ratio. Returns img itself if it fits already.
+func shrinkToFit(img *image.RGBA, maxSide int) *image.RGBA {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+	if width >= height {
+		width, height = maxSide, height*maxSide/width
+	} else {
+		width, height = width*maxSide/height, maxSide
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return shrink(img, width, height)
+}
+
+// Scales img down to width by height with a box filter: each pixel is the average of the pixels it covers in img
+func shrink(img *image.RGBA, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	srcWidth, srcHeight := img.Rect.Dx(), img.Rect.Dy()
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, (y+1)*srcHeight/height
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, (x+1)*srcWidth/width
+			if x1 == x0 {
+				x1++
+			}
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := img.Pix[img.PixOffset(img.Rect.Min.X+x0, img.Rect.Min.Y+sy):]
+				for i := 0; i < (x1-x0)*4; i++ {
+					sum[i%4] += int(row[i])
+				}
+			}
+			count := (x1 - x0) * (y1 - y0)
+			offset := dst.PixOffset(x, y)
+			for c := range sum {
+				dst.Pix[offset+c] = uint8((sum[c] + count/2) / count)
+			}
+		}
+	}
+	return dst
+}
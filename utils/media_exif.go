@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+/*
+   Cameras store JPEG images the way the sensor read them and record how to turn them upright in the orientation tag of their EXIF data:
+   https://www.cipa.jp/std/documents/e/DC-008-2012_E.pdf. The tag is in an APP1 segment before the image data, so it is read from the
+   start of the file that was kept while the image was decoded.
+*/
+
+const (
+	orientationNormal = 1
+	maxExifHeader     = 1 << 17 // bytes kept from the start of a JPEG file, the EXIF segment is at most 64KB and comes right after the start marker
+)
+
+// Keeps the first max bytes written to it and drops the rest, so the start of a file can be kept while the whole file streams through
+type headBuffer struct {
+	data []byte
+	max  int
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.data); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		b.data = append(b.data, p[:room]...)
+	}
+	return len(p), nil
+}
+
+// Returns the EXIF orientation of the JPEG file that starts with head, from 1 to 8. Files without a valid one are upright.
+func jpegOrientation(head []byte) int {
+	if len(head) < 2 || head[0] != 0xFF || head[1] != 0xD8 {
+		return orientationNormal
+	}
+	for i := 2; i+4 <= len(head); {
+		if head[i] != 0xFF {
+			return orientationNormal
+		}
+		marker := head[i+1]
+		if marker == 0xFF { // fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA || (marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC) {
+			return orientationNormal // the image data starts, EXIF data comes before it
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(head[i+2:i+4]))
+		if end > len(head) || end < i+4 {
+			return orientationNormal
+		}
+		if segment := head[i+4 : end]; marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		i = end
+	}
+	return orientationNormal
+}
+
+// Reads the orientation tag from the first directory of the TIFF structure that holds the EXIF data
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return orientationNormal
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return orientationNormal
+	}
+
+	directory := int64(order.Uint32(tiff[4:8]))
+	if directory < 8 || directory+2 > int64(len(tiff)) {
+		return orientationNormal
+	}
+	numEntries := int(order.Uint16(tiff[directory:]))
+	for i := 0; i < numEntries; i++ {
+		entry := int(directory) + 2 + i*12 // 2 bytes of tag, 2 of type, 4 of count and 4 of value
+		if entry+12 > len(tiff) {
+			return orientationNormal
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return orientationNormal
+			}
+			return orientation
+		}
+	}
+	return orientationNormal
+}
+
+// Orientations 5 to 8 turn the image by a quarter, so its width and height swap
+func swapsSides(orientation int) bool {
+	return orientation >= 5
+}
+
+// Returns img turned upright according to its EXIF orientation. Returns img itself if it already is.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= orientationNormal || orientation > 8 {
+		return img
+	}
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	dstWidth, dstHeight := width, height
+	if swapsSides(orientation) {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			// the pixel of img that ends up at x, y
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = width-1-x, y
+			case 3: // turned half way
+				sx, sy = width-1-x, height-1-y
+			case 4: // upside down and mirrored
+				sx, sy = x, height-1-y
+			case 5: // mirrored along the diagonal
+				sx, sy = y, x
+			case 6: // needs a quarter turn clockwise
+				sx, sy = y, height-1-x
+			case 7: // mirrored along the other diagonal
+				sx, sy = width-1-y, height-1-x
+			case 8: // needs a quarter turn counterclockwise
+				sx, sy = width-1-y, x
+			}
+			src := img.PixOffset(img.Rect.Min.X+sx, img.Rect.Min.Y+sy)
+			copy(dst.Pix[dst.PixOffset(x, y):], img.Pix[src:src+4])
+		}
+	}
+	return dst
+}
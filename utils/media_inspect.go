@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"nerajima.com/NeraJima/models"
+)
+
+/*
+   Media files are inspected while they stream to storage, so the parsers only ever read forward and never hold a whole file in memory.
+   JPEG and PNG headers are read with the standard library, WebP, MP4 and MP3 with the small parsers in this file and the ones next to it.
+   The width and height of a JPEG image are those of the image turned upright, see utils/media_exif.go.
+*/
+
+var (
+	ErrUnsupportedMedia = errors.New("unsupported media format")
+	errMalformedMedia   = errors.New("malformed media file")
+)
+
+// Reads the start of r to find out the format of the file and its metadata. size is the size of the whole file in bytes.
+// Returns ErrUnsupportedMedia if the file isn't a JPEG, PNG, WebP, MP4 or MP3 file. r may not be read to the end.
+func InspectMedia(r io.Reader, size int64) (models.MediaMetadata, error) {
+	reader := bufio.NewReader(r)
+	head, err := reader.Peek(12)
+	if err != nil && len(head) < 4 {
+		return models.MediaMetadata{}, ErrUnsupportedMedia
+	}
+
+	var metadata models.MediaMetadata
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		metadata, err = inspectJPEG(reader)
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		metadata, err = inspectImage(reader, models.MediaFormatPNG, png.DecodeConfig)
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		metadata, err = inspectWebP(reader)
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		metadata, err = inspectMP4(reader)
+	case bytes.HasPrefix(head, []byte("ID3")) || isMP3FrameSync(head):
+		metadata, err = inspectMP3(reader, size)
+	default:
+		return models.MediaMetadata{}, ErrUnsupportedMedia
+	}
+	if err != nil {
+		return models.MediaMetadata{}, err
+	}
+	metadata.Size = size
+	return metadata, nil
+}
+
+func inspectImage(r io.Reader, format string, decodeConfig func(io.Reader) (image.Config, error)) (models.MediaMetadata, error) {
+	config, err := decodeConfig(r)
+	if err != nil {
+		return models.MediaMetadata{}, errMalformedMedia
+	}
+	return models.MediaMetadata{Format: format, Width: config.Width, Height: config.Height}, nil
+}
+
+// The orientation is read from the start of the file kept while the header is decoded
+func inspectJPEG(r io.Reader) (models.MediaMetadata, error) {
+	head := &headBuffer{max: maxExifHeader}
+	metadata, err := inspectImage(io.TeeReader(r, head), models.MediaFormatJPEG, jpeg.DecodeConfig)
+	if err != nil {
+		return models.MediaMetadata{}, err
+	}
+	if swapsSides(jpegOrientation(head.data)) {
+		metadata.Width, metadata.Height = metadata.Height, metadata.Width
+	}
+	return metadata, nil
+}
+
+// The dimensions of a WebP image are in the first chunk after the RIFF header: https://developers.google.com/speed/webp/docs/riff_container
+func inspectWebP(r io.Reader) (models.MediaMetadata, error) {
+	header := make([]byte, 12+8+10) // RIFF header, chunk header, start of the chunk
+	if _, err := io.ReadFull(r, header); err != nil {
+		return models.MediaMetadata{}, errMalformedMedia
+	}
+	chunkType, chunk := string(header[12:16]), header[20:]
+
+	metadata := models.MediaMetadata{Format: models.MediaFormatWebP}
+	switch chunkType {
+	case "VP8 ": // lossy: 3 byte frame tag, 3 byte start code, then 14 bit width and height
+		if !bytes.Equal(chunk[3:6], []byte{0x9D, 0x01, 0x2A}) {
+			return models.MediaMetadata{}, errMalformedMedia
+		}
+		metadata.Width = int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3FFF)
+		metadata.Height = int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3FFF)
+	case "VP8L": // lossless: 1 byte signature, then 14 bits of width - 1 and 14 bits of height - 1
+		if chunk[0] != 0x2F {
+			return models.MediaMetadata{}, errMalformedMedia
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		metadata.Width = int(bits&0x3FFF) + 1
+		metadata.Height = int((bits>>14)&0x3FFF) + 1
+	case "VP8X": // extended: 4 bytes of flags, then 24 bits of canvas width - 1 and 24 bits of canvas height - 1
+		metadata.Width = int(uint32(chunk[4])|uint32(chunk[5])<<8|uint32(chunk[6])<<16) + 1
+		metadata.Height = int(uint32(chunk[7])|uint32(chunk[8])<<8|uint32(chunk[9])<<16) + 1
+	default:
+		return models.MediaMetadata{}, errMalformedMedia
+	}
+	return metadata, nil
+}
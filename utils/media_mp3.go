@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"nerajima.com/NeraJima/models"
+)
+
+/*
+   An MP3 file is a sequence of frames, each starting with a 4 byte header: http://www.mp3-tech.org/programmer/frame_header.html
+   It may start with an ID3v2 tag, which is skipped. The duration is read from the Xing, Info or VBRI header in the first frame if there is one,
+   otherwise the file is taken to have a constant bitrate and the duration is worked out from its size.
+*/
+
+const maxMP3SyncScan = 64 << 10 // how far past the ID3 tag to look for the first frame
+
+var (
+	mp3Bitrates = map[bool][]int{ // kbps by bitrate index, for MPEG 1 and for MPEG 2 and 2.5
+		true:  {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		false: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	mp3SampleRates = map[byte][]int{ // Hz by sample rate index, for each version
+		0x03: {44100, 48000, 32000}, // MPEG 1
+		0x02: {22050, 24000, 16000}, // MPEG 2
+		0x00: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+// Reports whether b starts with the header of a valid MPEG Layer III frame
+func isMP3FrameSync(b []byte) bool {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return false
+	}
+	version, layer := (b[1]>>3)&0x03, (b[1]>>1)&0x03
+	bitrateIndex, sampleRateIndex := b[2]>>4, (b[2]>>2)&0x03
+	return version != 0x01 && layer == 0x01 && bitrateIndex != 0 && bitrateIndex != 0x0F && sampleRateIndex != 0x03
+}
+
+func inspectMP3(r io.Reader, size int64) (models.MediaMetadata, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return models.MediaMetadata{}, errMalformedMedia
+	}
+
+	var audioStart int64
+	var buf []byte
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		// the tag size is 4 bytes of 7 bits each and doesn't include the header, or the footer if the flags say there is one
+		tagSize := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+		if header[5]&0x10 != 0 {
+			tagSize += 10
+		}
+		if _, err := io.CopyN(io.Discard, r, tagSize); err != nil {
+			return models.MediaMetadata{}, errMalformedMedia
+		}
+		audioStart = 10 + tagSize
+	} else {
+		buf = header
+	}
+
+	rest, err := io.ReadAll(io.LimitReader(r, maxMP3SyncScan))
+	if err != nil {
+		return models.MediaMetadata{}, errMalformedMedia
+	}
+	buf = append(buf, rest...)
+
+	frameStart := -1
+	for i := 0; i+4 <= len(buf); i++ {
+		if isMP3FrameSync(buf[i:]) {
+			frameStart = i
+			break
+		}
+	}
+	if frameStart < 0 {
+		return models.MediaMetadata{}, errMalformedMedia
+	}
+	frame := buf[frameStart:]
+	audioStart += int64(frameStart)
+
+	version := (frame[1] >> 3) & 0x03
+	isMPEG1, isMono := version == 0x03, frame[3]>>6 == 0x03
+	bitrate := mp3Bitrates[isMPEG1][frame[2]>>4]
+	sampleRate := mp3SampleRates[version][(frame[2]>>2)&0x03]
+	samplesPerFrame := 576
+	if isMPEG1 {
+		samplesPerFrame = 1152
+	}
+
+	metadata := models.MediaMetadata{Format: models.MediaFormatMP3, AudioCodec: "mp3"}
+	if frames := mp3FrameCount(frame, isMPEG1, isMono); frames > 0 {
+		metadata.Duration = float64(frames) * float64(samplesPerFrame) / float64(sampleRate)
+	} else if size > audioStart {
+		metadata.Duration = float64(size-audioStart) * 8 / float64(bitrate*1000)
+	}
+	return metadata, nil
+}
+
+// Returns the number of frames from the Xing, Info or VBRI header in the first frame, 0 if there isn't one
+func mp3FrameCount(frame []byte, isMPEG1, isMono bool) uint32 {
+	// Xing and Info headers come right after the side information, whose size depends on the version and channels
+	sideInfoSize := 32
+	switch {
+	case isMPEG1 && isMono:
+		sideInfoSize = 17
+	case !isMPEG1 && isMono:
+		sideInfoSize = 9
+	case !isMPEG1:
+		sideInfoSize = 17
+	}
+	if len(frame) < 4+32+18 { // long enough for either header
+		return 0
+	}
+	if xing := frame[4+sideInfoSize:]; len(xing) >= 12 && (bytes.HasPrefix(xing, []byte("Xing")) || bytes.HasPrefix(xing, []byte("Info"))) {
+		if binary.BigEndian.Uint32(xing[4:8])&0x01 == 0 { // the frame count is optional
+			return 0
+		}
+		return binary.BigEndian.Uint32(xing[8:12])
+	}
+
+	// VBRI headers are always 32 bytes after the frame header
+	if vbri := frame[4+32:]; bytes.HasPrefix(vbri, []byte("VBRI")) {
+		return binary.BigEndian.Uint32(vbri[14:18])
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"nerajima.com/NeraJima/models"
+)
+
+/*
+   An MP4 file is a sequence of boxes, each starting with its size and a four letter type: https://developer.apple.com/documentation/quicktime-file-format
+   Everything needed is in the moov box, which can come before or after the media data, so the boxes before it are skipped without being kept.
+
+      moov
+         mvhd                      duration of the movie
+         trak                      one per track
+            mdia
+               hdlr                whether the track is video (vide) or sound (soun)
+               minf / stbl / stsd  the codec of the track, and its width and height for video
+*/
+
+const maxMP4MetadataSize = 64 << 20 // moov boxes bigger than this are rejected rather than read into memory
+
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"Opus": "opus",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"alac": "alac",
+	"fLaC": "flac",
+}
+
+func inspectMP4(r io.Reader) (models.MediaMetadata, error) {
+	for {
+		boxType, bodySize, err := readMP4BoxHeader(r)
+		if err != nil {
+			return models.MediaMetadata{}, errMalformedMedia // includes reaching the end without a moov box
+		}
+		if boxType == "moov" {
+			if bodySize < 0 || bodySize > maxMP4MetadataSize {
+				return models.MediaMetadata{}, errMalformedMedia
+			}
+			moov := make([]byte, bodySize)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return models.MediaMetadata{}, errMalformedMedia
+			}
+			return parseMP4Moov(moov)
+		}
+		if bodySize < 0 { // the box runs to the end of the file
+			return models.MediaMetadata{}, errMalformedMedia
+		}
+		if _, err := io.CopyN(io.Discard, r, bodySize); err != nil {
+			return models.MediaMetadata{}, errMalformedMedia
+		}
+	}
+}
+
+// Returns the type of the box and the size of its body. The size is -1 if the box runs to the end of the file.
+func readMP4BoxHeader(r io.Reader) (string, int64, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", 0, err
+	}
+	size, boxType := int64(binary.BigEndian.Uint32(header[:4])), string(header[4:8])
+	switch size {
+	case 0:
+		return boxType, -1, nil
+	case 1: // the real size follows as 64 bits
+		if _, err := io.ReadFull(r, header); err != nil {
+			return "", 0, err
+		}
+		size = int64(binary.BigEndian.Uint64(header))
+		if size < 16 {
+			return "", 0, errMalformedMedia
+		}
+		return boxType, size - 16, nil
+	}
+	if size < 8 {
+		return "", 0, errMalformedMedia
+	}
+	return boxType, size - 8, nil
+}
+
+// Calls fn with every box in data, a sequence of boxes held in memory, until fn returns false
+func eachMP4Box(data []byte, fn func(boxType string, body []byte) bool) error {
+	for len(data) >= 8 {
+		size, boxType, headerSize := uint64(binary.BigEndian.Uint32(data[:4])), string(data[4:8]), uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return errMalformedMedia
+			}
+			size, headerSize = binary.BigEndian.Uint64(data[8:16]), 16
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			return errMalformedMedia
+		}
+		if !fn(boxType, data[headerSize:size]) {
+			return nil
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// Returns the body of the first box found by following path down from data, nil if there is none
+func findMP4Box(data []byte, path ...string) []byte {
+	for _, boxType := range path {
+		var found []byte
+		eachMP4Box(data, func(t string, body []byte) bool {
+			if t == boxType {
+				found = body
+				return false
+			}
+			return true
+		})
+		if found == nil {
+			return nil
+		}
+		data = found
+	}
+	return data
+}
+
+func parseMP4Moov(moov []byte) (models.MediaMetadata, error) {
+	metadata := models.MediaMetadata{Format: models.MediaFormatMP4}
+	err := eachMP4Box(moov, func(boxType string, body []byte) bool {
+		switch boxType {
+		case "mvhd":
+			metadata.Duration = mp4Duration(body)
+		case "trak":
+			addMP4Track(&metadata, body)
+		}
+		return true
+	})
+	if err != nil {
+		return models.MediaMetadata{}, err
+	}
+	return metadata, nil
+}
+
+// Reads the duration from the body of a mvhd box, whose layout depends on its version
+func mp4Duration(mvhd []byte) float64 {
+	var timescale, duration uint64
+	switch {
+	case len(mvhd) >= 20 && mvhd[0] == 0:
+		timescale, duration = uint64(binary.BigEndian.Uint32(mvhd[12:16])), uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	case len(mvhd) >= 32 && mvhd[0] == 1:
+		timescale, duration = uint64(binary.BigEndian.Uint32(mvhd[20:24])), binary.BigEndian.Uint64(mvhd[24:32])
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return float64(duration) / float64(timescale)
+}
+
+// Sets the codec of the track, and its dimensions for video, unless an earlier track of the same kind was found
+func addMP4Track(metadata *models.MediaMetadata, trak []byte) {
+	hdlr := findMP4Box(trak, "mdia", "hdlr")
+	stsd := findMP4Box(trak, "mdia", "minf", "stbl", "stsd")
+	if len(hdlr) < 12 || len(stsd) < 16 {
+		return
+	}
+	// stsd: version and flags, number of entries, then the sample entries, each a box named after its codec
+	entry := stsd[8:]
+	codec, ok := mp4Codecs[string(entry[4:8])]
+	if !ok && isPrintableFourCC(entry[4:8]) { // other codecs are kept by their four character code, unless it's binary garbage
+		codec = strings.TrimSpace(string(entry[4:8]))
+	}
+
+	switch string(hdlr[8:12]) {
+	case "vide":
+		if metadata.VideoCodec != "" {
+			return
+		}
+		metadata.VideoCodec = codec
+		// visual sample entries have their width and height 24 bytes into the entry body
+		if len(entry) >= 8+28 {
+			metadata.Width = int(binary.BigEndian.Uint16(entry[8+24 : 8+26]))
+			metadata.Height = int(binary.BigEndian.Uint16(entry[8+26 : 8+28]))
+		}
+	case "soun":
+		if metadata.AudioCodec == "" {
+			metadata.AudioCodec = codec
+		}
+	}
+}
+
+// Four character codes are printable ASCII, anything else is not a codec name
+func isPrintableFourCC(code []byte) bool {
+	for _, b := range code {
+		if b < 0x20 || b > 0x7E {
+			return false
+		}
+	}
+	return true
+}
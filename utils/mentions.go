@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"log"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/models"
+)
+
+// Notifies the mentioned profiles that can see the post. Profiles that muted the mentioner aren't notified. Failures are only logged since the post or comment is already saved.
+func NotifyMentions(mentioner models.Profile, profileIds []string, postId string, inComment bool) {
+	if len(profileIds) == 0 {
+		return
+	}
+
+	query := "SELECT profiles.id FROM profiles WHERE profiles.id IN ? AND NOT EXISTS (SELECT 1 FROM profile_mutes WHERE profile_mutes.profile_id = profiles.id AND profile_mutes.muted_id = ? AND (profile_mutes.expires_at IS NULL OR profile_mutes.expires_at > NOW()));"
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var recipientIds []string
+	if err := configs.Database.WithContext(dbCtx).Raw(query, profileIds, mentioner.Id).Scan(&recipientIds).Error; err != nil {
+		log.Printf("mentions: could not get profiles to notify of post %s: %v", postId, err)
+		return
+	}
+
+	body := mentioner.Username + " mentioned you in a post."
+	if inComment {
+		body = mentioner.Username + " mentioned you in a comment."
+	}
+	for _, profileId := range recipientIds {
+		canAccess, err := CanAccessPost(profileId, postId)
+		if err != nil {
+			log.Printf("mentions: could not check if %s can see post %s: %v", profileId, postId, err)
+			continue
+		}
+		if !canAccess {
+			continue
+		}
+
+		notification := models.Notification{
+			ProfileId: profileId,
+			Title:     "New mention",
+			Body:      body,
+			Link:      "/posts/" + postId,
+		}
+		dbCtx2, dbCancel2 := configs.NewQueryContext()
+		err = configs.Database.WithContext(dbCtx2).Create(&notification).Error
+		dbCancel2()
+		if err != nil {
+			log.Printf("mentions: could not notify %s of post %s: %v", profileId, postId, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import "strings"
+
+// SQL condition that is true when the viewer has not muted the profile referenced by column. Expired mutes are ignored.
+//
+// The condition has one placeholder which takes the viewer's profile id.
+func NotMutedCondition(column string) string {
+	return "NOT EXISTS (SELECT 1 FROM profile_mutes WHERE profile_mutes.profile_id = ? AND profile_mutes.muted_id = " + column + " AND (profile_mutes.expires_at IS NULL OR profile_mutes.expires_at > NOW()))"
+}
+
+// SQL condition that is true when none of the viewer's muted keywords appear as whole words in any of the text columns, so muting "art" doesn't hide "party".
+// Matching is case insensitive. Expired keywords are ignored.
+//
+// The condition has one placeholder which takes the viewer's profile id.
+func NoMutedKeywordCondition(columns ...string) string {
+	// every character of the keyword that isn't a letter, digit or space is escaped so the keyword is matched literally
+	keywordPattern := `'(^|[^[:alnum:]_])' || regexp_replace(muted_keywords.keyword, '([^[:alnum:][:space:]])', '\\\1', 'g') || '($|[^[:alnum:]_])'`
+	matches := make([]string, len(columns))
+	for i, column := range columns {
+		matches[i] = column + " ~* (" + keywordPattern + ")"
+	}
+	return "NOT EXISTS (SELECT 1 FROM muted_keywords WHERE muted_keywords.profile_id = ? AND (muted_keywords.expires_at IS NULL OR muted_keywords.expires_at > NOW()) AND (" + strings.Join(matches, " OR ") + "))"
+}
+
+// SQL condition that is true when a row of the posts table isn't a plain repost or the viewer hasn't muted the author of the post it reposts
+// or a keyword in it. A plain repost has no text of its own, so it is hidden whenever the original would be.
+//
+// The condition has two placeholders which both take the viewer's profile id.
+func RepostNotMutedCondition() string {
+	condition := "(posts.repost_of_id IS NULL OR posts.caption <> '' OR posts.repost_of_id IN ("
+	condition += "SELECT originals.id FROM posts AS originals WHERE " + NotMutedCondition("originals.profile_id") + " AND " + NoMutedKeywordCondition("originals.title", "originals.caption")
+	condition += "))"
+	return condition
+}
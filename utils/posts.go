@@ -0,0 +1,62 @@
+package utils
+
+import "nerajima.com/NeraJima/configs"
+
+// SQL condition that is true when a row of the posts table has been published. Scheduled posts have a publish_at until the publisher job makes them live.
+//
+// The condition has no placeholders.
+func PublishedCondition() string {
+	return "posts.publish_at IS NULL"
+}
+
+/*
+   A published post can be seen by its owner and, if it isn't archived, by everyone who
+      1. has no block with the owner
+      2. is in the post's audience, if it is limited to one
+      3. is an accepted subscriber of the owner whose tier reaches the post's minimum tier for subscriber only posts, or an approved follower of a private owner for other posts
+
+   The condition below checks this for a row of the posts table joined with the profile of its owner. Every placeholder takes the viewer's profile id,
+   PostAccessArgs returns the arguments for all of them.
+*/
+
+const numPostAccessPlaceholders = 7
+
+func PostAccessCondition() string {
+	condition := "(" + PublishedCondition() + " AND (posts.profile_id = ? OR (posts.is_archived = false AND " + NotBlockedCondition("posts.profile_id") + " AND " + InAudienceCondition() + " AND ("
+	condition += "(posts.for_subscribers_only = true AND " + SubscribedToPostCondition() + ") OR "
+	condition += "(posts.for_subscribers_only = false AND (profiles.is_private = false OR EXISTS (SELECT 1 FROM profile_followers WHERE profile_followers.profile_id = posts.profile_id AND profile_followers.follower_id = ? AND profile_followers.is_pending = false)))"
+	condition += "))))"
+	return condition
+}
+
+func PostAccessArgs(viewerId string) []interface{} {
+	args := make([]interface{}, numPostAccessPlaceholders)
+	for i := range args {
+		args[i] = viewerId
+	}
+	return args
+}
+
+// Returns true if the viewer can see the post. Returns false if the post does not exist.
+func CanAccessPost(viewerId, postId string) (bool, error) {
+	query := "SELECT " + PostAccessCondition() + " FROM posts JOIN profiles ON profiles.id = posts.profile_id WHERE posts.id = ?;"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var canAccess bool
+	if err := configs.Database.WithContext(dbCtx).Raw(query, append(PostAccessArgs(viewerId), postId)...).Scan(&canAccess).Error; err != nil {
+		return false, err
+	}
+	return canAccess, nil
+}
+
+// SQL condition that is true when a row of the posts table isn't a plain repost or the viewer can see the post it reposts. A plain repost has no
+// text of its own, so it is left out of lists when the original can't be shown.
+//
+// Every placeholder takes the viewer's profile id, PostAccessArgs returns the arguments for all of them.
+func RepostAccessCondition() string {
+	condition := "(posts.repost_of_id IS NULL OR posts.caption <> '' OR posts.repost_of_id IN ("
+	condition += "SELECT posts.id FROM posts JOIN profiles ON profiles.id = posts.profile_id WHERE " + PostAccessCondition() // the inner posts and profiles are the originals
+	condition += "))"
+	return condition
+}
@@ -0,0 +1,9 @@
+package utils
+
+// SQL condition that is true when a row of the profile_subscribers table hasn't expired. Expired subscriptions are removed by a background job,
+// this keeps them from granting access in the meantime.
+//
+// The condition has no placeholders.
+func UnexpiredSubscriptionCondition() string {
+	return "(profile_subscribers.expires_at IS NULL OR profile_subscribers.expires_at > NOW())"
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"time"
+
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/cache"
+)
+
+const (
+	SuggestionsExpiry = time.Hour * 12
+	maxSuggestions    = 100 // number of suggestions kept per profile
+)
+
+// A profile suggested to another profile and the number of profiles the two have in common
+type Suggestion struct {
+	ProfileId  string `json:"profile_id"`
+	NumMutuals int    `json:"num_mutuals"`
+}
+
+/*
+   Suggestions are friends of friends: profiles followed by the profiles someone follows, ranked by how many of the profiles they follow do so.
+   Profiles that are already followed (or requested) are left out here. Blocks and dismissals change often so they are filtered when the suggestions are read.
+*/
+
+// Computes the suggestions of every profile in profileIds and stores them in the cache. Profiles without suggestions get an empty list so the cache still gets hit.
+func RefreshSuggestions(profileIds ...string) (map[string][]Suggestion, error) {
+	query := "SELECT profile_id, suggested_id, num_mutuals FROM ("
+	query += "SELECT f1.follower_id AS profile_id, f2.profile_id AS suggested_id, COUNT(*) AS num_mutuals, "
+	query += "ROW_NUMBER() OVER (PARTITION BY f1.follower_id ORDER BY COUNT(*) DESC, f2.profile_id) AS rank "
+	query += "FROM profile_followers f1 "
+	query += "JOIN profile_followers f2 ON f2.follower_id = f1.profile_id AND f2.is_pending = false "
+	query += "WHERE f1.follower_id IN ? AND f1.is_pending = false AND f2.profile_id <> f1.follower_id "
+	query += "AND NOT EXISTS (SELECT 1 FROM profile_followers f3 WHERE f3.follower_id = f1.follower_id AND f3.profile_id = f2.profile_id) "
+	query += "GROUP BY f1.follower_id, f2.profile_id"
+	query += ") ranked WHERE rank <= ? ORDER BY profile_id, rank;"
+
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var rows []struct {
+		ProfileId   string
+		SuggestedId string
+		NumMutuals  int
+	}
+	if err := configs.Database.WithContext(dbCtx).Raw(query, profileIds, maxSuggestions).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	suggestions := make(map[string][]Suggestion, len(profileIds))
+	for _, profileId := range profileIds {
+		suggestions[profileId] = []Suggestion{}
+	}
+	for _, row := range rows {
+		suggestions[row.ProfileId] = append(suggestions[row.ProfileId], Suggestion{ProfileId: row.SuggestedId, NumMutuals: row.NumMutuals})
+	}
+
+	for profileId, profileSuggestions := range suggestions {
+		cacheCtx, cacheCancel := cache.NewCacheContext()
+		err := cache.Set(cacheCtx, cache.SuggestionsKey(profileId), profileSuggestions, SuggestionsExpiry)
+		cacheCancel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return suggestions, nil
+}
+
+// Returns the cached suggestions of a profile, computing them if they are not cached
+func GetSuggestions(profileId string) ([]Suggestion, error) {
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	defer cacheCancel()
+	var suggestions []Suggestion
+	if err := cache.Get(cacheCtx, cache.SuggestionsKey(profileId), &suggestions); err == nil {
+		return suggestions, nil
+	}
+
+	computed, err := RefreshSuggestions(profileId)
+	if err != nil {
+		return nil, err
+	}
+	return computed[profileId], nil
+}
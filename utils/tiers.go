@@ -0,0 +1,20 @@
+package utils
+
+// SQL condition that is true when a subscription whose tier is referenced by tierColumn reaches the minimum tier of a row of the posts table.
+// Posts without a minimum tier are reached by every subscription and subscriptions without a tier rank below every tier.
+//
+// The condition has no placeholders.
+func MeetsMinTierCondition(tierColumn string) string {
+	condition := "(posts.min_tier_id IS NULL OR COALESCE((SELECT subscription_tiers.rank FROM subscription_tiers WHERE subscription_tiers.id = " + tierColumn + "), 0) >= "
+	condition += "(SELECT subscription_tiers.rank FROM subscription_tiers WHERE subscription_tiers.id = posts.min_tier_id))"
+	return condition
+}
+
+// SQL condition that is true when the viewer is an accepted, unexpired subscriber of the owner of a row of the posts table and their tier reaches the minimum tier of the post.
+//
+// The condition has one placeholder which takes the viewer's profile id.
+func SubscribedToPostCondition() string {
+	condition := "EXISTS (SELECT 1 FROM profile_subscribers WHERE profile_subscribers.profile_id = posts.profile_id AND profile_subscribers.subscriber_id = ? AND profile_subscribers.is_accepted = true AND "
+	condition += UnexpiredSubscriptionCondition() + " AND " + MeetsMinTierCondition("profile_subscribers.tier_id") + ")"
+	return condition
+}
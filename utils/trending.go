@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"nerajima.com/NeraJima/configs"
+	"nerajima.com/NeraJima/configs/cache"
+)
+
+/*
+   Trending searches are counted in Redis in one sorted set per hour. The members are the searched terms and the scores are the number
+   of profiles that searched them in that hour. A window is the sum of the buckets it covers, so it slides forward an hour at a time.
+
+   Nothing that identifies a searcher is kept. A profile is counted once per term and hour through a key named after an HMAC of both,
+   keyed with a server secret so the key can't be matched to a profile by hashing guesses, and terms searched by fewer than
+   trendingMinSearchers profiles are never shown.
+*/
+
+const (
+	trendingBucketSize   = time.Hour
+	trendingMaxWindow    = time.Hour * 24 * 7
+	trendingMinSearchers = 3
+	trendingResultExpiry = time.Minute
+	maxTrendingSearches  = 20
+)
+
+// Windows trending searches can be read over
+var TrendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": time.Hour * 24,
+	"7d":  trendingMaxWindow,
+}
+
+func trendingBucket(t time.Time) int64 {
+	return t.Truncate(trendingBucketSize).Unix()
+}
+
+// Counts a search of term by the profile towards trending searches
+func RecordTrendingSearch(profileId, term string) error {
+	bucket := trendingBucket(time.Now())
+	mac := hmac.New(sha256.New, []byte(configs.EnvTrendingSearchSecret()))
+	mac.Write([]byte(profileId + "\x00" + term))
+	hash := mac.Sum(nil)
+
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	defer cacheCancel()
+	isFirst, err := cache.SetIfNotExists(cacheCtx, cache.TrendingSearcherKey(bucket, hex.EncodeToString(hash)), true, trendingBucketSize)
+	if err != nil {
+		return err
+	}
+	if !isFirst { // the profile already searched this term in this bucket
+		return nil
+	}
+
+	cacheCtx2, cacheCancel2 := cache.NewCacheContext()
+	defer cacheCancel2()
+	return cache.IncrementScore(cacheCtx2, cache.TrendingBucketKey(bucket), term, 1, trendingMaxWindow+trendingBucketSize)
+}
+
+// Returns the most searched terms over the named window, most searched first. Results are cached for a minute.
+func TrendingSearches(window string) ([]cache.ScoredMember, error) {
+	var trending []cache.ScoredMember
+	cacheCtx, cacheCancel := cache.NewCacheContext()
+	defer cacheCancel()
+	if err := cache.Get(cacheCtx, cache.TrendingResultKey(window), &trending); err == nil {
+		return trending, nil
+	} else if err != redis.Nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	keys := []string{}
+	for t := now.Add(-TrendingWindows[window] + trendingBucketSize); !t.After(now); t = t.Add(trendingBucketSize) { // the current bucket and the ones before it that fill the window
+		keys = append(keys, cache.TrendingBucketKey(trendingBucket(t)))
+	}
+
+	cacheCtx2, cacheCancel2 := cache.NewCacheContext()
+	defer cacheCancel2()
+	top, err := cache.TopScores(cacheCtx2, keys, maxTrendingSearches)
+	if err != nil {
+		return nil, err
+	}
+
+	trending = []cache.ScoredMember{}
+	for _, member := range top {
+		if member.Score >= trendingMinSearchers {
+			trending = append(trending, member)
+		}
+	}
+
+	cacheCtx3, cacheCancel3 := cache.NewCacheContext()
+	defer cacheCancel3()
+	cache.Set(cacheCtx3, cache.TrendingResultKey(window), trending, trendingResultExpiry) // the result is still correct if it couldn't be cached
+
+	return trending, nil
+}
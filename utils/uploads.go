@@ -0,0 +1,9 @@
+package utils
+
+// SQL condition that is true when a row of the uploads table can still be used. An upload a draft refers to doesn't expire,
+// so the draft can be published later.
+//
+// The condition has no placeholders.
+func UnexpiredUploadCondition() string {
+	return "(uploads.expires_at > NOW() OR EXISTS (SELECT 1 FROM draft_media WHERE draft_media.upload_id = uploads.id))"
+}
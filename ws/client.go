@@ -10,7 +10,9 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"github.com/google/uuid"
+	"nerajima.com/NeraJima/configs"
 	"nerajima.com/NeraJima/models"
+	"nerajima.com/NeraJima/utils"
 )
 
 type client struct {
@@ -72,6 +74,11 @@ func (c *client) readMessage(h *Hub) {
 			continue
 		}
 
+		if err := msg.removeBlockedRecipients(c); err != nil {
+			c.Conn.WriteJSON(&fiber.Map{"error": err.Error()})
+			continue
+		}
+
 		h.NewBroadcast(&msg)
 	}
 }
@@ -87,3 +94,18 @@ func (m *Message) Format(c *client) error {
 	}
 	return nil
 }
+
+// Drops every recipient that has a block with the sender. Errors if there is no one left to deliver the message to.
+func (m *Message) removeBlockedRecipients(c *client) error {
+	dbCtx, dbCancel := configs.NewQueryContext()
+	defer dbCancel()
+	var recipients []string
+	if err := configs.Database.WithContext(dbCtx).Table("profiles").Where("user_id IN ? AND "+utils.NotBlockedCondition("profiles.id"), m.To, c.Profile.Id, c.Profile.Id).Pluck("user_id", &recipients).Error; err != nil {
+		return errors.New("unexpected error, please try again")
+	}
+	if len(recipients) <= 0 {
+		return errors.New("recipients not available")
+	}
+	m.To = recipients
+	return nil
+}